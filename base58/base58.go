@@ -26,7 +26,9 @@ func Base58Encode(input []byte) []byte {
 	}
 
 	domain.ReverseBytes(result)
-	for b := range input {
+
+	// Each leading zero byte is encoded as the first character.
+	for _, b := range input {
 		if b == 0x00 {
 			result = append([]byte{b58Alphabet[0]}, result...)
 		} else {
@@ -42,10 +44,13 @@ func Base58Decode(input []byte) []byte {
 	result := big.NewInt(0)
 	zeroBytes := 0
 
-	for b := range input {
-		if b == 0x00 {
-			zeroBytes++
+	// Each leading instance of the first character is a zero byte.
+	for _, b := range input {
+		if b != b58Alphabet[0] {
+			break
 		}
+
+		zeroBytes++
 	}
 
 	payload := input[zeroBytes:]
@@ -0,0 +1,21 @@
+package base58
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBase58RoundTrip(t *testing.T) {
+	inputs := [][]byte{
+		{0x00, 0x01, 0x02},
+		{0x00, 0x00, 0xff, 0x10},
+		{0x61, 0x62, 0x63},
+	}
+
+	for _, input := range inputs {
+		assert.Equal(t, input, Base58Decode(Base58Encode(input)), "Input survives a round trip")
+	}
+
+	assert.Equal(t, "11LQo", string(Base58Encode([]byte{0x00, 0x00, 0xff, 0x10})), "Leading zeros are kept")
+}
@@ -0,0 +1,134 @@
+package chaincfg
+
+import (
+	"fmt"
+	"time"
+)
+
+// Params defines a yagocoin network. Nodes on different networks keep their
+// blockchains and wallets in different files, use different address versions
+// and ignore each others messages.
+type Params struct {
+	// Name of the network, as given to --network.
+	Name string
+
+	// Magic starts every message sent between nodes of the network.
+	Magic [4]byte
+
+	// Addresses of the nodes to connect to first. The first is the central
+	// node.
+	SeedNodes []string
+
+	// File names of the blockchain db and wallets, formatted with the node ID.
+	DBFile     string
+	WalletFile string
+
+	// Text in the coinbase of the genesis block.
+	GenesisCoinbaseData string
+
+	// Version bytes which start addresses paying to a public key hash, and
+	// to a script hash.
+	AddressVersion           byte
+	ScriptHashAddressVersion byte
+
+	// Version byte which starts exported private keys.
+	PrivateKeyVersion byte
+
+	// The easiest target a block may have, in compact form. Genesis blocks
+	// are mined at this target.
+	PowLimitBits uint32
+
+	// The number of blocks between difficulty adjustments, and the time we
+	// want there to be between blocks.
+	RetargetInterval int
+	TargetSpacing    time.Duration
+
+	// Whether the target stays at the limit rather than adjusting.
+	NoRetargeting bool
+
+	// The number of blocks a coinbase must be buried under before its
+	// outputs can be spent.
+	CoinbaseMaturity int
+
+	// The emission schedule, see crypto.EmissionSchedule.
+	InitialReward   int
+	HalvingInterval int
+	MinUnit         int
+}
+
+// MainNetParams are the parameters of the main network.
+var MainNetParams = Params{
+	Name:                     "mainnet",
+	Magic:                    [4]byte{0x79, 0x61, 0x67, 0x6d},
+	SeedNodes:                []string{"localhost:3000"},
+	DBFile:                   "blockchain_%s.db",
+	WalletFile:               "wallet_%s.dat",
+	GenesisCoinbaseData:      "The Times 03/Jan/2009 Chancellor on brink of second bailout for banks",
+	AddressVersion:           0x00,
+	ScriptHashAddressVersion: 0x05,
+	PrivateKeyVersion:        0x80,
+	PowLimitBits:             0x1f00ffff,
+	RetargetInterval:         10,
+	TargetSpacing:            10 * time.Second,
+	CoinbaseMaturity:         100,
+	InitialReward:            10,
+	HalvingInterval:          100000,
+	MinUnit:                  1,
+}
+
+// TestNetParams are the parameters of the test network. It follows the same
+// rules as the main network, on a separate chain.
+var TestNetParams = Params{
+	Name:                     "testnet",
+	Magic:                    [4]byte{0x79, 0x61, 0x67, 0x74},
+	SeedNodes:                []string{"localhost:13000"},
+	DBFile:                   "blockchain_testnet_%s.db",
+	WalletFile:               "wallet_testnet_%s.dat",
+	GenesisCoinbaseData:      "yagocoin testnet genesis block",
+	AddressVersion:           0x6f,
+	ScriptHashAddressVersion: 0xc4,
+	PrivateKeyVersion:        0xef,
+	PowLimitBits:             0x1f00ffff,
+	RetargetInterval:         10,
+	TargetSpacing:            10 * time.Second,
+	CoinbaseMaturity:         100,
+	InitialReward:            10,
+	HalvingInterval:          100000,
+	MinUnit:                  1,
+}
+
+// RegressionNetParams are the parameters of the regression test network. Its
+// difficulty is trivial, so blocks can be mined on demand.
+var RegressionNetParams = Params{
+	Name:                     "regtest",
+	Magic:                    [4]byte{0x79, 0x61, 0x67, 0x72},
+	SeedNodes:                []string{"localhost:23000"},
+	DBFile:                   "blockchain_regtest_%s.db",
+	WalletFile:               "wallet_regtest_%s.dat",
+	GenesisCoinbaseData:      "yagocoin regtest genesis block",
+	AddressVersion:           0x7a,
+	ScriptHashAddressVersion: 0xc5,
+	PrivateKeyVersion:        0xfa,
+	PowLimitBits:             0x207fffff,
+	RetargetInterval:         10,
+	TargetSpacing:            10 * time.Second,
+	NoRetargeting:            true,
+	CoinbaseMaturity:         100,
+	InitialReward:            10,
+	HalvingInterval:          150,
+	MinUnit:                  1,
+}
+
+// Networks lists the built-in networks.
+var Networks = []*Params{&MainNetParams, &TestNetParams, &RegressionNetParams}
+
+// ParamsForName returns the built-in network with the given name.
+func ParamsForName(name string) (*Params, error) {
+	for _, params := range Networks {
+		if params.Name == name {
+			return params, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown network %q", name)
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/danmrichards/yagocoin/crypto"
+	"github.com/spf13/cobra"
+)
+
+var (
+	required     int
+	multiSigKeys []string
+
+	createMultiSigCmd = &cobra.Command{
+		Use:   "createmultisig",
+		Short: "Create an address needing signatures from several keys to spend",
+		Long: `Create an address needing signatures from several keys to spend.
+
+Each key is either a hex encoded public key, as shown by getpubkey, or an
+address in the wallet file. Coins sent to the address are spent with
+spendmultisig, and the redeem script printed here is needed to do so.`,
+		Run:     createMultiSig,
+		Args:    cobra.ExactArgs(0),
+		PreRun:  cmdPreRun,
+		PostRun: cmdPostRun,
+	}
+)
+
+func init() {
+	createMultiSigCmd.Flags().IntVarP(&required, "required", "m", 0, "Number of signatures needed to spend")
+	createMultiSigCmd.Flags().StringArrayVarP(&multiSigKeys, "key", "k", nil, "Public key or wallet address, repeated for each key")
+	rootCmd.AddCommand(createMultiSigCmd)
+}
+
+// Create an address needing signatures from several keys to spend.
+func createMultiSig(cmd *cobra.Command, _ []string) {
+	if len(multiSigKeys) == 0 {
+		fmt.Printf("Missing keys\n")
+		fmt.Println()
+
+		cmd.Usage()
+		return
+	}
+
+	var pubKeys [][]byte
+	for _, key := range multiSigKeys {
+		if crypto.ValidateAddress(key) {
+			wallet, err := loadWallet(key)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+
+			pubKeys = append(pubKeys, wallet.PublicKey)
+			continue
+		}
+
+		pubKey, err := hex.DecodeString(key)
+		if err != nil {
+			fmt.Printf("Key '%s' is not an address or a hex encoded public key\n", key)
+			return
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+
+	address, redeemScript, err := crypto.NewMultiSigAddress(required, pubKeys)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Printf("Address: %s\n", address)
+	fmt.Printf("Redeem script: %x\n", redeemScript)
+}
@@ -11,25 +11,21 @@ import (
 
 var (
 	createWalletCmd = &cobra.Command{
-		Use:   "createwallet",
-		Short: "Generates a new key-pair and saves it into the wallet file",
-		Run:   createWallet,
-		Args:  cobra.ExactArgs(0),
+		Use:    "createwallet",
+		Short:  "Generates a new key-pair and saves it into the wallet file",
+		Run:    createWallet,
+		Args:   cobra.ExactArgs(0),
+		PreRun: walletPreRun,
 	}
 )
 
 func init() {
+	createWalletCmd.Flags().StringVar(&passphrase, "passphrase", "", passphraseUsage)
 	rootCmd.AddCommand(createWalletCmd)
 }
 
 // Create a new key-pair and save it into the wallet file
 func createWallet(_ *cobra.Command, _ []string) {
-	nodeID = os.Getenv("NODE_ID")
-	if nodeID == "" {
-		fmt.Printf("NODE_ID env. var is not set!")
-		os.Exit(1)
-	}
-
 	wallets, err := crypto.NewWallets(nodeID)
 	if err != nil && !os.IsNotExist(err) {
 		log.Printf("could not create wallet: %s", err)
@@ -38,8 +34,40 @@ func createWallet(_ *cobra.Command, _ []string) {
 		return
 	}
 
-	address := wallets.CreateWallet()
+	// New wallet files derive every key from one mnemonic.
+	mnemonic = ""
+	if os.IsNotExist(err) {
+		mnemonic, err = crypto.NewMnemonic()
+		if err != nil {
+			log.Panic(err)
+		}
+
+		err = wallets.SetMnemonic(mnemonic)
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+
+	// The new key is encrypted along with the others.
+	err = unlockWallets(wallets)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	address, err := wallets.CreateWallet()
+	if err != nil {
+		log.Panic(err)
+	}
 	wallets.SaveToFile(nodeID)
 
 	fmt.Printf("Your new address: %s\n", address)
+
+	if mnemonic != "" {
+		fmt.Println()
+		fmt.Println("Write down this mnemonic, it restores every address of the wallet file with")
+		fmt.Println("restorewallet:")
+		fmt.Println()
+		fmt.Printf("  %s\n", mnemonic)
+	}
 }
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/danmrichards/yagocoin/crypto"
+	"github.com/spf13/cobra"
+)
+
+var dumpMnemonicCmd = &cobra.Command{
+	Use:   "dumpmnemonic",
+	Short: "Print the mnemonic the keys of the wallet file are derived from",
+	Long: `Print the mnemonic the keys of the wallet file are derived from.
+
+Anyone with the mnemonic can spend the coins of every address derived from it,
+so keep it somewhere safe. Wallet files created before mnemonics existed don't
+have one.`,
+	Run:    dumpMnemonic,
+	Args:   cobra.ExactArgs(0),
+	PreRun: walletPreRun,
+}
+
+func init() {
+	dumpMnemonicCmd.Flags().StringVar(&passphrase, "passphrase", "", passphraseUsage)
+	rootCmd.AddCommand(dumpMnemonicCmd)
+}
+
+// Print the mnemonic of the wallet file.
+func dumpMnemonic(_ *cobra.Command, _ []string) {
+	wallets, err := crypto.NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if !wallets.IsHD() {
+		fmt.Println("The wallet file has no mnemonic")
+		return
+	}
+
+	err = unlockWallets(wallets)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	mnemonic, err := wallets.Mnemonic()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println(mnemonic)
+}
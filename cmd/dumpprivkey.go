@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/danmrichards/yagocoin/crypto"
+	"github.com/spf13/cobra"
+)
+
+var dumpPrivKeyCmd = &cobra.Command{
+	Use:   "dumpprivkey",
+	Short: "Print the private key of an address in the wallet file",
+	Long: `Print the private key of an address in the wallet file.
+
+The key is Base58Check encoded for the network, and can be added to another
+wallet file with importprivkey. Anyone with it can spend the coins of the
+address, so keep it somewhere safe.`,
+	Run:    dumpPrivKey,
+	Args:   cobra.ExactArgs(0),
+	PreRun: walletPreRun,
+}
+
+func init() {
+	dumpPrivKeyCmd.Flags().StringVarP(&address, "address", "a", "", "Address to print the private key of")
+	dumpPrivKeyCmd.Flags().StringVar(&passphrase, "passphrase", "", passphraseUsage)
+	rootCmd.AddCommand(dumpPrivKeyCmd)
+}
+
+// Print the private key of an address in the wallet file.
+func dumpPrivKey(cmd *cobra.Command, _ []string) {
+	// Validate the address argument.
+	if address == "" {
+		fmt.Printf("Invalid or missing address\n")
+		fmt.Println()
+
+		cmd.Usage()
+		return
+	}
+
+	if !crypto.ValidateAddress(address) {
+		fmt.Println("ERROR: Address is not valid")
+		return
+	}
+
+	wallet, err := signingWallet(address)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Println(wallet.ExportPrivateKey())
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/danmrichards/yagocoin/crypto"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Text file of private keys written by dumpwallet.
+	dumpFile string
+
+	dumpWalletCmd = &cobra.Command{
+		Use:   "dumpwallet",
+		Short: "Write every private key in the wallet file to a text file",
+		Long: `Write every private key in the wallet file to a text file.
+
+Each line holds a private key, as printed by dumpprivkey, followed by its
+address. Lines starting with # are comments, one of which holds the mnemonic if
+the wallet file has one. The keys can be added to another wallet file with
+importwallet. Anyone with the file can spend the coins of every address in it,
+so keep it somewhere safe.`,
+		Run:    dumpWallet,
+		Args:   cobra.ExactArgs(0),
+		PreRun: walletPreRun,
+	}
+)
+
+func init() {
+	dumpWalletCmd.Flags().StringVar(&dumpFile, "file", "", "File to write the keys to, which mustn't exist")
+	dumpWalletCmd.Flags().StringVar(&passphrase, "passphrase", "", passphraseUsage)
+	rootCmd.AddCommand(dumpWalletCmd)
+}
+
+// Write every private key in the wallet file to a text file.
+func dumpWallet(cmd *cobra.Command, _ []string) {
+	// Validate the file argument.
+	if dumpFile == "" {
+		fmt.Printf("Missing file\n")
+		fmt.Println()
+
+		cmd.Usage()
+		return
+	}
+
+	wallets, err := crypto.NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	err = unlockWallets(wallets)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// Only the user may read the keys, and an older dump is never replaced.
+	f, err := os.OpenFile(dumpFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "# Wallet dump of node %s on %s, written %s\n", nodeID, crypto.Net.Name, time.Now().UTC().Format(time.RFC3339))
+	if wallets.IsHD() {
+		mnemonic, err := wallets.Mnemonic()
+		if err != nil {
+			log.Panic(err)
+		}
+
+		fmt.Fprintf(w, "# mnemonic: %s\n", mnemonic)
+	}
+
+	addresses := wallets.GetAddresses()
+	sort.Strings(addresses)
+
+	for _, address := range addresses {
+		wallet, err := wallets.GetWallet(address)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		fmt.Fprintf(w, "%s %s\n", wallet.ExportPrivateKey(), address)
+	}
+
+	err = w.Flush()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("Wrote %d keys to %s\n", len(addresses), dumpFile)
+}
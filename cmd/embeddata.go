@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/danmrichards/yagocoin/crypto"
+	"github.com/danmrichards/yagocoin/script"
+	"github.com/danmrichards/yagocoin/server"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dataHex  string
+	dataFile string
+
+	embedDataCmd = &cobra.Command{
+		Use:   "embeddata",
+		Short: "Embed data, such as a document hash, in the blockchain",
+		Long: fmt.Sprintf(`Embed data, such as a document hash, in the blockchain.
+
+The data is given in hex, up to %d bytes, or as a file whose SHA-256 hash is
+embedded. It goes in an unspendable output of a transaction paid for by the
+from address, with any change going back to it.`, script.MaxDataCarrierSize),
+		Run:     embedData,
+		Args:    cobra.ExactArgs(0),
+		PreRun:  cmdPreRun,
+		PostRun: cmdPostRun,
+	}
+)
+
+func init() {
+	embedDataCmd.Flags().StringVarP(&from, "from", "f", "", "Address to pay for the transaction from")
+	embedDataCmd.Flags().StringVar(&dataHex, "hex", "", "Hex encoded data to embed")
+	embedDataCmd.Flags().StringVar(&dataFile, "file", "", "File to embed the SHA-256 hash of")
+	embedDataCmd.Flags().IntVar(&fee, "fee", 0, "Fee to pay the miner of the transaction")
+	embedDataCmd.Flags().BoolVarP(&mineNow, "mine", "m", false, "Mine immediately on the same node")
+	embedDataCmd.Flags().StringVar(&passphrase, "passphrase", "", passphraseUsage)
+	rootCmd.AddCommand(embedDataCmd)
+}
+
+// Embed data in the blockchain.
+func embedData(cmd *cobra.Command, _ []string) {
+	// Validate the from adress.
+	if from == "" {
+		fmt.Printf("Invalid or missing from address\n")
+		fmt.Println()
+
+		cmd.Usage()
+		return
+	}
+
+	// Validate the fee.
+	if fee < 0 {
+		fmt.Printf("Invalid fee\n")
+		fmt.Println()
+
+		cmd.Usage()
+		return
+	}
+
+	var data []byte
+	switch {
+	case dataHex != "" && dataFile != "":
+		fmt.Printf("Give either hex data or a file, not both\n")
+		return
+	case dataHex != "":
+		var err error
+		data, err = hex.DecodeString(dataHex)
+		if err != nil {
+			fmt.Printf("Invalid hex data: %s\n", err)
+			return
+		}
+	case dataFile != "":
+		hash, err := fileHash(dataFile)
+		if err != nil {
+			fmt.Printf("Could not hash file: %s\n", err)
+			return
+		}
+		data = hash
+	default:
+		fmt.Printf("Missing data\n")
+		fmt.Println()
+
+		cmd.Usage()
+		return
+	}
+
+	wallet, err := signingWallet(from)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	uTxOSet := crypto.UTxOSet{Blockchain: bc}
+
+	tx, err := crypto.NewDataTransaction(wallet, data, fee, &uTxOSet)
+	if err != nil {
+		fmt.Printf("ERROR: %s\n", err)
+		return
+	}
+
+	if mineNow {
+		cbTx := crypto.NewCoinbaseTx(from, "", bc.GetBestHeight()+1, fee)
+		txs := []*crypto.Transaction{cbTx, tx}
+
+		_, err := bc.MineBlock(context.Background(), txs)
+		if err != nil {
+			log.Panic(err)
+		}
+	} else {
+		server.SendTx(server.KnownNodes[0], tx)
+	}
+
+	fmt.Printf("Embedded %x in transaction %x\n", data, tx.ID)
+}
+
+// fileHash returns the SHA-256 hash of the contents of a file.
+func fileHash(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
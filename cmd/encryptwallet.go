@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/danmrichards/yagocoin/crypto"
+	"github.com/spf13/cobra"
+)
+
+var encryptWalletCmd = &cobra.Command{
+	Use:   "encryptwallet",
+	Short: "Encrypt the private keys in the wallet file with a passphrase",
+	Long: `Encrypt the private keys in the wallet file with a passphrase.
+
+Once encrypted, commands which sign with the wallet file ask for the
+passphrase, or take it with --passphrase. Addresses can still be listed
+without it. Keep the passphrase safe, the coins can't be spent without it.`,
+	Run:    encryptWallet,
+	Args:   cobra.ExactArgs(0),
+	PreRun: walletPreRun,
+}
+
+func init() {
+	encryptWalletCmd.Flags().StringVar(&passphrase, "passphrase", "", "New passphrase of the wallet file, asked for if not given"+secretFlagWarning)
+	rootCmd.AddCommand(encryptWalletCmd)
+}
+
+// Encrypt the private keys in the wallet file with a passphrase.
+func encryptWallet(_ *cobra.Command, _ []string) {
+	wallets, err := crypto.NewWallets(nodeID)
+	if os.IsNotExist(err) {
+		fmt.Println("There is no wallet file, create one with createwallet")
+		return
+	} else if err != nil {
+		log.Panic(err)
+	}
+
+	if wallets.IsEncrypted() {
+		fmt.Println("The wallet file is already encrypted, use walletpassphrasechange to change the passphrase")
+		return
+	}
+
+	if passphrase == "" {
+		passphrase, err = readNewPassphrase()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	err = wallets.Encrypt([]byte(passphrase))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	wallets.SaveToFile(nodeID)
+
+	fmt.Println("Wallet file encrypted")
+}
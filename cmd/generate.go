@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/danmrichards/yagocoin/chaincfg"
+	"github.com/danmrichards/yagocoin/crypto"
+	"github.com/spf13/cobra"
+)
+
+var (
+	generateBlocks int
+
+	generateCmd = &cobra.Command{
+		Use:     "generate",
+		Short:   "Mine blocks to an address straight away, on regtest only",
+		Run:     generate,
+		Args:    cobra.ExactArgs(0),
+		PreRun:  cmdPreRun,
+		PostRun: cmdPostRun,
+	}
+)
+
+func init() {
+	generateCmd.Flags().StringVarP(&address, "address", "a", "", "Address to pay the block subsidies to")
+	generateCmd.Flags().IntVarP(&generateBlocks, "blocks", "n", 1, "Number of blocks to mine")
+	rootCmd.AddCommand(generateCmd)
+}
+
+// Mine blocks to an address straight away, on regtest only.
+func generate(cmd *cobra.Command, _ []string) {
+	if crypto.Net.Name != chaincfg.RegressionNetParams.Name {
+		fmt.Println("Blocks can only be generated on regtest, use --network regtest")
+		return
+	}
+
+	// Validate the address.
+	if !crypto.ValidateAddress(address) {
+		fmt.Printf("Invalid or missing address\n")
+		fmt.Println()
+
+		cmd.Usage()
+		return
+	}
+
+	// Validate the number of blocks.
+	if generateBlocks < 1 {
+		fmt.Printf("Invalid number of blocks\n")
+		fmt.Println()
+
+		cmd.Usage()
+		return
+	}
+
+	blocks, err := bc.Generate(context.Background(), address, generateBlocks)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	for _, block := range blocks {
+		fmt.Printf("%x\n", block.Hash)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 
 	"github.com/danmrichards/yagocoin/crypto"
+	"github.com/danmrichards/yagocoin/server"
 	"github.com/spf13/cobra"
 )
 
@@ -14,7 +15,7 @@ var (
 		Short:   "Get balance of adress",
 		Run:     getBalance,
 		Args:    cobra.ExactArgs(0),
-		PreRun:  cmdPreRun,
+		PreRun:  rpcPreRun,
 		PostRun: cmdPostRun,
 	}
 )
@@ -40,13 +41,27 @@ func getBalance(cmd *cobra.Command, _ []string) {
 		log.Panic("ERROR: Address is not valid")
 	}
 
-	balance := 0
-	uTxOSet := crypto.UTxOSet{bc}
-	UTXOs := uTxOSet.FindUTxO(crypto.GetPublicKeyHash([]byte(address)))
+	if rpcClient != nil {
+		var result server.BalanceResult
+
+		err := rpcClient.Call("getbalance", &result, address)
+		if err != nil {
+			log.Panic(err)
+		}
 
-	for _, out := range UTXOs {
-		balance += out.Value
+		printBalance(result.Balance, result.Immature)
+		return
 	}
 
+	uTxOSet := crypto.UTxOSet{bc}
+	printBalance(uTxOSet.GetBalance(crypto.GetPublicKeyHash([]byte(address))))
+}
+
+// printBalance prints the balance of the address, and the coinbase outputs
+// which can't be spent yet.
+func printBalance(balance, immature int) {
 	fmt.Printf("Balance of '%s': %d\n", address, balance)
+	if immature > 0 {
+		fmt.Printf("Immature coinbase of '%s': %d\n", address, immature)
+	}
 }
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	blockHash   string
+	blockHeight int
+
+	getBlockCmd = &cobra.Command{
+		Use:     "getblock",
+		Short:   "Print the block with the given hash or height",
+		Run:     getBlock,
+		Args:    cobra.ExactArgs(0),
+		PreRun:  rpcPreRun,
+		PostRun: cmdPostRun,
+	}
+)
+
+func init() {
+	getBlockCmd.Flags().StringVar(&blockHash, "hash", "", "Hash of the block")
+	getBlockCmd.Flags().IntVar(&blockHeight, "height", -1, "Height of the block on the main chain")
+	rootCmd.AddCommand(getBlockCmd)
+}
+
+// Print the block with the given hash or height.
+func getBlock(cmd *cobra.Command, _ []string) {
+	if blockHash == "" && blockHeight >= 0 {
+		block := blockAtHeight(blockHeight)
+		if block == nil {
+			fmt.Println("Block not found")
+			return
+		}
+
+		printBlock(block)
+		return
+	}
+
+	hash, err := hex.DecodeString(blockHash)
+	if blockHash == "" || err != nil {
+		fmt.Printf("Invalid or missing block hash or height\n")
+		fmt.Println()
+
+		cmd.Usage()
+		return
+	}
+
+	if rpcClient != nil {
+		printBlock(fetchBlock(blockHash))
+		return
+	}
+
+	block, err := bc.GetBlock(hash)
+	if err != nil {
+		fmt.Println("Block not found")
+		return
+	}
+
+	printBlock(&block)
+}
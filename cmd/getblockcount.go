@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+var getBlockCountCmd = &cobra.Command{
+	Use:     "getblockcount",
+	Short:   "Get the height of the tip of the chain",
+	Run:     getBlockCount,
+	Args:    cobra.ExactArgs(0),
+	PreRun:  rpcPreRun,
+	PostRun: cmdPostRun,
+}
+
+func init() {
+	rootCmd.AddCommand(getBlockCountCmd)
+}
+
+// Get the height of the tip of the chain.
+func getBlockCount(_ *cobra.Command, _ []string) {
+	if rpcClient != nil {
+		var height int
+
+		err := rpcClient.Call("getblockcount", &height)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		fmt.Println(height)
+		return
+	}
+
+	fmt.Println(bc.GetBestHeight())
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/danmrichards/yagocoin/crypto"
+	"github.com/spf13/cobra"
+)
+
+var getHistoryCmd = &cobra.Command{
+	Use:     "gethistory",
+	Short:   "Get the confirmed transaction history of an address",
+	Run:     getHistory,
+	Args:    cobra.ExactArgs(0),
+	PreRun:  cmdPreRun,
+	PostRun: cmdPostRun,
+}
+
+func init() {
+	getHistoryCmd.Flags().StringVarP(&address, "address", "a", "", "Address to get the history of")
+	rootCmd.AddCommand(getHistoryCmd)
+}
+
+// Get the confirmed transaction history of an address.
+func getHistory(cmd *cobra.Command, _ []string) {
+	// Validate the address argument.
+	if address == "" {
+		fmt.Printf("Invalid or missing address\n")
+		fmt.Println()
+
+		cmd.Usage()
+		return
+	}
+
+	// Validate the address.
+	if !crypto.ValidateAddress(address) {
+		log.Panic("ERROR: Address is not valid")
+	}
+
+	history, err := bc.AddressHistory(crypto.GetPublicKeyHash([]byte(address)))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Printf("History of '%s':\n", address)
+	printHistory(history)
+}
+
+// printHistory prints the entries of an address history, one per line.
+func printHistory(history []crypto.AddressHistoryEntry) {
+	for _, entry := range history {
+		sign := "+"
+		if entry.Direction == crypto.Sent {
+			sign = "-"
+		}
+
+		fmt.Printf("%6d  %x  %s%d\n", entry.Height, entry.TxID, sign, entry.Value)
+	}
+}
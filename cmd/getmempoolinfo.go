@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/danmrichards/yagocoin/server"
+	"github.com/spf13/cobra"
+)
+
+var getMempoolInfoCmd = &cobra.Command{
+	Use:    "getmempoolinfo",
+	Short:  "Get the state of the mempool of a running node",
+	Run:    getMempoolInfo,
+	Args:   cobra.ExactArgs(0),
+	PreRun: rpcOnlyPreRun,
+}
+
+func init() {
+	rootCmd.AddCommand(getMempoolInfoCmd)
+}
+
+// Get the state of the mempool of a running node.
+func getMempoolInfo(_ *cobra.Command, _ []string) {
+	var result server.MempoolInfoResult
+
+	err := rpcClient.Call("getmempoolinfo", &result)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("Transactions: %d\n", result.Size)
+	fmt.Printf("Bytes: %d\n", result.Bytes)
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/danmrichards/yagocoin/crypto"
+	"github.com/spf13/cobra"
+)
+
+var getPubKeyCmd = &cobra.Command{
+	Use:   "getpubkey",
+	Short: "Get the public key of an address in the wallet file",
+	Long: `Get the public key of an address in the wallet file, hex encoded.
+
+Public keys, unlike addresses, can be combined into a multisig address with
+createmultisig.`,
+	Run:     getPubKey,
+	Args:    cobra.ExactArgs(0),
+	PreRun:  cmdPreRun,
+	PostRun: cmdPostRun,
+}
+
+func init() {
+	getPubKeyCmd.Flags().StringVarP(&address, "address", "a", "", "Address to get the public key of")
+	rootCmd.AddCommand(getPubKeyCmd)
+}
+
+// Get the public key of an address in the wallet file.
+func getPubKey(cmd *cobra.Command, _ []string) {
+	// Validate the address argument.
+	if address == "" {
+		fmt.Printf("Invalid or missing address\n")
+		fmt.Println()
+
+		cmd.Usage()
+		return
+	}
+
+	wallet, err := loadWallet(address)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Printf("%x\n", wallet.PublicKey)
+}
+
+// loadWallet returns the wallet of an address from the wallet file.
+func loadWallet(address string) (*crypto.Wallet, error) {
+	wallets, err := crypto.NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	wallet, ok := wallets.Wallets[address]
+	if !ok {
+		return nil, fmt.Errorf("address '%s' is not in the wallet file", address)
+	}
+
+	return wallet, nil
+}
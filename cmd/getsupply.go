@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/danmrichards/yagocoin/crypto"
+	"github.com/spf13/cobra"
+)
+
+var getSupplyCmd = &cobra.Command{
+	Use:     "getsupply",
+	Short:   "Get the circulating supply of coins at the tip of the chain",
+	Run:     getSupply,
+	Args:    cobra.ExactArgs(0),
+	PreRun:  rpcPreRun,
+	PostRun: cmdPostRun,
+}
+
+func init() {
+	rootCmd.AddCommand(getSupplyCmd)
+}
+
+// Get the circulating supply of coins at the tip of the chain.
+func getSupply(_ *cobra.Command, _ []string) {
+	var height int
+
+	if rpcClient != nil {
+		err := rpcClient.Call("getblockcount", &height)
+		if err != nil {
+			log.Panic(err)
+		}
+	} else {
+		height = bc.GetBestHeight()
+	}
+
+	fmt.Printf("Height: %d\n", height)
+	fmt.Printf("Block subsidy: %d\n", crypto.Emission.Subsidy(height))
+	fmt.Printf("Circulating supply: %d\n", crypto.Emission.Supply(height))
+	fmt.Printf("Maximum supply: %d\n", crypto.Emission.MaxSupply())
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+
+	"github.com/danmrichards/yagocoin/crypto"
+	"github.com/danmrichards/yagocoin/server"
+	"github.com/spf13/cobra"
+)
+
+var (
+	txID string
+
+	getTransactionCmd = &cobra.Command{
+		Use:     "gettransaction",
+		Short:   "Print the transaction with the given ID",
+		Run:     getTransaction,
+		Args:    cobra.ExactArgs(0),
+		PreRun:  rpcPreRun,
+		PostRun: cmdPostRun,
+	}
+)
+
+func init() {
+	getTransactionCmd.Flags().StringVar(&txID, "txid", "", "ID of the transaction")
+	rootCmd.AddCommand(getTransactionCmd)
+}
+
+// Print the transaction with the given ID.
+func getTransaction(cmd *cobra.Command, _ []string) {
+	id, err := hex.DecodeString(txID)
+	if txID == "" || err != nil {
+		fmt.Printf("Invalid or missing transaction ID\n")
+		fmt.Println()
+
+		cmd.Usage()
+		return
+	}
+
+	if rpcClient != nil {
+		var result server.TransactionResult
+
+		err := rpcClient.Call("gettransaction", &result, txID)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		data, err := hex.DecodeString(result.Hex)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		tx, err := crypto.DeserializeTransaction(data)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		fmt.Printf("Confirmed: %t\n", result.Confirmed)
+		fmt.Println(tx)
+		return
+	}
+
+	tx, err := bc.FindTransaction(id)
+	if err != nil {
+		fmt.Println("Transaction not found")
+		return
+	}
+
+	fmt.Println(tx)
+}
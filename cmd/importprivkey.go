@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/danmrichards/yagocoin/crypto"
+	"github.com/spf13/cobra"
+)
+
+var (
+	privateKey string
+
+	importPrivKeyCmd = &cobra.Command{
+		Use:   "importprivkey",
+		Short: "Add a private key printed by dumpprivkey to the wallet file",
+		Long: `Add a private key printed by dumpprivkey to the wallet file.
+
+The UTXO set is then scanned for the coins of its address, and the address
+index, if it has been built, for its transactions. An imported key isn't
+derived from the mnemonic of the wallet file, so back it up separately.`,
+		Run:     importPrivKey,
+		Args:    cobra.ExactArgs(0),
+		PreRun:  cmdPreRun,
+		PostRun: cmdPostRun,
+	}
+)
+
+func init() {
+	importPrivKeyCmd.Flags().StringVar(&privateKey, "key", "", "Private key to import, asked for if not given"+secretFlagWarning)
+	importPrivKeyCmd.Flags().StringVar(&passphrase, "passphrase", "", passphraseUsage)
+	rootCmd.AddCommand(importPrivKeyCmd)
+}
+
+// Add a private key to the wallet file.
+func importPrivKey(_ *cobra.Command, _ []string) {
+	wallets, err := importingWallets()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if privateKey == "" {
+		privateKey = readPassphrase("Private key: ")
+	}
+
+	wallet, err := crypto.DecodePrivateKey(privateKey)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	imported, err := wallets.ImportWallet(wallet)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	wallets.SaveToFile(nodeID)
+
+	fmt.Printf("Imported %s\n", imported)
+	rescanAddresses([]string{imported})
+}
+
+// importingWallets returns the wallet file, unlocked so keys can be imported
+// into it.
+func importingWallets() (*crypto.Wallets, error) {
+	wallets, err := crypto.NewWallets(nodeID)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("there is no wallet file, create one with createwallet")
+	} else if err != nil {
+		log.Panic(err)
+	}
+
+	err = unlockWallets(wallets)
+	if err != nil {
+		return nil, err
+	}
+
+	return wallets, nil
+}
+
+// rescanAddresses scans the UTXO set for the coins of imported addresses, and
+// the address index for their transactions.
+func rescanAddresses(addresses []string) {
+	uTxOSet := crypto.UTxOSet{Blockchain: bc}
+
+	// Without the address index, only the coins still held can be seen.
+	indexed := bc.AddressIndexEnabled()
+	if !indexed && len(addresses) > 0 {
+		fmt.Println("The address index hasn't been built, so past transactions of imported")
+		fmt.Println("addresses can't be found. Build it with reindex --addresses to find them.")
+	}
+
+	for _, imported := range addresses {
+		pubKeyHash := crypto.GetPublicKeyHash([]byte(imported))
+
+		address = imported
+		printBalance(uTxOSet.GetBalance(pubKeyHash))
+
+		if !indexed {
+			continue
+		}
+
+		history, err := bc.AddressHistory(pubKeyHash)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		fmt.Printf("History of '%s':\n", imported)
+		printHistory(history)
+	}
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/danmrichards/yagocoin/crypto"
+	"github.com/spf13/cobra"
+)
+
+var importWalletCmd = &cobra.Command{
+	Use:   "importwallet",
+	Short: "Add the private keys of a file written by dumpwallet to the wallet file",
+	Long: `Add the private keys of a file written by dumpwallet to the wallet file.
+
+Keys already in the wallet file are skipped. The UTXO set is then scanned for
+the coins of each imported address, and the address index, if it has been
+built, for their transactions. The mnemonic in the file isn't imported, use
+restorewallet for that.`,
+	Run:     importWallet,
+	Args:    cobra.ExactArgs(0),
+	PreRun:  cmdPreRun,
+	PostRun: cmdPostRun,
+}
+
+func init() {
+	importWalletCmd.Flags().StringVar(&dumpFile, "file", "", "File written by dumpwallet to read the keys from")
+	importWalletCmd.Flags().StringVar(&passphrase, "passphrase", "", passphraseUsage)
+	rootCmd.AddCommand(importWalletCmd)
+}
+
+// Add the private keys of a dump file to the wallet file.
+func importWallet(cmd *cobra.Command, _ []string) {
+	// Validate the file argument.
+	if dumpFile == "" {
+		fmt.Printf("Missing file\n")
+		fmt.Println()
+
+		cmd.Usage()
+		return
+	}
+
+	keys, err := readDumpFile(dumpFile)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	wallets, err := importingWallets()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	var imported []string
+	for _, wallet := range keys {
+		address, err := wallets.ImportWallet(wallet)
+		if err == crypto.ErrKeyExists {
+			continue
+		} else if err != nil {
+			log.Panic(err)
+		}
+
+		imported = append(imported, address)
+	}
+	wallets.SaveToFile(nodeID)
+
+	fmt.Printf("Imported %d of %d keys\n", len(imported), len(keys))
+	rescanAddresses(imported)
+}
+
+// readDumpFile returns the wallets of the private keys in a file written by
+// dumpwallet. Nothing is returned if any key is invalid.
+func readDumpFile(name string) ([]*crypto.Wallet, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var wallets []*crypto.Wallet
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		wallet, err := crypto.DecodePrivateKey(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+
+		// The address is only there to read, but a mismatch means the file is damaged.
+		if len(fields) > 1 && fields[1] != string(wallet.GetAddress()) {
+			return nil, fmt.Errorf("line %d: address doesn't match the private key", line)
+		}
+
+		wallets = append(wallets, wallet)
+	}
+
+	return wallets, scanner.Err()
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/danmrichards/yagocoin/crypto"
+	"github.com/spf13/cobra"
+)
+
+var migrateDBCmd = &cobra.Command{
+	Use:   "migratedb",
+	Short: "Upgrades a blockchain to the current storage format",
+	Run:   migrateDB,
+	Args:  cobra.ExactArgs(0),
+}
+
+func init() {
+	rootCmd.AddCommand(migrateDBCmd)
+}
+
+// Upgrades a blockchain to the current storage format.
+func migrateDB(_ *cobra.Command, _ []string) {
+	nodeID = os.Getenv("NODE_ID")
+	if nodeID == "" {
+		fmt.Printf("NODE_ID env. var is not set!")
+		os.Exit(1)
+	}
+
+	if err := crypto.MigrateDB(nodeID); err != nil {
+		fmt.Printf("Could not migrate blockchain: %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Done!")
+}
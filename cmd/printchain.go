@@ -1,48 +1,142 @@
 package cmd
 
 import (
+	"encoding/hex"
 	"fmt"
+	"log"
 	"strconv"
 
 	"github.com/danmrichards/yagocoin/crypto"
+	"github.com/danmrichards/yagocoin/server"
 	"github.com/spf13/cobra"
 )
 
-var printChainCmd = &cobra.Command{
-	Use:     "printchain",
-	Short:   "Print all the blocks of the blockchain",
-	Run:     printChain,
-	Args:    cobra.ExactArgs(0),
-	PreRun:  cmdPreRun,
-	PostRun: cmdPostRun,
-}
+var (
+	printFrom  int
+	printTo    int
+	printLimit int
+
+	printChainCmd = &cobra.Command{
+		Use:     "printchain",
+		Short:   "Print the blocks of the blockchain, newest first",
+		Run:     printChain,
+		Args:    cobra.ExactArgs(0),
+		PreRun:  rpcPreRun,
+		PostRun: cmdPostRun,
+	}
+)
 
 func init() {
+	printChainCmd.Flags().IntVar(&printFrom, "from", 0, "Lowest height to print")
+	printChainCmd.Flags().IntVar(&printTo, "to", -1, "Highest height to print, defaults to the tip")
+	printChainCmd.Flags().IntVar(&printLimit, "limit", 0, "Maximum number of blocks to print, 0 for no limit")
 	rootCmd.AddCommand(printChainCmd)
 }
 
-// Print all the blocks of the blockchain.
+// Print the blocks of the blockchain between two heights, newest first.
 func printChain(_ *cobra.Command, _ []string) {
-	bci := bc.Iterator()
+	to := printTo
+	if tip := bestHeight(); to < 0 || to > tip {
+		to = tip
+	}
 
-	for {
-		block := bci.Next()
+	for height, printed := to, 0; height >= printFrom; height-- {
+		if printLimit > 0 && printed == printLimit {
+			break
+		}
 
-		fmt.Printf("============ Block %x ============\n", block.Hash)
-		fmt.Printf("Height: %d\n", block.Height)
-		fmt.Printf("Prev. block: %x\n", block.PrevBlockHash)
+		block := blockAtHeight(height)
+		if block == nil {
+			log.Panicf("ERROR: Block at height %d not found", height)
+		}
 
-		pow := crypto.NewProof(block)
-		fmt.Printf("PoW: %s\n\n", strconv.FormatBool(pow.Validate()))
+		printBlock(block)
+		printed++
+	}
+}
+
+// bestHeight returns the height of the tip of the main chain.
+func bestHeight() int {
+	if rpcClient == nil {
+		return bc.GetBestHeight()
+	}
+
+	var height int
+
+	err := rpcClient.Call("getblockcount", &height)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return height
+}
 
-		for _, tx := range block.Transactions {
-			fmt.Println(tx)
+// blockAtHeight returns the block at a height of the main chain, or nil if
+// there is no such block.
+func blockAtHeight(height int) *crypto.Block {
+	if rpcClient == nil {
+		block, err := bc.GetBlockByHeight(height)
+		if err != nil {
+			return nil
 		}
 
-		fmt.Printf("\n\n")
+		return &block
+	}
 
-		if len(block.PrevBlockHash) == 0 {
-			break
+	var hash string
+
+	err := rpcClient.Call("getblockhash", &hash, height)
+	if err != nil {
+		// The node answers with an RPC error if the height is out of range.
+		if _, ok := err.(*server.RPCError); ok {
+			return nil
 		}
+
+		log.Panic(err)
+	}
+
+	return fetchBlock(hash)
+}
+
+// fetchBlock gets the block with the given hash from a running node.
+func fetchBlock(hash string) *crypto.Block {
+	var result server.BlockResult
+
+	err := rpcClient.Call("getblock", &result, hash)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	data, err := hex.DecodeString(result.Hex)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	block, err := crypto.DeserializeBlock(data)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return block
+}
+
+// printBlock prints a block and its transactions. The proof-of-work can only
+// be checked when we have the blockchain db open.
+func printBlock(block *crypto.Block) {
+	fmt.Printf("============ Block %x ============\n", block.Hash)
+	fmt.Printf("Height: %d\n", block.Height)
+	fmt.Printf("Prev. block: %x\n", block.PrevBlockHash)
+	fmt.Printf("Bits: %08x\n", block.Bits)
+
+	if bc != nil {
+		pow := crypto.NewProof(block)
+		fmt.Printf("PoW: %s\n", strconv.FormatBool(pow.Validate(bc)))
 	}
+	fmt.Println()
+
+	for _, tx := range block.Transactions {
+		fmt.Println(tx)
+	}
+
+	fmt.Printf("\n\n")
 }
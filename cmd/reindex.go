@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	reindexAddresses bool
+
+	reindexCmd = &cobra.Command{
+		Use:     "reindex",
+		Short:   "Rebuilds the transaction and height indexes, and optionally the address index",
+		Run:     reindex,
+		Args:    cobra.ExactArgs(0),
+		PreRun:  cmdPreRun,
+		PostRun: cmdPostRun,
+	}
+)
+
+func init() {
+	reindexCmd.Flags().BoolVar(&reindexAddresses, "addresses", false, "Build the address index, which is kept up to date from then on")
+	rootCmd.AddCommand(reindexCmd)
+}
+
+// Rebuilds the transaction and height indexes, and optionally the address index.
+func reindex(_ *cobra.Command, _ []string) {
+	count := bc.ReindexTransactions()
+	fmt.Printf("Done! There are %d transactions in the index.\n", count)
+
+	count = bc.ReindexHeights()
+	fmt.Printf("Done! There are %d blocks in the height index.\n", count)
+
+	if reindexAddresses || bc.AddressIndexEnabled() {
+		count = bc.ReindexAddresses()
+		fmt.Printf("Done! There are %d entries in the address index.\n", count)
+	}
+}
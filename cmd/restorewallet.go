@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/danmrichards/yagocoin/crypto"
+	"github.com/spf13/cobra"
+)
+
+var (
+	mnemonic string
+
+	restoreWalletCmd = &cobra.Command{
+		Use:   "restorewallet",
+		Short: "Rebuild the wallet file from its mnemonic",
+		Long: fmt.Sprintf(`Rebuild the wallet file from its mnemonic.
+
+The keys of the receive and change chains are derived in turn, and each chain
+is scanned until %d addresses in a row have never been paid to. Every address
+up to the last used one is added to a new wallet file, which is unencrypted
+until encryptwallet is run.
+
+Addresses whose coins have all been spent are only found if the address index
+has been built, with reindex --addresses.`, crypto.GapLimit),
+		Run:     restoreWallet,
+		Args:    cobra.ExactArgs(0),
+		PreRun:  cmdPreRun,
+		PostRun: cmdPostRun,
+	}
+)
+
+func init() {
+	restoreWalletCmd.Flags().StringVar(&mnemonic, "mnemonic", "", "Mnemonic of the wallet file, asked for if not given"+secretFlagWarning)
+	rootCmd.AddCommand(restoreWalletCmd)
+}
+
+// Rebuild the wallet file from its mnemonic.
+func restoreWallet(_ *cobra.Command, _ []string) {
+	wallets, err := crypto.NewWallets(nodeID)
+	if err == nil {
+		fmt.Println("The wallet file already exists, move it out of the way first")
+		return
+	} else if !os.IsNotExist(err) {
+		log.Panic(err)
+	}
+
+	if mnemonic == "" {
+		mnemonic = readPassphrase("Mnemonic: ")
+	}
+
+	err = wallets.SetMnemonic(mnemonic)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// Without the address index, only addresses holding coins can be seen.
+	uTxOSet := crypto.UTxOSet{Blockchain: bc}
+	indexed := bc.AddressIndexEnabled()
+	if !indexed {
+		fmt.Println("The address index hasn't been built, so addresses whose coins were all spent")
+		fmt.Println("will be missed. Build it with reindex --addresses to find them.")
+	}
+
+	found, err := wallets.Rescan(func(pubKeyHash []byte) bool {
+		if !indexed {
+			balance, immature := uTxOSet.GetBalance(pubKeyHash)
+
+			return balance > 0 || immature > 0
+		}
+
+		history, err := bc.AddressHistory(pubKeyHash)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		return len(history) > 0
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Have an address to receive coins with.
+	if len(wallets.Wallets) == 0 {
+		_, err = wallets.CreateWallet()
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+	wallets.SaveToFile(nodeID)
+
+	fmt.Printf("Found %d used addresses\n", found)
+	for _, address := range wallets.GetAddresses() {
+		fmt.Println(address)
+	}
+}
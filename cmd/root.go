@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/danmrichards/yagocoin/chaincfg"
 	"github.com/danmrichards/yagocoin/crypto"
+	"github.com/danmrichards/yagocoin/server"
 	"github.com/spf13/cobra"
 )
 
@@ -15,6 +17,15 @@ var (
 
 	nodeID string
 
+	// Name of the network to use.
+	network string
+
+	// Settings for talking to a running node over JSON-RPC.
+	rpcConnect  string
+	rpcUser     string
+	rpcPassword string
+	rpcClient   *server.RPCClient
+
 	rootCmd = &cobra.Command{
 		Use:   "yagocoin",
 		Short: "Yet Another Go Coin",
@@ -26,22 +37,71 @@ Based on a simple blockchain as described at https://jeiwan.cc`,
 	}
 )
 
+func init() {
+	cobra.OnInitialize(setNetwork)
+
+	rootCmd.PersistentFlags().StringVar(&network, "network", chaincfg.MainNetParams.Name, "Network to use: mainnet, testnet or regtest")
+	rootCmd.PersistentFlags().StringVar(&rpcConnect, "rpcconnect", "", "Address of a running node to send RPC commands to, instead of opening the database")
+	rootCmd.PersistentFlags().StringVar(&rpcUser, "rpcuser", "", "User for JSON-RPC connections")
+	rootCmd.PersistentFlags().StringVar(&rpcPassword, "rpcpassword", "", "Password for JSON-RPC connections")
+}
+
 func Execute() error {
 	return rootCmd.Execute()
 }
 
-func cmdPreRun(_ *cobra.Command, _ []string) {
+// setNetwork switches to the network chosen with --network.
+func setNetwork() {
+	params, err := chaincfg.ParamsForName(network)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	crypto.SetNetwork(params)
+	server.KnownNodes = append([]string{}, params.SeedNodes...)
+}
+
+func cmdPreRun(cmd *cobra.Command, args []string) {
+	walletPreRun(cmd, args)
+
+	// Open the connection to the blockchain db.
+	bc = crypto.NewBlockchain(nodeID)
+}
+
+// walletPreRun prepares a command which only uses the wallet file.
+func walletPreRun(_ *cobra.Command, _ []string) {
 	nodeID = os.Getenv("NODE_ID")
 	if nodeID == "" {
 		fmt.Printf("NODE_ID env. var is not set!")
 		os.Exit(1)
 	}
+}
 
-	// Open the connection to the blockchain db.
-	bc = crypto.NewBlockchain(nodeID)
+// rpcPreRun prepares a command which can either talk to a running node, if
+// --rpcconnect is given, or open the blockchain db itself.
+func rpcPreRun(cmd *cobra.Command, args []string) {
+	if rpcConnect == "" {
+		cmdPreRun(cmd, args)
+		return
+	}
+
+	rpcClient = server.NewRPCClient(rpcConnect, rpcUser, rpcPassword)
+}
+
+// rpcOnlyPreRun prepares a command which needs a running node.
+func rpcOnlyPreRun(_ *cobra.Command, _ []string) {
+	if rpcConnect == "" {
+		fmt.Println("This command needs a running node, set one with --rpcconnect")
+		os.Exit(1)
+	}
+
+	rpcClient = server.NewRPCClient(rpcConnect, rpcUser, rpcPassword)
 }
 
 func cmdPostRun(_ *cobra.Command, _ []string) {
 	// Close the connection to the blockchain db.
-	bc.Close()
+	if bc != nil {
+		bc.Close()
+	}
 }
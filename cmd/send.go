@@ -1,8 +1,10 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/danmrichards/yagocoin/crypto"
 	"github.com/danmrichards/yagocoin/server"
@@ -12,14 +14,20 @@ import (
 var (
 	from, to string
 	amount   int
+	fee      int
 	mineNow  bool
 
+	// Lock times of the transaction and of each of its inputs.
+	lockTime       uint32
+	relativeBlocks int
+	relativeTime   time.Duration
+
 	sendCmd = &cobra.Command{
 		Use:     "send",
 		Short:   "Send an amount of coins from one address to another",
 		Run:     send,
 		Args:    cobra.ExactArgs(0),
-		PreRun:  cmdPreRun,
+		PreRun:  rpcPreRun,
 		PostRun: cmdPostRun,
 	}
 )
@@ -28,7 +36,13 @@ func init() {
 	sendCmd.Flags().StringVarP(&from, "from", "f", "", "Address to send the coins from")
 	sendCmd.Flags().StringVarP(&to, "to", "t", "", "Address to send the coins to")
 	sendCmd.Flags().IntVarP(&amount, "amount", "a", 0, "Amount of coins to send")
+	sendCmd.Flags().IntVar(&fee, "fee", 0, "Fee to pay the miner of the transaction")
 	sendCmd.Flags().BoolVarP(&mineNow, "mine", "m", false, "Mine immediately on the same node")
+	sendCmd.Flags().Uint32Var(&lockTime, "locktime", 0, "Block height, or Unix time if 500000000 or more, the transaction can't be mined until after")
+	sendCmd.Flags().IntVar(&relativeBlocks, "relative-blocks", 0, "Number of blocks the spent outputs must be buried under before the transaction can be mined")
+	sendCmd.Flags().DurationVar(&relativeTime, "relative-time", 0, "Time which must pass after the spent outputs were mined before the transaction can be mined")
+	sendCmd.Flags().IntVar(&crypto.MinerThreads, "threads", crypto.MinerThreads, "Number of goroutines to mine with")
+	sendCmd.Flags().StringVar(&passphrase, "passphrase", "", passphraseUsage)
 	rootCmd.AddCommand(sendCmd)
 }
 
@@ -61,22 +75,107 @@ func send(cmd *cobra.Command, _ []string) {
 		return
 	}
 
+	// Validate the fee.
+	if fee < 0 {
+		fmt.Printf("Invalid fee\n")
+		fmt.Println()
+
+		cmd.Usage()
+		return
+	}
+
+	// Validate the relative lock time.
+	sequence := crypto.SequenceFinal
+	switch {
+	case relativeBlocks < 0 || relativeBlocks > crypto.MaxRelativeLockBlocks:
+		fmt.Printf("Invalid relative lock, it must be from 0 to %d blocks\n", crypto.MaxRelativeLockBlocks)
+		return
+	case relativeTime < 0 || relativeTime > crypto.MaxRelativeLockTime:
+		fmt.Printf("Invalid relative lock, it must be from 0 to %s\n", crypto.MaxRelativeLockTime)
+		return
+	case relativeBlocks > 0 && relativeTime > 0:
+		fmt.Printf("Relative locks can be in blocks or time, not both\n")
+		return
+	case relativeBlocks > 0:
+		sequence = crypto.RelativeLockBlocks(relativeBlocks)
+	case relativeTime > 0:
+		sequence = crypto.RelativeLockTime(relativeTime)
+	case lockTime > 0:
+		// The lock time only applies if an input isn't final.
+		sequence = crypto.SequenceFinal - 1
+	}
+
+	// A running node signs with its own wallet file, unlocked with
+	// walletpassphrase if it is encrypted.
+	if rpcClient != nil {
+		if mineNow || lockTime > 0 || sequence != crypto.SequenceFinal {
+			fmt.Println("Mining and lock times can't be used with --rpcconnect")
+			return
+		}
+
+		var txID string
+
+		err := rpcClient.Call("sendtoaddress", &txID, from, to, amount, fee)
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			return
+		}
+
+		fmt.Printf("Success! Transaction %s\n", txID)
+		return
+	}
+
+	wallets, err := signingWallets(from)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	wallet, err := wallets.GetWallet(from)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// HD wallet files send change to a new address of the change chain.
+	var change string
+	if wallets.IsHD() {
+		change, err = wallets.NewChangeAddress()
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+
 	uTxOSet := crypto.UTxOSet{bc}
 
-	wallets, err := crypto.NewWallets(nodeID)
+	tx, err := crypto.NewLockedUTxOTransaction(&wallet, to, change, amount, fee, lockTime, sequence, &uTxOSet)
 	if err != nil {
-		log.Panic(err)
+		fmt.Printf("ERROR: %s\n", err)
+		return
 	}
-	wallet := wallets.GetWallet(from)
 
-	tx := crypto.NewUTxOTransaction(&wallet, to, amount, &uTxOSet)
+	// Keep the change address, unless there was no change.
+	if change != "" && len(tx.Vout) > 1 {
+		wallets.SaveToFile(nodeID)
+	}
+
+	// Nodes won't take transactions which can't be mined yet, so leave it to
+	// the sender to broadcast later.
+	if err := bc.CheckLocks(tx); err != nil {
+		fmt.Printf("The transaction can't be mined yet: %s\n", err)
+		fmt.Println("Once it can, send it with sendrawtransaction:")
+		fmt.Printf("%x\n", tx.Serialize())
+		return
+	}
 
 	if mineNow {
-		cbTx := crypto.NewCoinbaseTx(from, "")
+		cbTx := crypto.NewCoinbaseTx(from, "", bc.GetBestHeight()+1, fee)
 		txs := []*crypto.Transaction{cbTx, tx}
 
-		newBlock := bc.MineBlock(txs)
-		uTxOSet.Update(newBlock)
+		_, err := bc.MineBlock(context.Background(), txs)
+		if err != nil {
+			log.Panic(err)
+		}
 	} else {
 		server.SendTx(server.KnownNodes[0], tx)
 	}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	txHex string
+
+	sendRawTransactionCmd = &cobra.Command{
+		Use:    "sendrawtransaction",
+		Short:  "Send a hex encoded transaction to a running node",
+		Run:    sendRawTransaction,
+		Args:   cobra.ExactArgs(0),
+		PreRun: rpcOnlyPreRun,
+	}
+)
+
+func init() {
+	sendRawTransactionCmd.Flags().StringVar(&txHex, "hex", "", "Hex encoded transaction")
+	rootCmd.AddCommand(sendRawTransactionCmd)
+}
+
+// Send a hex encoded transaction to a running node.
+func sendRawTransaction(cmd *cobra.Command, _ []string) {
+	if txHex == "" {
+		fmt.Printf("Missing transaction\n")
+		fmt.Println()
+
+		cmd.Usage()
+		return
+	}
+
+	var id string
+
+	err := rpcClient.Call("sendrawtransaction", &id, txHex)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println(id)
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/danmrichards/yagocoin/crypto"
+	"github.com/danmrichards/yagocoin/server"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sendWhenComplete bool
+
+	signMultiSigCmd = &cobra.Command{
+		Use:   "signmultisig",
+		Short: "Add a signature to a transaction spending from a multisig address",
+		Long: `Add a signature to a transaction spending from a multisig address.
+
+The transaction is given hex encoded, as printed by spendmultisig or by an
+earlier signmultisig, and printed again with the signature of the from
+address added. Once it has enough signatures it can be broadcast, either with
+--send or with sendrawtransaction.`,
+		Run:     signMultiSig,
+		Args:    cobra.ExactArgs(0),
+		PreRun:  cmdPreRun,
+		PostRun: cmdPostRun,
+	}
+)
+
+func init() {
+	signMultiSigCmd.Flags().StringVar(&txHex, "hex", "", "Hex encoded transaction")
+	signMultiSigCmd.Flags().StringVarP(&from, "from", "f", "", "Wallet address to sign with")
+	signMultiSigCmd.Flags().BoolVar(&sendWhenComplete, "send", false, "Broadcast the transaction if it has enough signatures")
+	signMultiSigCmd.Flags().StringVar(&passphrase, "passphrase", "", passphraseUsage)
+	rootCmd.AddCommand(signMultiSigCmd)
+}
+
+// Add a signature to a transaction spending from a multisig address.
+func signMultiSig(cmd *cobra.Command, _ []string) {
+	data, err := hex.DecodeString(txHex)
+	if err != nil || len(data) == 0 {
+		fmt.Printf("Invalid or missing transaction\n")
+		fmt.Println()
+
+		cmd.Usage()
+		return
+	}
+
+	tx, err := crypto.DeserializeTransaction(data)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	wallet, err := signingWallet(from)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if bc.SignMultiSigTransaction(&tx, wallet.PrivateKey) == 0 {
+		fmt.Printf("No signatures were added by '%s'\n", from)
+	}
+
+	fmt.Printf("%x\n", tx.Serialize())
+
+	complete := bc.VerifyTransaction(&tx)
+	fmt.Printf("Complete: %t\n", complete)
+
+	if sendWhenComplete {
+		if !complete {
+			fmt.Println("ERROR: Transaction needs more signatures before it can be sent")
+			return
+		}
+
+		server.SendTx(server.KnownNodes[0], &tx)
+		fmt.Println("Success!")
+	}
+}
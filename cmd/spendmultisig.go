@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/danmrichards/yagocoin/crypto"
+	"github.com/spf13/cobra"
+)
+
+var (
+	redeemScriptHex string
+
+	spendMultiSigCmd = &cobra.Command{
+		Use:   "spendmultisig",
+		Short: "Create an unsigned transaction spending from a multisig address",
+		Long: `Create an unsigned transaction spending from a multisig address.
+
+The transaction is printed hex encoded, for the holders of the keys to add
+their signatures to in turn with signmultisig. Change goes back to the
+multisig address.`,
+		Run:     spendMultiSig,
+		Args:    cobra.ExactArgs(0),
+		PreRun:  cmdPreRun,
+		PostRun: cmdPostRun,
+	}
+)
+
+func init() {
+	spendMultiSigCmd.Flags().StringVar(&redeemScriptHex, "redeemscript", "", "Hex encoded redeem script of the multisig address")
+	spendMultiSigCmd.Flags().StringVarP(&to, "to", "t", "", "Address to send the coins to")
+	spendMultiSigCmd.Flags().IntVarP(&amount, "amount", "a", 0, "Amount of coins to send")
+	spendMultiSigCmd.Flags().IntVar(&fee, "fee", 0, "Fee to pay the miner of the transaction")
+	rootCmd.AddCommand(spendMultiSigCmd)
+}
+
+// Create an unsigned transaction spending from a multisig address.
+func spendMultiSig(cmd *cobra.Command, _ []string) {
+	redeemScript, err := hex.DecodeString(redeemScriptHex)
+	if err != nil || len(redeemScript) == 0 {
+		fmt.Printf("Invalid or missing redeem script\n")
+		fmt.Println()
+
+		cmd.Usage()
+		return
+	}
+
+	// Validate the to adress.
+	if !crypto.ValidateAddress(to) {
+		fmt.Printf("Invalid or missing to address\n")
+		fmt.Println()
+
+		cmd.Usage()
+		return
+	}
+
+	// Validate the amount and fee.
+	if amount <= 0 || fee < 0 {
+		fmt.Printf("Invalid or missing amount\n")
+		fmt.Println()
+
+		cmd.Usage()
+		return
+	}
+
+	uTxOSet := crypto.UTxOSet{Blockchain: bc}
+
+	tx, err := crypto.NewMultiSigTransaction(redeemScript, to, amount, fee, &uTxOSet)
+	if err != nil {
+		fmt.Printf("ERROR: %s\n", err)
+		return
+	}
+
+	fmt.Printf("%x\n", tx.Serialize())
+}
@@ -12,6 +12,8 @@ import (
 
 var (
 	minerAddress string
+	rpcListen    string
+	explorerAddr string
 
 	startNodeCmd = &cobra.Command{
 		Use:   "startnode",
@@ -23,6 +25,9 @@ var (
 
 func init() {
 	startNodeCmd.Flags().StringVarP(&minerAddress, "miner", "m", "", "Enable mining mode and send reward to address")
+	startNodeCmd.Flags().StringVar(&rpcListen, "rpclisten", "", "Address to serve JSON-RPC on, needs --rpcuser and --rpcpassword")
+	startNodeCmd.Flags().StringVar(&explorerAddr, "explorer", "", "Address to serve the block explorer on")
+	startNodeCmd.Flags().IntVar(&crypto.MinerThreads, "threads", crypto.MinerThreads, "Number of goroutines to mine with")
 	rootCmd.AddCommand(startNodeCmd)
 }
 
@@ -43,5 +48,9 @@ func startNode(_ *cobra.Command, _ []string) {
 		}
 	}
 
-	server.StartServer(nodeID, minerAddress)
+	server.StartServer(nodeID, minerAddress, server.RPCConfig{
+		Addr:     rpcListen,
+		User:     rpcUser,
+		Password: rpcPassword,
+	}, explorerAddr)
 }
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+var stopCmd = &cobra.Command{
+	Use:    "stop",
+	Short:  "Stop a running node",
+	Run:    stop,
+	Args:   cobra.ExactArgs(0),
+	PreRun: rpcOnlyPreRun,
+}
+
+func init() {
+	rootCmd.AddCommand(stopCmd)
+}
+
+// Stop a running node.
+func stop(_ *cobra.Command, _ []string) {
+	var result string
+
+	err := rpcClient.Call("stop", &result)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println(result)
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var walletLockCmd = &cobra.Command{
+	Use:    "walletlock",
+	Short:  "Lock the wallet file of a running node unlocked by walletpassphrase",
+	Run:    walletLock,
+	Args:   cobra.ExactArgs(0),
+	PreRun: rpcOnlyPreRun,
+}
+
+func init() {
+	rootCmd.AddCommand(walletLockCmd)
+}
+
+// Lock the wallet file of a running node.
+func walletLock(_ *cobra.Command, _ []string) {
+	err := rpcClient.Call("walletlock", nil)
+	if err != nil {
+		fmt.Printf("ERROR: %s\n", err)
+		return
+	}
+
+	fmt.Println("Wallet locked")
+}
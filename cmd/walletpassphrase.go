@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/danmrichards/yagocoin/crypto"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+const (
+	// secretFlagWarning ends the usage of flags taking a secret, which is
+	// better typed at the prompt than given on the command line.
+	secretFlagWarning = ". Unsafe: other users can see it in the process list, and it is kept in the shell history"
+
+	passphraseUsage = "Passphrase of the wallet file, asked for if not given" + secretFlagWarning
+)
+
+var (
+	// Passphrase of an encrypted wallet file.
+	passphrase    string
+	unlockTimeout time.Duration
+
+	stdin = bufio.NewReader(os.Stdin)
+
+	walletPassphraseCmd = &cobra.Command{
+		Use:   "walletpassphrase",
+		Short: "Unlock the encrypted wallet file of a running node for a while",
+		Long: `Unlock the encrypted wallet file of a running node for a while.
+
+Until the timeout passes, or walletlock is run, send --rpcconnect can send from
+the addresses in the wallet file of the node without its passphrase.`,
+		Run:    walletPassphrase,
+		Args:   cobra.ExactArgs(0),
+		PreRun: rpcOnlyPreRun,
+	}
+)
+
+func init() {
+	walletPassphraseCmd.Flags().StringVar(&passphrase, "passphrase", "", passphraseUsage)
+	walletPassphraseCmd.Flags().DurationVar(&unlockTimeout, "timeout", 5*time.Minute, "How long to unlock the wallet file for")
+	rootCmd.AddCommand(walletPassphraseCmd)
+}
+
+// Unlock the encrypted wallet file of a running node for a while.
+func walletPassphrase(cmd *cobra.Command, _ []string) {
+	// Validate the timeout.
+	if unlockTimeout <= 0 {
+		fmt.Printf("Invalid timeout\n")
+		fmt.Println()
+
+		cmd.Usage()
+		return
+	}
+
+	if passphrase == "" {
+		passphrase = readPassphrase("Wallet passphrase: ")
+	}
+
+	// The node counts in whole seconds.
+	seconds := int((unlockTimeout + time.Second - 1) / time.Second)
+
+	err := rpcClient.Call("walletpassphrase", nil, passphrase, seconds)
+	if err != nil {
+		fmt.Printf("ERROR: %s\n", err)
+		return
+	}
+
+	fmt.Printf("Wallet unlocked for %s\n", time.Duration(seconds)*time.Second)
+}
+
+// readPassphrase asks for a passphrase, or another secret, without echoing it
+// on the terminal. Input which isn't a terminal is read a line at a time.
+func readPassphrase(prompt string) string {
+	fmt.Print(prompt)
+
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		secret, err := term.ReadPassword(fd)
+		fmt.Println()
+		if err != nil {
+			log.Panic(err)
+		}
+
+		return string(secret)
+	}
+
+	line, err := stdin.ReadString('\n')
+	if err != nil && err != io.EOF {
+		log.Panic(err)
+	}
+
+	return strings.TrimRight(line, "\r\n")
+}
+
+// readNewPassphrase asks for a new passphrase twice, to guard against typos.
+func readNewPassphrase() (string, error) {
+	newPassphrase := readPassphrase("New wallet passphrase: ")
+	if readPassphrase("Repeat the new passphrase: ") != newPassphrase {
+		return "", fmt.Errorf("passphrases don't match")
+	}
+
+	return newPassphrase, nil
+}
+
+// unlockWallets unlocks an encrypted wallet file so its wallets can sign, with
+// --passphrase or else by asking for it.
+func unlockWallets(wallets *crypto.Wallets) error {
+	if !wallets.IsLocked() {
+		return nil
+	}
+
+	if passphrase == "" {
+		passphrase = readPassphrase("Wallet passphrase: ")
+	}
+
+	return wallets.Unlock([]byte(passphrase))
+}
+
+// signingWallets returns the wallet file, unlocked so the wallet of an
+// address can sign.
+func signingWallets(address string) (*crypto.Wallets, error) {
+	wallets, err := crypto.NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if _, ok := wallets.Wallets[address]; ok {
+		err = unlockWallets(wallets)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return wallets, nil
+}
+
+// signingWallet returns the wallet of an address from the wallet file,
+// unlocked so it can sign.
+func signingWallet(address string) (*crypto.Wallet, error) {
+	wallets, err := signingWallets(address)
+	if err != nil {
+		return nil, err
+	}
+
+	wallet, err := wallets.GetWallet(address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &wallet, nil
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/danmrichards/yagocoin/crypto"
+	"github.com/spf13/cobra"
+)
+
+var (
+	newPassphrase string
+
+	walletPassphraseChangeCmd = &cobra.Command{
+		Use:    "walletpassphrasechange",
+		Short:  "Change the passphrase of the encrypted wallet file",
+		Run:    walletPassphraseChange,
+		Args:   cobra.ExactArgs(0),
+		PreRun: walletPreRun,
+	}
+)
+
+func init() {
+	walletPassphraseChangeCmd.Flags().StringVar(&passphrase, "passphrase", "", "Current passphrase of the wallet file, asked for if not given"+secretFlagWarning)
+	walletPassphraseChangeCmd.Flags().StringVar(&newPassphrase, "new-passphrase", "", "New passphrase of the wallet file, asked for if not given"+secretFlagWarning)
+	rootCmd.AddCommand(walletPassphraseChangeCmd)
+}
+
+// Change the passphrase of the encrypted wallet file.
+func walletPassphraseChange(_ *cobra.Command, _ []string) {
+	wallets, err := crypto.NewWallets(nodeID)
+	if os.IsNotExist(err) {
+		fmt.Println("There is no wallet file, create one with createwallet")
+		return
+	} else if err != nil {
+		log.Panic(err)
+	}
+
+	if !wallets.IsEncrypted() {
+		fmt.Println("The wallet file isn't encrypted, use encryptwallet to set a passphrase")
+		return
+	}
+
+	if passphrase == "" {
+		passphrase = readPassphrase("Current wallet passphrase: ")
+	}
+
+	if newPassphrase == "" {
+		newPassphrase, err = readNewPassphrase()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	err = wallets.ChangePassphrase([]byte(passphrase), []byte(newPassphrase))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	wallets.SaveToFile(nodeID)
+
+	fmt.Println("Wallet passphrase changed")
+}
@@ -0,0 +1,311 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/boltdb/bolt"
+)
+
+const addrIndexBucket = "addrindex"
+
+// ErrAddressIndexDisabled is returned when the address index is queried
+// before it has been built.
+var ErrAddressIndexDisabled = errors.New("address index is not enabled, build it with reindex --addresses")
+
+// Direction says whether an address history entry received or spent coins.
+type Direction byte
+
+const (
+	// Received entries are outputs paid to the address.
+	Received Direction = iota
+
+	// Sent entries are inputs spending an output paid to the address.
+	Sent
+)
+
+// String returns the name of the direction.
+func (d Direction) String() string {
+	if d == Sent {
+		return "sent"
+	}
+
+	return "received"
+}
+
+// AddressHistoryEntry is an output paid to an address, or an input spending
+// one, on the main chain.
+type AddressHistoryEntry struct {
+	TxID      []byte
+	BlockHash []byte
+	Height    int
+	Index     int // Index of the output or input in the transaction.
+	Value     int
+	Direction Direction
+}
+
+// AddressHistory returns every output paid to the public key hash, and every
+// input spending one, on the main chain. Entries are ordered by height.
+// ErrAddressIndexDisabled is returned if the address index hasn't been built.
+func (bc *Blockchain) AddressHistory(pubKeyHash []byte) ([]AddressHistoryEntry, error) {
+	var history []AddressHistoryEntry
+
+	err := bc.db.View(func(tx *bolt.Tx) error {
+		a := tx.Bucket([]byte(addrIndexBucket))
+		if a == nil {
+			return ErrAddressIndexDisabled
+		}
+
+		prefix := addrIndexPrefix(pubKeyHash)
+		c := a.Cursor()
+
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			entry, err := decodeAddrIndexEntry(k[len(prefix):], v)
+			if err != nil {
+				return err
+			}
+
+			history = append(history, entry)
+		}
+
+		return nil
+	})
+
+	return history, err
+}
+
+// AddressIndexEnabled reports whether the address index has been built.
+func (bc *Blockchain) AddressIndexEnabled() bool {
+	var enabled bool
+
+	err := bc.db.View(func(tx *bolt.Tx) error {
+		enabled = tx.Bucket([]byte(addrIndexBucket)) != nil
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return enabled
+}
+
+// ReindexAddresses builds the address index from the main chain, replacing it
+// if it already exists. Once built, the index is kept up to date as blocks
+// are connected and disconnected. The number of entries is returned.
+func (bc *Blockchain) ReindexAddresses() int {
+	count := 0
+
+	err := bc.db.Update(func(tx *bolt.Tx) error {
+		err := tx.DeleteBucket([]byte(addrIndexBucket))
+		if err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+
+		a, err := tx.CreateBucket([]byte(addrIndexBucket))
+		if err != nil {
+			return err
+		}
+
+		b := tx.Bucket([]byte(blocksBucket))
+		for hash := b.Get([]byte(hashKey)); len(hash) > 0; {
+			block, err := DeserializeBlock(b.Get(hash))
+			if err != nil {
+				return err
+			}
+
+			err = indexAddresses(tx, block)
+			if err != nil {
+				return err
+			}
+
+			hash = block.PrevBlockHash
+		}
+
+		return a.ForEach(func(_, _ []byte) error {
+			count++
+
+			return nil
+		})
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return count
+}
+
+// indexAddresses adds the outputs and inputs of a block joining the main chain
+// to the address index, if it is enabled. The transaction index must already
+// include the block.
+func indexAddresses(tx *bolt.Tx, block *Block) error {
+	a := tx.Bucket([]byte(addrIndexBucket))
+	if a == nil {
+		return nil
+	}
+
+	for _, transaction := range block.Transactions {
+		for i, out := range transaction.Vout {
+			hash := out.AddressHash()
+			if hash == nil {
+				continue
+			}
+
+			entry := AddressHistoryEntry{transaction.ID, block.Hash, block.Height, i, out.Value, Received}
+
+			err := a.Put(addrIndexKey(hash, entry), encodeAddrIndexValue(entry))
+			if err != nil {
+				return err
+			}
+		}
+
+		if transaction.IsCoinbase() {
+			continue
+		}
+
+		for i, vin := range transaction.Vin {
+			prevTx, _, err := locateTransaction(tx, vin.Txid)
+			if err != nil {
+				return err
+			}
+			out := prevTx.Vout[vin.Vout]
+
+			hash := out.AddressHash()
+			if hash == nil {
+				continue
+			}
+
+			entry := AddressHistoryEntry{transaction.ID, block.Hash, block.Height, i, out.Value, Sent}
+
+			err = a.Put(addrIndexKey(hash, entry), encodeAddrIndexValue(entry))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// unindexAddresses removes the outputs and inputs of a block leaving the main
+// chain from the address index, if it is enabled. The block must still be in
+// the transaction index.
+func unindexAddresses(tx *bolt.Tx, block *Block) error {
+	a := tx.Bucket([]byte(addrIndexBucket))
+	if a == nil {
+		return nil
+	}
+
+	for _, transaction := range block.Transactions {
+		for i, out := range transaction.Vout {
+			hash := out.AddressHash()
+			if hash == nil {
+				continue
+			}
+
+			entry := AddressHistoryEntry{TxID: transaction.ID, Height: block.Height, Index: i, Direction: Received}
+
+			err := a.Delete(addrIndexKey(hash, entry))
+			if err != nil {
+				return err
+			}
+		}
+
+		if transaction.IsCoinbase() {
+			continue
+		}
+
+		for i, vin := range transaction.Vin {
+			prevTx, _, err := locateTransaction(tx, vin.Txid)
+			if err != nil {
+				return err
+			}
+			out := prevTx.Vout[vin.Vout]
+
+			hash := out.AddressHash()
+			if hash == nil {
+				continue
+			}
+
+			entry := AddressHistoryEntry{TxID: transaction.ID, Height: block.Height, Index: i, Direction: Sent}
+
+			err = a.Delete(addrIndexKey(hash, entry))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// addrIndexPrefix returns the prefix of the address index keys for a public
+// key hash.
+func addrIndexPrefix(pubKeyHash []byte) []byte {
+	return append([]byte{byte(len(pubKeyHash))}, pubKeyHash...)
+}
+
+// addrIndexKey returns the address index key of an entry. Keys are the public
+// key hash followed by the big endian height, so a cursor returns the history
+// of an address in height order.
+func addrIndexKey(pubKeyHash []byte, entry AddressHistoryEntry) []byte {
+	var buf [4]byte
+
+	key := addrIndexPrefix(pubKeyHash)
+
+	binary.BigEndian.PutUint32(buf[:], uint32(entry.Height))
+	key = append(key, buf[:]...)
+
+	key = append(key, byte(len(entry.TxID)))
+	key = append(key, entry.TxID...)
+	key = append(key, byte(entry.Direction))
+
+	binary.BigEndian.PutUint32(buf[:], uint32(entry.Index))
+	key = append(key, buf[:]...)
+
+	return key
+}
+
+// encodeAddrIndexValue encodes the parts of an entry not held in its key.
+func encodeAddrIndexValue(entry AddressHistoryEntry) []byte {
+	var e encoder
+
+	e.uvarint(encodingVersion)
+	e.bytes(entry.BlockHash)
+	e.varint(int64(entry.Value))
+
+	return e.Bytes()
+}
+
+// decodeAddrIndexEntry decodes an address index entry from its key, without
+// the public key hash prefix, and its value.
+func decodeAddrIndexEntry(key, value []byte) (AddressHistoryEntry, error) {
+	var entry AddressHistoryEntry
+
+	if len(key) < 5 || len(key) != 4+1+int(key[4])+1+4 {
+		return entry, errors.New("could not decode address index key")
+	}
+
+	entry.Height = int(binary.BigEndian.Uint32(key))
+	key = key[4:]
+
+	entry.TxID = append([]byte{}, key[1:1+key[0]]...)
+	key = key[1+key[0]:]
+
+	entry.Direction = Direction(key[0])
+	entry.Index = int(binary.BigEndian.Uint32(key[1:]))
+
+	d := decoder{data: value}
+	d.version(encodingVersion)
+	entry.BlockHash = d.bytes()
+	entry.Value = int(d.varint())
+
+	if err := d.finish(); err != nil {
+		return entry, fmt.Errorf("could not decode address index entry: %s", err)
+	}
+
+	return entry, nil
+}
@@ -0,0 +1,40 @@
+package crypto
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddressHistory(t *testing.T) {
+	a, b := NewWallet(), NewWallet()
+	bc := newTestChain(t, a)
+	uTxOSet := UTxOSet{Blockchain: bc}
+
+	_, err := bc.AddressHistory(HashPubKey(a.PublicKey))
+	assert.Equal(t, ErrAddressIndexDisabled, err, "Index is disabled until built")
+
+	assert.Equal(t, 1, bc.ReindexAddresses(), "Genesis output is indexed")
+
+	tx := NewUTxOTransaction(a, string(b.GetAddress()), 4, 1, &uTxOSet)
+	_, err = bc.MineBlock(context.Background(), []*Transaction{NewCoinbaseTx(string(b.GetAddress()), "", 1, 1), tx})
+	assert.NoError(t, err, "Block is mined")
+
+	history, err := bc.AddressHistory(HashPubKey(a.PublicKey))
+	assert.NoError(t, err, "History is found")
+	assert.Len(t, history, 3, "Genesis output, spend and change are in the history")
+
+	assert.Equal(t, 0, history[0].Height, "Genesis output comes first")
+	assert.Equal(t, Received, history[0].Direction, "Genesis output is received")
+	assert.Equal(t, 10, history[0].Value, "Genesis output value is recorded")
+
+	for _, entry := range history[1:] {
+		assert.Equal(t, 1, entry.Height, "Spend is at height 1")
+		assert.Equal(t, tx.ID, entry.TxID, "Spend is in the transaction")
+	}
+
+	history, err = bc.AddressHistory(HashPubKey(b.PublicKey))
+	assert.NoError(t, err, "History is found")
+	assert.Len(t, history, 2, "Coinbase and payment are in the history")
+}
@@ -1,33 +1,39 @@
 package crypto
 
 import (
-	"bytes"
-	"encoding/gob"
+	"context"
+	"fmt"
 	"log"
 	"time"
 )
 
-// Block represents the core structure of a block.
+// The version of the blocks we create.
+const blockVersion = 1
+
+// Block represents the core structure of a block. The header fields are
+// embedded so they can be used directly on the block.
 type Block struct {
-	Timestamp     time.Time
-	Transactions  []*Transaction
-	PrevBlockHash []byte
-	Hash          []byte
-	Nonce         int
-	Height        int
+	BlockHeader
+	Transactions []*Transaction
+	Hash         []byte
+	Height       int
 }
 
-// Serialize serializes a block as gob for storage.
+// Serialize serializes a block in the canonical encoding for storage.
 func (b *Block) Serialize() []byte {
-	var result bytes.Buffer
-	encoder := gob.NewEncoder(&result)
+	var e encoder
 
-	err := encoder.Encode(b)
-	if err != nil {
-		log.Panic(err)
+	e.uvarint(blockEncodingVersion)
+	e.header(&b.BlockHeader)
+	e.bytes(b.Hash)
+	e.uvarint(uint64(b.Height))
+
+	e.uvarint(uint64(len(b.Transactions)))
+	for _, tx := range b.Transactions {
+		e.transaction(tx)
 	}
 
-	return result.Bytes()
+	return e.Bytes()
 }
 
 // HashTransactions returns a hash of the transactions in the block.
@@ -42,33 +48,71 @@ func (b *Block) HashTransactions() []byte {
 	return mTree.RootNode.Data
 }
 
-// NewBlock creates a new block.
-func NewBlock(transactions []*Transaction, prevBlockHash []byte, height int) *Block {
-	block := &Block{time.Now(), transactions, prevBlockHash, []byte{}, 0, height}
+// NewBlock creates a new block, mining it to the target given by bits.
+func NewBlock(transactions []*Transaction, prevBlockHash []byte, height int, bits uint32) *Block {
+	block, err := NewBlockContext(context.Background(), transactions, prevBlockHash, height, bits)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return block
+}
+
+// NewBlockContext creates a new block, mining it to the target given by bits
+// with MinerThreads workers. Mining stops with an error if the context is
+// cancelled, such as when another block arrives first.
+func NewBlockContext(ctx context.Context, transactions []*Transaction, prevBlockHash []byte, height int, bits uint32) (*Block, error) {
+	block := &Block{
+		BlockHeader: BlockHeader{
+			Version:       blockVersion,
+			PrevBlockHash: prevBlockHash,
+			// Timestamps are only kept to the second.
+			Timestamp: time.Unix(time.Now().Unix(), 0),
+			Bits:      bits,
+		},
+		Transactions: transactions,
+		Height:       height,
+	}
+	block.MerkleRoot = block.HashTransactions()
 
 	pow := NewProof(block)
-	nonce, hash := pow.Run()
+	header, hash, err := pow.Run(ctx, MinerThreads)
+	if err != nil {
+		return nil, err
+	}
 
-	block.Hash = hash[:]
-	block.Nonce = nonce
+	block.BlockHeader = header
+	block.Hash = hash
 
-	return block
+	return block, nil
 }
 
 // NewGenesisBlock creates a new "genesis" block to start a chain.
 func NewGenesisBlock(coinbase *Transaction) *Block {
-	return NewBlock([]*Transaction{coinbase}, []byte{}, 0)
+	return NewBlock([]*Transaction{coinbase}, []byte{}, 0, Net.PowLimitBits)
 }
 
-// DeserializeBlock deserializes a block from gob.
-func DeserializeBlock(d []byte) *Block {
+// DeserializeBlock deserializes a block from the canonical encoding.
+func DeserializeBlock(data []byte) (*Block, error) {
 	var block Block
+	d := decoder{data: data}
 
-	decoder := gob.NewDecoder(bytes.NewReader(d))
-	err := decoder.Decode(&block)
-	if err != nil {
-		log.Panic(err)
+	// Only the current version has ever been stored.
+	if v := d.uvarint(); d.err == nil && v != blockEncodingVersion {
+		d.fail(fmt.Errorf("unknown encoding version %d", v))
+	}
+
+	block.BlockHeader = d.header()
+	block.Hash = d.bytes()
+	block.Height = int(d.uvarint())
+
+	for i, n := 0, d.count(); i < n; i++ {
+		block.Transactions = append(block.Transactions, d.transaction())
+	}
+
+	if err := d.finish(); err != nil {
+		return nil, fmt.Errorf("could not decode block: %s", err)
 	}
 
-	return &block
+	return &block, nil
 }
@@ -0,0 +1,65 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// BlockHeader holds the fields of a block that are hashed for its
+// proof-of-work. The transactions are committed to by the merkle root, so a
+// header can be hashed, stored and sent to peers without the block body.
+type BlockHeader struct {
+	Version       int
+	PrevBlockHash []byte
+	MerkleRoot    []byte // The root of the merkle tree of the transactions.
+	Timestamp     time.Time
+	Bits          uint32 // The proof-of-work target in compact form.
+	Nonce         uint32
+}
+
+// Serialize serializes a block header in the canonical encoding.
+func (h *BlockHeader) Serialize() []byte {
+	var e encoder
+
+	e.header(h)
+
+	return e.Bytes()
+}
+
+// Hash returns the hash of the block header, which is the hash of the block.
+func (h *BlockHeader) Hash() []byte {
+	hash := sha256.Sum256(h.Serialize())
+
+	return hash[:]
+}
+
+// CheckProof checks that the hash of the header meets the target given by its
+// bits. Whether that is the target the chain requires can only be checked
+// with the previous blocks, see Proof.Validate.
+func (h *BlockHeader) CheckProof() bool {
+	var hashInt big.Int
+
+	target := CompactToBig(h.Bits)
+	if !validTarget(target) {
+		return false
+	}
+
+	hashInt.SetBytes(h.Hash())
+
+	return hashInt.Cmp(target) == -1
+}
+
+// DeserializeBlockHeader deserializes a block header from the canonical
+// encoding.
+func DeserializeBlockHeader(data []byte) (*BlockHeader, error) {
+	d := decoder{data: data}
+	header := d.header()
+
+	if err := d.finish(); err != nil {
+		return nil, fmt.Errorf("could not decode block header: %s", err)
+	}
+
+	return &header, nil
+}
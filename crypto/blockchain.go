@@ -2,56 +2,292 @@ package crypto
 
 import (
 	"bytes"
+	"context"
 	"crypto/ecdsa"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"log"
+	"math/big"
 	"os"
+	"sync"
 
 	"github.com/boltdb/bolt"
 )
 
 const (
-	blocksBucket        = "blocks"
-	dbFile              = "blockchain_%s.db"
-	fileMode            = 0600
-	genesisCoinbaseData = "The Times 03/Jan/2009 Chancellor on brink of second bailout for banks"
-	hashKey             = "l"
+	blocksBucket    = "blocks"
+	chainWorkBucket = "chainwork"
+	fileMode        = 0600
+	hashKey         = "l"
+	headersBucket   = "headers"
+
+	// The most headers returned by GetHeaders.
+	MaxHeaders = 2000
 )
 
 // Blockchain represents the chain of blocks.
 type Blockchain struct {
+	// The tip is only used with mu held. Elsewhere it is read from the
+	// database, which setTip keeps up to date.
 	tip []byte
 	db  *bolt.DB
+
+	// Serialises changes to the main chain.
+	mu sync.Mutex
+}
+
+// ChainChange describes how the main chain moved when a block was added.
+type ChainChange struct {
+	Disconnected []*Block // Blocks removed from the main chain, tip first.
+	Connected    []*Block // Blocks added to the main chain, oldest first.
 }
 
-// AddBlock saves the block into the blockchain.
-func (bc *Blockchain) AddBlock(block *Block) {
+// Displaced returns the transactions from disconnected blocks which are not
+// part of the new main chain. Coinbase transactions are never returned as
+// they are only valid in the block that created them.
+func (c *ChainChange) Displaced() []*Transaction {
+	var displaced []*Transaction
+	confirmed := make(map[string]bool)
+
+	for _, block := range c.Connected {
+		for _, tx := range block.Transactions {
+			confirmed[hex.EncodeToString(tx.ID)] = true
+		}
+	}
+
+	for _, block := range c.Disconnected {
+		for _, tx := range block.Transactions {
+			if tx.IsCoinbase() || confirmed[hex.EncodeToString(tx.ID)] {
+				continue
+			}
+
+			displaced = append(displaced, tx)
+		}
+	}
+
+	return displaced
+}
+
+// AddBlock saves the block into the blockchain. Blocks that do not extend the
+// main chain are kept as side branches. If the branch the block belongs to has
+// more accumulated work than the main chain, the chain is reorganised onto it.
+// The returned ChainChange describes which blocks left and joined the main
+// chain as a result.
+func (bc *Blockchain) AddBlock(block *Block) (*ChainChange, error) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	var known bool
+	var blockWork, tipWork *big.Int
+
 	err := bc.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
-		blockInDb := b.Get(block.Hash)
+		h := tx.Bucket([]byte(headersBucket))
+		w := tx.Bucket([]byte(chainWorkBucket))
 
-		if blockInDb != nil {
+		if b.Get(block.Hash) != nil {
+			known = true
 			return nil
 		}
 
-		blockData := block.Serialize()
-		err := b.Put(block.Hash, blockData)
+		// We can only weigh a block if we know the branch it builds on.
+		parentWork := w.Get(block.PrevBlockHash)
+		if parentWork == nil {
+			return ruleError(ErrOrphanBlock, "previous block %x is not known", block.PrevBlockHash)
+		}
+
+		blockWork = new(big.Int).SetBytes(parentWork)
+		blockWork.Add(blockWork, NewProof(block).Work())
+		tipWork = new(big.Int).SetBytes(w.Get(bc.tip))
+
+		err := b.Put(block.Hash, block.Serialize())
+		if err != nil {
+			log.Panic(err)
+		}
+
+		err = h.Put(block.Hash, block.BlockHeader.Serialize())
 		if err != nil {
 			log.Panic(err)
 		}
 
-		lastHash := b.Get([]byte("l"))
-		lastBlockData := b.Get(lastHash)
-		lastBlock := DeserializeBlock(lastBlockData)
+		err = w.Put(block.Hash, blockWork.Bytes())
+		if err != nil {
+			log.Panic(err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Blocks on a branch with no more work than the main chain are stored but
+	// do not move the tip.
+	if known || blockWork.Cmp(tipWork) <= 0 {
+		return &ChainChange{}, nil
+	}
+
+	return bc.reorganize(block)
+}
+
+// reorganize makes the given block the tip of the main chain. Blocks from the
+// current tip back to the fork point are disconnected, and the blocks of the
+// new branch are connected from the fork point onwards. If a block on the new
+// branch turns out to be invalid the branch is removed and the original main
+// chain is restored.
+func (bc *Blockchain) reorganize(newTip *Block) (*ChainChange, error) {
+	change := &ChainChange{}
+
+	oldTip, err := bc.GetBlock(bc.tip)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	detach := &oldTip
+	attach := newTip
+
+	// Walk both branches back until they meet at the fork point.
+	for !bytes.Equal(detach.Hash, attach.Hash) {
+		if attach.Height >= detach.Height {
+			change.Connected = append([]*Block{attach}, change.Connected...)
 
-		if block.Height > lastBlock.Height {
-			err = b.Put([]byte("l"), block.Hash)
+			parent, err := bc.GetBlock(attach.PrevBlockHash)
 			if err != nil {
 				log.Panic(err)
 			}
-			bc.tip = block.Hash
+			attach = &parent
+		} else {
+			change.Disconnected = append(change.Disconnected, detach)
+
+			parent, err := bc.GetBlock(detach.PrevBlockHash)
+			if err != nil {
+				log.Panic(err)
+			}
+			detach = &parent
+		}
+	}
+	fork := detach
+
+	for _, block := range change.Disconnected {
+		// Blocks connected before undo data was recorded can't be rolled
+		// back, so fall back to rebuilding the chain state at the fork point.
+		if err := bc.disconnectBlock(block); err != nil {
+			bc.setTip(fork.Hash)
+			UTxOSet{Blockchain: bc}.Reindex()
+			bc.ReindexTransactions()
+			bc.ReindexHeights()
+			if bc.AddressIndexEnabled() {
+				bc.ReindexAddresses()
+			}
+			break
+		}
+	}
+
+	for i, block := range change.Connected {
+		if err := bc.checkConnectBlock(block); err != nil {
+			// Go back to the original main chain and forget the invalid
+			// block along with everything built on it.
+			for j := i - 1; j >= 0; j-- {
+				err := bc.disconnectBlock(change.Connected[j])
+				if err != nil {
+					log.Panic(err)
+				}
+			}
+
+			for j := len(change.Disconnected) - 1; j >= 0; j-- {
+				bc.connectBlock(change.Disconnected[j])
+			}
+
+			bc.removeBlocks(change.Connected[i:])
+
+			return nil, err
+		}
+
+		bc.connectBlock(block)
+	}
+
+	return change, nil
+}
+
+// connectBlock adds a block which builds on the tip to the main chain. The
+// UTXO set and the indexes are updated and the block becomes the new tip.
+func (bc *Blockchain) connectBlock(block *Block) {
+	UTxOSet{Blockchain: bc}.Update(block)
+
+	err := bc.db.Update(func(tx *bolt.Tx) error {
+		err := indexTransactions(tx, block)
+		if err != nil {
+			return err
+		}
+
+		err = indexHeight(tx, block)
+		if err != nil {
+			return err
+		}
+
+		return indexAddresses(tx, block)
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	bc.setTip(block.Hash)
+}
+
+// disconnectBlock removes the block at the tip from the main chain, reverting
+// the changes made by connectBlock. An error is returned if the block has no
+// undo data.
+func (bc *Blockchain) disconnectBlock(block *Block) error {
+	err := UTxOSet{Blockchain: bc}.Disconnect(block)
+	if err != nil {
+		return err
+	}
+
+	err = bc.db.Update(func(tx *bolt.Tx) error {
+		err := unindexAddresses(tx, block)
+		if err != nil {
+			return err
+		}
+
+		err = unindexHeight(tx, block)
+		if err != nil {
+			return err
+		}
+
+		return unindexTransactions(tx, block)
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	bc.setTip(block.PrevBlockHash)
+
+	return nil
+}
+
+// removeBlocks deletes blocks which are not on the main chain.
+func (bc *Blockchain) removeBlocks(blocks []*Block) {
+	err := bc.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(blocksBucket))
+		h := tx.Bucket([]byte(headersBucket))
+		w := tx.Bucket([]byte(chainWorkBucket))
+
+		for _, block := range blocks {
+			err := b.Delete(block.Hash)
+			if err != nil {
+				return err
+			}
+
+			err = h.Delete(block.Hash)
+			if err != nil {
+				return err
+			}
+
+			err = w.Delete(block.Hash)
+			if err != nil {
+				return err
+			}
 		}
 
 		return nil
@@ -61,6 +297,20 @@ func (bc *Blockchain) AddBlock(block *Block) {
 	}
 }
 
+// setTip stores the hash of the block at the tip of the main chain.
+func (bc *Blockchain) setTip(hash []byte) {
+	err := bc.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(blocksBucket))
+
+		return b.Put([]byte(hashKey), hash)
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	bc.tip = hash
+}
+
 // GetBestHeight returns the height of the latest block.
 func (bc *Blockchain) GetBestHeight() int {
 	var lastBlock Block
@@ -69,7 +319,11 @@ func (bc *Blockchain) GetBestHeight() int {
 		b := tx.Bucket([]byte(blocksBucket))
 		lastHash := b.Get([]byte("l"))
 		blockData := b.Get(lastHash)
-		lastBlock = *DeserializeBlock(blockData)
+		block, err := DeserializeBlock(blockData)
+		if err != nil {
+			return err
+		}
+		lastBlock = *block
 
 		return nil
 	})
@@ -93,7 +347,11 @@ func (bc *Blockchain) GetBlock(blockHash []byte) (Block, error) {
 			return errors.New("block is not found")
 		}
 
-		block = *DeserializeBlock(blockData)
+		decoded, err := DeserializeBlock(blockData)
+		if err != nil {
+			return err
+		}
+		block = *decoded
 
 		return nil
 	})
@@ -104,28 +362,129 @@ func (bc *Blockchain) GetBlock(blockHash []byte) (Block, error) {
 	return block, nil
 }
 
-// GetBlockHashes returns a list of hashes of all the blocks in the chain.
+// HasBlock reports whether the block with the given hash is stored, on the
+// main chain or a side branch.
+func (bc *Blockchain) HasBlock(blockHash []byte) bool {
+	var found bool
+
+	err := bc.db.View(func(tx *bolt.Tx) error {
+		found = tx.Bucket([]byte(headersBucket)).Get(blockHash) != nil
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return found
+}
+
+// GetBlockHeader finds a block header by its hash and returns it. Only the
+// header is read, not the block body.
+func (bc *Blockchain) GetBlockHeader(blockHash []byte) (BlockHeader, error) {
+	var header BlockHeader
+
+	err := bc.db.View(func(tx *bolt.Tx) error {
+		headerData := tx.Bucket([]byte(headersBucket)).Get(blockHash)
+		if headerData == nil {
+			return errors.New("block header is not found")
+		}
+
+		decoded, err := DeserializeBlockHeader(headerData)
+		if err != nil {
+			return err
+		}
+		header = *decoded
+
+		return nil
+	})
+
+	return header, err
+}
+
+// GetHeaders returns up to max headers of the main chain which follow the
+// block with the given hash, oldest first. If the block is not on the main
+// chain the headers are returned from the genesis block onwards. Headers of
+// blocks converted by MigrateDB are never returned, as peers can't check
+// their proof-of-work.
+func (bc *Blockchain) GetHeaders(after []byte, max int) []*BlockHeader {
+	var headers []*BlockHeader
+
+	if max <= 0 || max > MaxHeaders {
+		max = MaxHeaders
+	}
+
+	err := bc.db.View(func(tx *bolt.Tx) error {
+		h := tx.Bucket([]byte(headersBucket))
+		tip := tx.Bucket([]byte(blocksBucket)).Get([]byte(hashKey))
+		migrated := migratedTip(tx)
+
+		// Walk back from the tip. Only the headers are read, which keeps
+		// this cheap compared with loading every block.
+		for hash := tip; len(hash) > 0 && !bytes.Equal(hash, after) && !bytes.Equal(hash, migrated); {
+			header, err := DeserializeBlockHeader(h.Get(hash))
+			if err != nil {
+				return err
+			}
+			headers = append(headers, header)
+			hash = header.PrevBlockHash
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Reverse the headers so the oldest comes first.
+	for i, j := 0, len(headers)-1; i < j; i, j = i+1, j-1 {
+		headers[i], headers[j] = headers[j], headers[i]
+	}
+
+	if len(headers) > max {
+		headers = headers[:max]
+	}
+
+	return headers
+}
+
+// GetBestHash returns the hash of the block at the tip of the main chain.
+func (bc *Blockchain) GetBestHash() []byte {
+	tip, err := readTip(bc.db)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return tip
+}
+
+// GetBlockHashes returns the hashes of the blocks of the main chain, newest
+// first.
 func (bc *Blockchain) GetBlockHashes() [][]byte {
 	var blocks [][]byte
-	bci := bc.Iterator()
-
-	for {
-		block := bci.Next()
 
-		blocks = append(blocks, block.Hash)
+	err := bc.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(heightIndexBucket)).Cursor()
 
-		if len(block.PrevBlockHash) == 0 {
-			break
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			blocks = append(blocks, append([]byte{}, v...))
 		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
 	}
 
 	return blocks
 }
 
-// MineBlock mines a new block with the provided transactions.
-func (bc *Blockchain) MineBlock(transactions []*Transaction) *Block {
-	var lastHash []byte
-	var lastHeight int
+// MineBlock mines a new block with the provided transactions on top of the
+// current tip and adds it to the blockchain. An error is returned if the block
+// breaks a consensus rule, such as by spending an output twice, or if the
+// context is cancelled before the block is mined.
+func (bc *Blockchain) MineBlock(ctx context.Context, transactions []*Transaction) (*Block, error) {
+	var lastBlock *Block
 
 	for _, tx := range transactions {
 		if !bc.VerifyTransaction(tx) {
@@ -136,14 +495,13 @@ func (bc *Blockchain) MineBlock(transactions []*Transaction) *Block {
 	// Get the hash of the last block in the DB.
 	err := bc.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
-		lastHash = b.Get([]byte(hashKey))
+		lastHash := b.Get([]byte(hashKey))
 
 		blockData := b.Get(lastHash)
-		block := DeserializeBlock(blockData)
+		var err error
+		lastBlock, err = DeserializeBlock(blockData)
 
-		lastHeight = block.Height
-
-		return nil
+		return err
 	})
 
 	if err != nil {
@@ -151,31 +509,38 @@ func (bc *Blockchain) MineBlock(transactions []*Transaction) *Block {
 	}
 
 	// Mine a new block and add to the DB.
-	newBlock := NewBlock(transactions, lastHash, lastHeight+1)
+	bits := bc.requiredBits(lastBlock)
+	newBlock, err := NewBlockContext(ctx, transactions, lastBlock.Hash, lastBlock.Height+1, bits)
+	if err != nil {
+		return nil, err
+	}
 
-	err = bc.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(blocksBucket))
+	_, err = bc.AddBlock(newBlock)
+	if err != nil {
+		return nil, err
+	}
 
-		err := b.Put(newBlock.Hash, newBlock.Serialize())
-		if err != nil {
-			log.Panic(err)
-		}
+	return newBlock, nil
+}
 
-		err = b.Put([]byte(hashKey), newBlock.Hash)
-		if err != nil {
-			log.Panic(err)
-		}
+// Generate mines n blocks on top of the current tip, paying the subsidy of each
+// to address, and returns them. It is meant for quickly building chains on a
+// network with trivial difficulty, such as regtest.
+func (bc *Blockchain) Generate(ctx context.Context, address string, n int) ([]*Block, error) {
+	var blocks []*Block
 
-		bc.tip = newBlock.Hash
+	for i := 0; i < n; i++ {
+		cbTx := NewCoinbaseTx(address, "", bc.GetBestHeight()+1, 0)
 
-		return nil
-	})
+		block, err := bc.MineBlock(ctx, []*Transaction{cbTx})
+		if err != nil {
+			return blocks, err
+		}
 
-	if err != nil {
-		log.Panic(err)
+		blocks = append(blocks, block)
 	}
 
-	return newBlock
+	return blocks, nil
 }
 
 // FindUTXO finds all unspent transaction outputs and returns transactions with spent outputs removed
@@ -192,6 +557,10 @@ func (bc *Blockchain) FindUTxO() map[string]TxOutputs {
 
 		Outputs:
 			for outIdx, out := range tx.Vout {
+				if out.IsUnspendable() {
+					continue
+				}
+
 				// Was the output spent?
 				if spentTXOs[txID] != nil {
 					for _, spentOutIdx := range spentTXOs[txID] {
@@ -201,9 +570,14 @@ func (bc *Blockchain) FindUTxO() map[string]TxOutputs {
 					}
 				}
 
-				outs := uTxO[txID]
-				outs.Outputs = append(outs.Outputs, out)
-				uTxO[txID] = outs
+				outs, ok := uTxO[txID]
+				if !ok {
+					outs = NewTxOutputs()
+					outs.Height = block.Height
+					outs.Coinbase = tx.IsCoinbase()
+					uTxO[txID] = outs
+				}
+				outs.Outputs[outIdx] = out
 			}
 
 			if tx.IsCoinbase() == false {
@@ -224,7 +598,7 @@ func (bc *Blockchain) FindUTxO() map[string]TxOutputs {
 
 // Iterator returns a new iterator for the current blockchain.
 func (bc *Blockchain) Iterator() *BlockchainIterator {
-	bci := &BlockchainIterator{bc.tip, bc.db}
+	bci := &BlockchainIterator{bc.GetBestHash(), bc.db}
 
 	return bci
 }
@@ -249,6 +623,10 @@ func (bc *Blockchain) FindUnspentTransactions(pubKeyHash []byte) []Transaction {
 
 		Outputs:
 			for outIdx, out := range tx.Vout {
+				if out.IsUnspendable() {
+					continue
+				}
+
 				// Was the output spent?
 				if spentTXOs[txID] != nil {
 					for _, spentOut := range spentTXOs[txID] {
@@ -281,41 +659,26 @@ func (bc *Blockchain) FindUnspentTransactions(pubKeyHash []byte) []Transaction {
 	return unspentTXs
 }
 
-// FindTransaction finds a transaction by its ID.
+// FindTransaction finds a transaction on the main chain by its ID.
 func (bc *Blockchain) FindTransaction(ID []byte) (Transaction, error) {
-	bci := bc.Iterator()
-
-	for {
-		block := bci.Next()
-
-		for _, tx := range block.Transactions {
-			if bytes.Compare(tx.ID, ID) == 0 {
-				return *tx, nil
-			}
-		}
+	tx, _, err := bc.FindTransactionBlock(ID)
 
-		if len(block.PrevBlockHash) == 0 {
-			break
-		}
-	}
-
-	return Transaction{}, errors.New("transaction is not found")
+	return tx, err
 }
 
 // SignTransaction signs inputs of a Transaction.
 func (bc *Blockchain) SignTransaction(tx *Transaction, privKey ecdsa.PrivateKey) {
-	prevTXs := make(map[string]Transaction)
-
-	for _, vin := range tx.Vin {
-		prevTX, err := bc.FindTransaction(vin.Txid)
-		if err != nil {
-			log.Panic(err)
-		}
+	tx.Sign(privKey, bc.prevTransactions(tx))
+}
 
-		prevTXs[hex.EncodeToString(prevTX.ID)] = prevTX
-	}
+// SignMultiSigTransaction adds a signature by privKey to the multisig inputs
+// of a transaction, returning the number of signatures added. The ID of the
+// transaction is updated to cover them.
+func (bc *Blockchain) SignMultiSigTransaction(tx *Transaction, privKey ecdsa.PrivateKey) int {
+	added := tx.SignMultiSig(privKey, bc.prevTransactions(tx))
+	tx.ID = tx.Hash()
 
-	tx.Sign(privKey, prevTXs)
+	return added
 }
 
 // VerifyTransaction verifies transaction input signatures.
@@ -324,6 +687,12 @@ func (bc *Blockchain) VerifyTransaction(tx *Transaction) bool {
 		return true
 	}
 
+	return tx.Verify(bc.prevTransactions(tx))
+}
+
+// prevTransactions returns the transactions holding the outputs spent by a
+// transaction, keyed by their hex encoded IDs.
+func (bc *Blockchain) prevTransactions(tx *Transaction) map[string]Transaction {
 	prevTXs := make(map[string]Transaction)
 
 	for _, vin := range tx.Vin {
@@ -335,7 +704,7 @@ func (bc *Blockchain) VerifyTransaction(tx *Transaction) bool {
 		prevTXs[hex.EncodeToString(prevTX.ID)] = prevTX
 	}
 
-	return tx.Verify(prevTXs)
+	return prevTXs
 }
 
 // BlockchainIterator is used to iterate over the blockchain.
@@ -352,9 +721,10 @@ func (i *BlockchainIterator) Next() *Block {
 	err := i.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
 		encodedBlock := b.Get(i.currentHash)
-		block = DeserializeBlock(encodedBlock)
+		var err error
+		block, err = DeserializeBlock(encodedBlock)
 
-		return nil
+		return err
 	})
 
 	if err != nil {
@@ -368,24 +738,82 @@ func (i *BlockchainIterator) Next() *Block {
 
 // NewBlockchain creates a new blockchain with a genesis block.
 func NewBlockchain(nodeID string) *Blockchain {
-	dbFile := fmt.Sprintf(dbFile, nodeID)
+	dbFile := fmt.Sprintf(Net.DBFile, nodeID)
 	if dbExists(dbFile) == false {
 		fmt.Println("No existing blockchain found. Create one first.")
 		os.Exit(1)
 	}
 
-	var tip []byte
-
 	// Open or create blockchain db.
 	db, err := bolt.Open(dbFile, fileMode, nil)
 	if err != nil {
 		log.Panic(err)
 	}
 
+	var legacy bool
+	var network string
+	var version byte
+	err = db.View(func(tx *bolt.Tx) error {
+		legacy = isLegacyDB(tx)
+		if !legacy {
+			network = dbNetwork(tx)
+			version = readDBVersion(tx)
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if legacy {
+		db.Close()
+		fmt.Println("Blockchain uses an old format. Run migratedb to upgrade it.")
+		os.Exit(1)
+	}
+
+	if network != Net.Name {
+		db.Close()
+		fmt.Printf("Blockchain belongs to the %s network, not %s.\n", network, Net.Name)
+		os.Exit(1)
+	}
+
+	tip, err := readTip(db)
+	if err != nil {
+		log.Panic(err)
+	}
+
 	err = db.Update(func(tx *bolt.Tx) error {
-		// Get the block bucket.
-		b := tx.Bucket([]byte(blocksBucket))
-		tip = b.Get([]byte(hashKey))
+		// Databases created before fork handling have no record of the
+		// work behind each block, so build it from the main chain.
+		if tx.Bucket([]byte(chainWorkBucket)) == nil {
+			err := indexChainWork(tx, tip)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Likewise, headers were not stored separately from blocks.
+		if tx.Bucket([]byte(headersBucket)) == nil {
+			err := indexHeaders(tx)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Nor were transactions indexed.
+		if tx.Bucket([]byte(txIndexBucket)) == nil {
+			_, err := indexChainTransactions(tx, tip)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Nor were blocks indexed by height.
+		if tx.Bucket([]byte(heightIndexBucket)) == nil {
+			_, err := indexChainHeights(tx, tip)
+			return err
+		}
 
 		return nil
 	})
@@ -394,19 +822,24 @@ func NewBlockchain(nodeID string) *Blockchain {
 		log.Panic(err)
 	}
 
-	return &Blockchain{tip, db}
+	bc := &Blockchain{tip: tip, db: db}
+	if version < dbVersion {
+		bc.upgradeChainState()
+	}
+
+	return bc
 }
 
 // CreateBlockchain creates a new blockchain DB
 func CreateBlockchain(address, nodeID string) *Blockchain {
-	dbFile := fmt.Sprintf(dbFile, nodeID)
+	dbFile := fmt.Sprintf(Net.DBFile, nodeID)
 	if dbExists(dbFile) {
 		fmt.Println("Blockchain already exists.")
 		os.Exit(1)
 	}
 
 	var tip []byte
-	cbtx := NewCoinbaseTx(address, genesisCoinbaseData)
+	cbtx := NewCoinbaseTx(address, Net.GenesisCoinbaseData, 0, 0)
 	genesis := NewGenesisBlock(cbtx)
 
 	db, err := bolt.Open(dbFile, fileMode, nil)
@@ -425,24 +858,124 @@ func CreateBlockchain(address, nodeID string) *Blockchain {
 			log.Panic(err)
 		}
 
-		err = b.Put([]byte("l"), genesis.Hash)
+		err = b.Put([]byte(hashKey), genesis.Hash)
 		if err != nil {
 			log.Panic(err)
 		}
 		tip = genesis.Hash
 
-		return nil
+		h, err := tx.CreateBucket([]byte(headersBucket))
+		if err != nil {
+			log.Panic(err)
+		}
+
+		err = h.Put(genesis.Hash, genesis.BlockHeader.Serialize())
+		if err != nil {
+			log.Panic(err)
+		}
+
+		w, err := tx.CreateBucket([]byte(chainWorkBucket))
+		if err != nil {
+			log.Panic(err)
+		}
+
+		err = w.Put(genesis.Hash, NewProof(genesis).Work().Bytes())
+		if err != nil {
+			log.Panic(err)
+		}
+
+		err = indexTransactions(tx, genesis)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		err = indexHeight(tx, genesis)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		return putDBVersion(tx)
 	})
 
 	if err != nil {
 		log.Panic(err)
 	}
 
-	bc := Blockchain{tip, db}
+	bc := Blockchain{tip: tip, db: db}
 
 	return &bc
 }
 
+// readTip returns the hash of the block at the tip of the main chain.
+func readTip(db *bolt.DB) ([]byte, error) {
+	var tip []byte
+
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(blocksBucket))
+		tip = append([]byte{}, b.Get([]byte(hashKey))...)
+
+		return nil
+	})
+
+	return tip, err
+}
+
+// indexChainWork creates the chain work bucket and fills it with the total
+// work of each block on the main chain ending at tip.
+func indexChainWork(tx *bolt.Tx, tip []byte) error {
+	var chain []*Block
+
+	b := tx.Bucket([]byte(blocksBucket))
+	w, err := tx.CreateBucket([]byte(chainWorkBucket))
+	if err != nil {
+		return err
+	}
+
+	for hash := tip; len(hash) > 0; {
+		block, err := DeserializeBlock(b.Get(hash))
+		if err != nil {
+			return err
+		}
+		chain = append(chain, block)
+		hash = block.PrevBlockHash
+	}
+
+	work := big.NewInt(0)
+	for i := len(chain) - 1; i >= 0; i-- {
+		work.Add(work, NewProof(chain[i]).Work())
+
+		err = w.Put(chain[i].Hash, work.Bytes())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// indexHeaders creates the headers bucket and fills it with the header of every
+// stored block.
+func indexHeaders(tx *bolt.Tx) error {
+	b := tx.Bucket([]byte(blocksBucket))
+	h, err := tx.CreateBucket([]byte(headersBucket))
+	if err != nil {
+		return err
+	}
+
+	return b.ForEach(func(k, v []byte) error {
+		if bytes.Equal(k, []byte(hashKey)) {
+			return nil
+		}
+
+		block, err := DeserializeBlock(v)
+		if err != nil {
+			return err
+		}
+
+		return h.Put(k, block.BlockHeader.Serialize())
+	})
+}
+
 // Check if the blockchain database exists.
 func dbExists(dbFile string) bool {
 	if _, err := os.Stat(dbFile); os.IsNotExist(err) {
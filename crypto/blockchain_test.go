@@ -0,0 +1,171 @@
+package crypto
+
+import (
+	"context"
+	"testing"
+
+	"github.com/danmrichards/yagocoin/chaincfg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerate(t *testing.T) {
+	SetNetwork(&chaincfg.RegressionNetParams)
+	t.Cleanup(func() { SetNetwork(&chaincfg.MainNetParams) })
+
+	w := NewWallet()
+	bc := newTestChain(t, w)
+
+	blocks, err := bc.Generate(context.Background(), string(w.GetAddress()), 20)
+	assert.NoError(t, err, "Blocks are generated")
+	assert.Len(t, blocks, 20, "Every block is returned")
+	assert.Equal(t, 20, bc.GetBestHeight(), "Blocks are on the main chain")
+
+	balance := 0
+	uTxOSet := UTxOSet{Blockchain: bc}
+	for _, out := range uTxOSet.FindUTxO(HashPubKey(w.PublicKey)) {
+		balance += out.Value
+	}
+	assert.Equal(t, Emission.Supply(20), balance, "UTXO set holds the subsidies")
+}
+
+// mineOn mines a block with the given transactions on top of parent, without
+// adding it to the blockchain.
+func mineOn(bc *Blockchain, parent *Block, transactions ...*Transaction) *Block {
+	return NewBlock(transactions, parent.Hash, parent.Height+1, bc.requiredBits(parent))
+}
+
+// forkTestChain sets up a regtest chain whose genesis pays a, and a block on
+// it which spends the genesis coinbase to b.
+func forkTestChain(t *testing.T, a, b *Wallet) (bc *Blockchain, genesis, main *Block, spend *Transaction) {
+	SetNetwork(&chaincfg.RegressionNetParams)
+	t.Cleanup(func() { SetNetwork(&chaincfg.MainNetParams) })
+
+	bc = newTestChain(t, a)
+	uTxOSet := UTxOSet{Blockchain: bc}
+
+	g, err := bc.GetBlockByHeight(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spend = NewUTxOTransaction(a, string(b.GetAddress()), 4, 1, &uTxOSet)
+	main, err = bc.MineBlock(context.Background(), []*Transaction{NewCoinbaseTx(string(a.GetAddress()), "", 1, 1), spend})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return bc, &g, main, spend
+}
+
+func TestReorganize(t *testing.T) {
+	a, b := NewWallet(), NewWallet()
+	bc, genesis, main, spend := forkTestChain(t, a, b)
+	uTxOSet := UTxOSet{Blockchain: bc}
+	coinbase := genesis.Transactions[0]
+
+	side1 := mineOn(bc, genesis, NewCoinbaseTx(string(b.GetAddress()), "", 1, 0))
+	change, err := bc.AddBlock(side1)
+	assert.NoError(t, err, "Side block is added")
+	assert.Empty(t, change.Connected, "Branch with equal work doesn't win")
+	assert.Equal(t, main.Hash, bc.GetBestHash(), "Tip stays on the first branch")
+
+	side2 := mineOn(bc, side1, NewCoinbaseTx(string(b.GetAddress()), "", 2, 0))
+	change, err = bc.AddBlock(side2)
+	assert.NoError(t, err, "Heavier side block is added")
+	assert.Equal(t, []*Block{main}, change.Disconnected, "First branch is disconnected")
+	assert.Equal(t, []*Block{side1, side2}, change.Connected, "Heavier branch is connected")
+	assert.Equal(t, []*Transaction{spend}, change.Displaced(), "Spend is displaced")
+
+	assert.Equal(t, side2.Hash, bc.GetBestHash(), "Tip follows the heavier branch")
+	assert.Equal(t, 2, bc.GetBestHeight(), "Height follows the heavier branch")
+	block, err := bc.GetBlockByHeight(1)
+	assert.NoError(t, err, "Block is found by height")
+	assert.Equal(t, side1.Hash, block.Hash, "Height index follows the heavier branch")
+
+	_, found := uTxOSet.FindTxOutputs(spend.ID)
+	assert.False(t, found, "Outputs of the displaced spend are removed")
+	_, found = uTxOSet.FindOutput(coinbase.ID, 0)
+	assert.True(t, found, "Output spent by the displaced spend is restored")
+	spendable, _ := uTxOSet.GetBalance(HashPubKey(b.PublicKey))
+	assert.Equal(t, Emission.Subsidy(1)+Emission.Subsidy(2), spendable, "UTXO set holds the heavier branch")
+
+	// The first branch wins back once it is heavier again.
+	main2 := mineOn(bc, main, NewCoinbaseTx(string(a.GetAddress()), "", 2, 0))
+	change, err = bc.AddBlock(main2)
+	assert.NoError(t, err, "Block is added to the first branch")
+	assert.Empty(t, change.Connected, "Branch with equal work doesn't win")
+
+	main3 := mineOn(bc, main2, NewCoinbaseTx(string(a.GetAddress()), "", 3, 0))
+	change, err = bc.AddBlock(main3)
+	assert.NoError(t, err, "Block is added to the first branch")
+	assert.Equal(t, []*Block{side2, side1}, change.Disconnected, "Side branch is disconnected, tip first")
+	assert.Equal(t, main3.Hash, bc.GetBestHash(), "Tip follows the heavier branch")
+
+	_, found = uTxOSet.FindTxOutputs(spend.ID)
+	assert.True(t, found, "Outputs of the spend are back")
+	_, found = uTxOSet.FindOutput(coinbase.ID, 0)
+	assert.False(t, found, "Output spent by the spend is spent again")
+}
+
+func TestReorganizeInvalidBlock(t *testing.T) {
+	a, b := NewWallet(), NewWallet()
+	bc, genesis, main, spend := forkTestChain(t, a, b)
+	uTxOSet := UTxOSet{Blockchain: bc}
+
+	side1 := mineOn(bc, genesis, NewCoinbaseTx(string(b.GetAddress()), "", 1, 0))
+	_, err := bc.AddBlock(side1)
+	assert.NoError(t, err, "Side block is added")
+
+	// The coinbase claims fees the block doesn't have.
+	side2 := mineOn(bc, side1, NewCoinbaseTx(string(b.GetAddress()), "", 2, 100))
+	_, err = bc.AddBlock(side2)
+	if assert.IsType(t, RuleError{}, err, "Invalid block is rejected") {
+		assert.Equal(t, ErrBadCoinbaseValue, err.(RuleError).Code, "Invalid block is rejected")
+	}
+
+	assert.Equal(t, main.Hash, bc.GetBestHash(), "Tip stays on the original chain")
+	block, err := bc.GetBlockByHeight(1)
+	assert.NoError(t, err, "Block is found by height")
+	assert.Equal(t, main.Hash, block.Hash, "Height index stays on the original chain")
+	assert.False(t, bc.HasBlock(side2.Hash), "Invalid block is forgotten")
+	assert.True(t, bc.HasBlock(side1.Hash), "Valid side block is kept")
+
+	_, found := uTxOSet.FindTxOutputs(spend.ID)
+	assert.True(t, found, "UTXO set stays on the original chain")
+	spendable, _ := uTxOSet.GetBalance(HashPubKey(b.PublicKey))
+	assert.Equal(t, 4, spendable, "Side branch coins aren't in the UTXO set")
+
+	tx, err := bc.FindTransaction(spend.ID)
+	assert.NoError(t, err, "Transaction index stays on the original chain")
+	assert.Equal(t, spend.ID, tx.ID, "Transaction index stays on the original chain")
+}
+
+func TestTipReadsDuringAddBlock(t *testing.T) {
+	SetNetwork(&chaincfg.RegressionNetParams)
+	t.Cleanup(func() { SetNetwork(&chaincfg.MainNetParams) })
+
+	w := NewWallet()
+	bc := newTestChain(t, w)
+
+	done := make(chan []*Block)
+	go func() {
+		blocks, _ := bc.Generate(context.Background(), string(w.GetAddress()), 5)
+		done <- blocks
+	}()
+
+	// Run with -race to catch reads of the tip racing with setTip.
+	var blocks []*Block
+	for blocks == nil {
+		select {
+		case blocks = <-done:
+		default:
+			bc.GetBestHash()
+			bc.GetHeaders(nil, MaxHeaders)
+			bc.Iterator().Next()
+		}
+	}
+
+	assert.Len(t, blocks, 5, "Every block is generated")
+	assert.Equal(t, blocks[4].Hash, bc.GetBestHash(), "Tip is the last block")
+	assert.Len(t, bc.GetHeaders(nil, MaxHeaders), 6, "Headers run up to the tip")
+}
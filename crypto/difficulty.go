@@ -0,0 +1,119 @@
+package crypto
+
+import (
+	"log"
+	"math/big"
+	"time"
+)
+
+// The most the target can change by in a single adjustment.
+const maxRetargetFactor = 4
+
+// CompactToBig converts a target in its compact "bits" form to a big int. The
+// compact form holds the size of the target in bytes in its most significant
+// byte and the most significant bytes of the target in the remaining three.
+// The top bit of the mantissa is a sign bit, so the result may be negative.
+func CompactToBig(compact uint32) *big.Int {
+	mantissa := compact & 0x007fffff
+	exponent := uint(compact >> 24)
+
+	var n *big.Int
+	if exponent <= 3 {
+		mantissa >>= 8 * (3 - exponent)
+		n = big.NewInt(int64(mantissa))
+	} else {
+		n = big.NewInt(int64(mantissa))
+		n.Lsh(n, 8*(exponent-3))
+	}
+
+	if compact&0x00800000 != 0 {
+		n.Neg(n)
+	}
+
+	return n
+}
+
+// validTarget reports whether a block may have a target, which must be above
+// zero and no easier than the network allows.
+func validTarget(target *big.Int) bool {
+	return target.Sign() > 0 && target.Cmp(powLimit()) <= 0
+}
+
+// BigToCompact converts a non-negative target to its compact "bits" form. The
+// conversion loses the precision beyond the three most significant bytes.
+func BigToCompact(n *big.Int) uint32 {
+	var mantissa uint32
+	exponent := uint(len(n.Bytes()))
+
+	if exponent <= 3 {
+		mantissa = uint32(n.Uint64()) << (8 * (3 - exponent))
+	} else {
+		mantissa = uint32(new(big.Int).Rsh(n, 8*(exponent-3)).Uint64())
+	}
+
+	// The mantissa is signed, so keep its top bit clear.
+	if mantissa&0x00800000 != 0 {
+		mantissa >>= 8
+		exponent++
+	}
+
+	return uint32(exponent<<24) | mantissa
+}
+
+// requiredBits returns the target a block built on top of parent must have.
+// Every RetargetInterval blocks of the network the target is scaled by how long
+// the previous window of blocks took compared to how long it should have taken.
+// A parent with a target no block could have is followed by the easiest target.
+func (bc *Blockchain) requiredBits(parent *Block) uint32 {
+	if !validTarget(CompactToBig(parent.Bits)) {
+		return Net.PowLimitBits
+	}
+
+	height := parent.Height + 1
+	if Net.NoRetargeting || height%Net.RetargetInterval != 0 {
+		return parent.Bits
+	}
+
+	// Find the last block of the previous window. The first window is
+	// measured from the genesis block.
+	first := *parent
+	for first.Height > 0 && first.Height > parent.Height-Net.RetargetInterval {
+		var err error
+
+		first, err = bc.GetBlock(first.PrevBlockHash)
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+
+	blocks := int64(parent.Height - first.Height)
+	if blocks == 0 {
+		return parent.Bits
+	}
+
+	targetTimespan := int64(Net.TargetSpacing/time.Second) * blocks
+	actualTimespan := parent.Timestamp.Unix() - first.Timestamp.Unix()
+
+	// Limit the adjustment so the target can't swing wildly.
+	if actualTimespan < targetTimespan/maxRetargetFactor {
+		actualTimespan = targetTimespan / maxRetargetFactor
+	}
+	if actualTimespan > targetTimespan*maxRetargetFactor {
+		actualTimespan = targetTimespan * maxRetargetFactor
+	}
+
+	target := CompactToBig(parent.Bits)
+	target.Mul(target, big.NewInt(actualTimespan))
+	target.Div(target, big.NewInt(targetTimespan))
+
+	if limit := powLimit(); target.Cmp(limit) > 0 {
+		target.Set(limit)
+	}
+
+	// The hardest target is 1, as nothing hashes below 0.
+	if target.Sign() <= 0 {
+		target.SetInt64(1)
+	}
+
+	return BigToCompact(target)
+}
@@ -0,0 +1,35 @@
+package crypto
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompactToBig(t *testing.T) {
+	expected := new(big.Int).Lsh(big.NewInt(0xffff), 224)
+
+	assert.Equal(t, expected, CompactToBig(0x1f00ffff), "Compact target is expanded")
+	assert.Equal(t, big.NewInt(0x12), CompactToBig(0x01120000), "Small compact target is expanded")
+	assert.Equal(t, big.NewInt(0), CompactToBig(0), "Zero compact target is expanded")
+	assert.Equal(t, big.NewInt(-0x12), CompactToBig(0x01920000), "Sign bit makes the target negative")
+}
+
+func TestBigToCompact(t *testing.T) {
+	n := new(big.Int).Lsh(big.NewInt(0xffff), 224)
+
+	assert.Equal(t, "1f00ffff", fmt.Sprintf("%08x", BigToCompact(n)), "Target is compacted")
+	assert.Equal(t, "01120000", fmt.Sprintf("%08x", BigToCompact(big.NewInt(0x12))), "Small target is compacted")
+	assert.Equal(t, "02008000", fmt.Sprintf("%08x", BigToCompact(big.NewInt(0x80))), "Sign bit is kept clear")
+}
+
+func TestRequiredBitsInvalidParent(t *testing.T) {
+	bc := &Blockchain{}
+
+	for _, bits := range []uint32{0, 0x01920000, 0x2100ffff} {
+		parent := &Block{BlockHeader: BlockHeader{Bits: bits}}
+		assert.Equal(t, Net.PowLimitBits, bc.requiredBits(parent), "Invalid target %08x is followed by the easiest", bits)
+	}
+}
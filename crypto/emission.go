@@ -0,0 +1,75 @@
+package crypto
+
+import "github.com/danmrichards/yagocoin/chaincfg"
+
+// EmissionSchedule describes how much a miner is paid for each block, before
+// fees. The subsidy halves every HalvingInterval blocks and is rounded down to
+// a multiple of MinUnit, so it stops entirely once it drops below MinUnit.
+type EmissionSchedule struct {
+	InitialReward   int // The subsidy for the genesis block.
+	HalvingInterval int // The number of blocks between each halving.
+	MinUnit         int // The smallest amount of subsidy that can be paid.
+}
+
+// Emission is the schedule used to pay for blocks. It is set by SetNetwork and
+// can be changed before a blockchain is created or opened.
+var Emission = newEmissionSchedule(Net)
+
+// newEmissionSchedule returns the emission schedule of a network.
+func newEmissionSchedule(params *chaincfg.Params) EmissionSchedule {
+	return EmissionSchedule{
+		InitialReward:   params.InitialReward,
+		HalvingInterval: params.HalvingInterval,
+		MinUnit:         params.MinUnit,
+	}
+}
+
+// Subsidy returns the subsidy for a block at the given height.
+func (e EmissionSchedule) Subsidy(height int) int {
+	halvings := uint(height / e.HalvingInterval)
+	if halvings >= 63 {
+		return 0
+	}
+
+	reward := e.InitialReward >> halvings
+
+	return reward / e.MinUnit * e.MinUnit
+}
+
+// Supply returns the total subsidy paid for all blocks up to and including the
+// given height.
+func (e EmissionSchedule) Supply(height int) int {
+	supply := 0
+
+	for start := 0; start <= height; start += e.HalvingInterval {
+		reward := e.Subsidy(start)
+		if reward == 0 {
+			break
+		}
+
+		blocks := e.HalvingInterval
+		if height-start+1 < blocks {
+			blocks = height - start + 1
+		}
+
+		supply += blocks * reward
+	}
+
+	return supply
+}
+
+// MaxSupply returns the total subsidy that will ever be paid.
+func (e EmissionSchedule) MaxSupply() int {
+	supply := 0
+
+	for halvings := uint(0); halvings < 63; halvings++ {
+		reward := e.Subsidy(int(halvings) * e.HalvingInterval)
+		if reward == 0 {
+			break
+		}
+
+		supply += e.HalvingInterval * reward
+	}
+
+	return supply
+}
@@ -0,0 +1,27 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmissionScheduleSubsidy(t *testing.T) {
+	e := EmissionSchedule{InitialReward: 50, HalvingInterval: 10, MinUnit: 2}
+
+	assert.Equal(t, 50, e.Subsidy(0), "Genesis subsidy is the initial reward")
+	assert.Equal(t, 50, e.Subsidy(9), "Subsidy is constant until the first halving")
+	assert.Equal(t, 24, e.Subsidy(10), "Subsidy is halved and rounded to the minimum unit")
+	assert.Equal(t, 12, e.Subsidy(20), "Subsidy is halved again")
+	assert.Equal(t, 0, e.Subsidy(50), "Subsidy below the minimum unit is not paid")
+	assert.Equal(t, 0, e.Subsidy(10000), "Subsidy stays at zero")
+}
+
+func TestEmissionScheduleSupply(t *testing.T) {
+	e := EmissionSchedule{InitialReward: 50, HalvingInterval: 10, MinUnit: 2}
+
+	assert.Equal(t, 50, e.Supply(0), "Supply includes the genesis block")
+	assert.Equal(t, 500+24*3, e.Supply(12), "Supply spans halvings")
+	assert.Equal(t, 500+240+120+60+20, e.MaxSupply(), "Supply is capped")
+	assert.Equal(t, e.MaxSupply(), e.Supply(10000), "Supply stops at the cap")
+}
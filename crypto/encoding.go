@@ -0,0 +1,482 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/danmrichards/yagocoin/script"
+)
+
+// Blocks, transactions and unspent outputs are stored and hashed using the
+// canonical binary encoding described here, so that every implementation
+// produces exactly the same bytes for the same data.
+//
+// The encoding is built from the following values:
+//
+//	uvarint  unsigned LEB128, as written by binary.PutUvarint
+//	varint   zig-zag signed LEB128, as written by binary.PutVarint
+//	uint32   4 bytes, little endian
+//	bytes    uvarint length followed by that many bytes
+//
+// Varints must use the fewest bytes possible. Anything else is rejected when
+// decoding, as is trailing data.
+//
+// A Transaction is encoded as:
+//
+//	uvarint  format version, currently 3
+//	bytes    ID
+//	uvarint  number of inputs, followed by each TxInput
+//	uvarint  number of outputs, followed by each TxOutput
+//	uvarint  LockTime
+//
+// A TxInput is encoded as:
+//
+//	bytes    Txid
+//	varint   Vout
+//	bytes    ScriptSig
+//	uvarint  Sequence
+//
+// Transactions with no LockTime whose inputs all have the SequenceFinal
+// sequence are written in version 2, which leaves out the LockTime and
+// Sequence fields, so the IDs of transactions made before lock times existed
+// don't change. Version 3 is rejected for them, as there must only be one
+// encoding.
+//
+// A TxOutput is encoded as:
+//
+//	varint   Value
+//	bytes    ScriptPubKey
+//
+// Version 1 transactions, written before outputs were locked with scripts,
+// hold a Signature and PubKey in each input and a PubKeyHash in each output.
+// They are read as pay to public key hash scripts, keeping their IDs. As with
+// migratedb, old blocks converted this way can be read but not revalidated by
+// nodes syncing them.
+//
+// A BlockHeader is encoded as:
+//
+//	varint   Version
+//	bytes    PrevBlockHash
+//	bytes    MerkleRoot
+//	varint   Timestamp, in seconds since the Unix epoch
+//	uint32   Bits
+//	uint32   Nonce
+//
+// The Nonce comes last so a miner can change it without encoding the rest of
+// the header again.
+//
+// A Block is encoded as:
+//
+//	uvarint  format version, currently 2
+//	BlockHeader
+//	bytes    Hash
+//	uvarint  Height
+//	uvarint  number of transactions, followed by each Transaction
+//
+// Blocks of any other version are rejected.
+//
+// The unspent outputs of a transaction, TxOutputs, are encoded as:
+//
+//	uvarint  format version, currently 3
+//	uvarint  Height of the block holding the transaction
+//	uvarint  1 if the transaction is a coinbase, 0 otherwise
+//	uvarint  number of outputs, followed by each output as
+//	uvarint  index of the output in its transaction, in ascending order
+//	TxOutput the output
+//
+// Version 1 TxOutputs, written before coinbase maturity was enforced, have no
+// Height or coinbase flag. Versions 1 and 2 hold outputs in the version 1
+// transaction format.
+//
+// The ID of a transaction is the SHA-256 hash of its encoding with an empty
+// ID, and the hash of a block is the SHA-256 hash of its header. Block undo
+// data uses the same building blocks, see undo.go.
+
+const (
+	// The current version of the encoding.
+	encodingVersion = 1
+
+	// The current version of the transaction encoding.
+	txEncodingVersion = 3
+
+	// The current version of the block encoding.
+	blockEncodingVersion = 2
+
+	// The current version of the unspent outputs and undo data encodings.
+	utxoEncodingVersion = 3
+
+	// The largest byte string we'll decode.
+	maxBytesLen = 1 << 20
+)
+
+var errNonCanonical = errors.New("value is not canonically encoded")
+
+// encoder writes values in the canonical encoding.
+type encoder struct {
+	bytes.Buffer
+}
+
+// uvarint writes an unsigned varint.
+func (e *encoder) uvarint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+
+	n := binary.PutUvarint(buf[:], v)
+	e.Write(buf[:n])
+}
+
+// varint writes a signed varint.
+func (e *encoder) varint(v int64) {
+	var buf [binary.MaxVarintLen64]byte
+
+	n := binary.PutVarint(buf[:], v)
+	e.Write(buf[:n])
+}
+
+// uint32 writes a fixed size 32 bit integer.
+func (e *encoder) uint32(v uint32) {
+	var buf [4]byte
+
+	binary.LittleEndian.PutUint32(buf[:], v)
+	e.Write(buf[:])
+}
+
+// bytes writes a length prefixed byte string.
+func (e *encoder) bytes(b []byte) {
+	e.uvarint(uint64(len(b)))
+	e.Write(b)
+}
+
+// bool writes a boolean as a uvarint of 1 or 0.
+func (e *encoder) bool(b bool) {
+	if b {
+		e.uvarint(1)
+	} else {
+		e.uvarint(0)
+	}
+}
+
+// decoder reads values in the canonical encoding. The first error encountered
+// is kept and every read after it returns the zero value.
+type decoder struct {
+	data []byte
+	err  error
+}
+
+// fail records an error if there isn't one already.
+func (d *decoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+// uvarint reads an unsigned varint.
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.fail(errors.New("invalid varint"))
+		return 0
+	}
+
+	// Reject padded encodings which would give the same value.
+	var buf [binary.MaxVarintLen64]byte
+	if binary.PutUvarint(buf[:], v) != n {
+		d.fail(errNonCanonical)
+		return 0
+	}
+
+	d.data = d.data[n:]
+
+	return v
+}
+
+// varint reads a signed varint.
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+
+	v, n := binary.Varint(d.data)
+	if n <= 0 {
+		d.fail(errors.New("invalid varint"))
+		return 0
+	}
+
+	var buf [binary.MaxVarintLen64]byte
+	if binary.PutVarint(buf[:], v) != n {
+		d.fail(errNonCanonical)
+		return 0
+	}
+
+	d.data = d.data[n:]
+
+	return v
+}
+
+// uint32 reads a fixed size 32 bit integer.
+func (d *decoder) uint32() uint32 {
+	if d.err != nil {
+		return 0
+	}
+
+	if len(d.data) < 4 {
+		d.fail(errors.New("unexpected end of data"))
+		return 0
+	}
+
+	v := binary.LittleEndian.Uint32(d.data)
+	d.data = d.data[4:]
+
+	return v
+}
+
+// uint32v reads an unsigned varint which must fit in 32 bits.
+func (d *decoder) uint32v() uint32 {
+	v := d.uvarint()
+	if v > math.MaxUint32 {
+		d.fail(fmt.Errorf("value %d is too large", v))
+		return 0
+	}
+
+	return uint32(v)
+}
+
+// bytes reads a length prefixed byte string.
+func (d *decoder) bytes() []byte {
+	n := d.uvarint()
+	if d.err != nil {
+		return nil
+	}
+
+	if n > maxBytesLen || n > uint64(len(d.data)) {
+		d.fail(fmt.Errorf("invalid length %d", n))
+		return nil
+	}
+
+	b := make([]byte, n)
+	copy(b, d.data)
+	d.data = d.data[n:]
+
+	return b
+}
+
+// count reads the number of items in a list. Each item takes at least one
+// byte, so a count larger than the remaining data can't be valid.
+func (d *decoder) count() int {
+	n := d.uvarint()
+	if d.err != nil {
+		return 0
+	}
+
+	if n > uint64(len(d.data)) {
+		d.fail(fmt.Errorf("invalid count %d", n))
+		return 0
+	}
+
+	return int(n)
+}
+
+// bool reads a boolean written as a uvarint of 1 or 0.
+func (d *decoder) bool() bool {
+	v := d.uvarint()
+	if v > 1 {
+		d.fail(errNonCanonical)
+	}
+
+	return v == 1
+}
+
+// version reads a format version and checks it is one we understand, from 1
+// up to latest.
+func (d *decoder) version(latest uint64) uint64 {
+	v := d.uvarint()
+	if d.err == nil && (v == 0 || v > latest) {
+		d.fail(fmt.Errorf("unknown encoding version %d", v))
+	}
+
+	return v
+}
+
+// finish returns the first error encountered, or an error if any data was
+// left unread.
+func (d *decoder) finish() error {
+	if d.err == nil && len(d.data) > 0 {
+		d.fail(errors.New("unexpected trailing data"))
+	}
+
+	return d.err
+}
+
+// transaction writes a transaction.
+func (e *encoder) transaction(tx *Transaction) {
+	version := uint64(txEncodingVersion)
+	if !tx.hasLocks() {
+		version = 2
+	}
+
+	e.uvarint(version)
+	e.bytes(tx.ID)
+
+	e.uvarint(uint64(len(tx.Vin)))
+	for _, vin := range tx.Vin {
+		e.bytes(vin.Txid)
+		e.varint(int64(vin.Vout))
+		e.bytes(vin.ScriptSig)
+		if version >= 3 {
+			e.uvarint(uint64(vin.Sequence))
+		}
+	}
+
+	e.uvarint(uint64(len(tx.Vout)))
+	for _, vout := range tx.Vout {
+		e.output(vout)
+	}
+
+	if version >= 3 {
+		e.uvarint(uint64(tx.LockTime))
+	}
+}
+
+// output writes a transaction output.
+func (e *encoder) output(out TxOutput) {
+	e.varint(int64(out.Value))
+	e.bytes(out.ScriptPubKey)
+}
+
+// transaction reads a transaction.
+func (d *decoder) transaction() *Transaction {
+	tx := &Transaction{}
+
+	version := d.version(txEncodingVersion)
+	tx.ID = d.bytes()
+
+	for i, n := 0, d.count(); i < n; i++ {
+		vin := TxInput{Sequence: SequenceFinal}
+
+		vin.Txid = d.bytes()
+		vin.Vout = int(d.varint())
+		if version >= 2 {
+			vin.ScriptSig = d.bytes()
+		} else {
+			sig := d.bytes()
+			vin.ScriptSig = legacyScriptSig(vin, sig, d.bytes())
+		}
+		if version >= 3 {
+			vin.Sequence = d.uint32v()
+		}
+
+		tx.Vin = append(tx.Vin, vin)
+	}
+
+	for i, n := 0, d.count(); i < n; i++ {
+		if version >= 2 {
+			tx.Vout = append(tx.Vout, d.output())
+		} else {
+			tx.Vout = append(tx.Vout, d.legacyOutput())
+		}
+	}
+
+	if version >= 3 {
+		tx.LockTime = d.uint32v()
+
+		if !tx.hasLocks() {
+			d.fail(errNonCanonical)
+		}
+	}
+
+	return tx
+}
+
+// hasLocks reports whether a transaction has a lock time or an input which
+// isn't final, and so needs version 3 of the encoding.
+func (tx *Transaction) hasLocks() bool {
+	if tx.LockTime != 0 {
+		return true
+	}
+
+	for _, vin := range tx.Vin {
+		if vin.Sequence != SequenceFinal {
+			return true
+		}
+	}
+
+	return false
+}
+
+// output reads a transaction output.
+func (d *decoder) output() TxOutput {
+	var out TxOutput
+
+	out.Value = int(d.varint())
+	out.ScriptPubKey = d.bytes()
+
+	return out
+}
+
+// legacyOutput reads a version 1 transaction output, locked to a public key
+// hash, as a pay to public key hash script.
+func (d *decoder) legacyOutput() TxOutput {
+	var out TxOutput
+
+	out.Value = int(d.varint())
+	out.ScriptPubKey = legacyScriptPubKey(d.bytes())
+
+	return out
+}
+
+// utxoOutput reads an output written by the given version of the unspent
+// outputs encoding.
+func (d *decoder) utxoOutput(version uint64) TxOutput {
+	if version >= 3 {
+		return d.output()
+	}
+
+	return d.legacyOutput()
+}
+
+// legacyScriptSig returns the signature script of an input which held a
+// signature and public key. Coinbase inputs keep their data as it was.
+func legacyScriptSig(in TxInput, sig, pubKey []byte) []byte {
+	if len(in.Txid) == 0 && in.Vout == -1 {
+		return pubKey
+	}
+
+	return script.SignatureScript(sig, pubKey)
+}
+
+// legacyScriptPubKey returns the script of an output which was locked to a
+// public key hash.
+func legacyScriptPubKey(pubKeyHash []byte) []byte {
+	return script.PayToPubKeyHash(pubKeyHash)
+}
+
+// header writes a block header.
+func (e *encoder) header(h *BlockHeader) {
+	e.varint(int64(h.Version))
+	e.bytes(h.PrevBlockHash)
+	e.bytes(h.MerkleRoot)
+	e.varint(h.Timestamp.Unix())
+	e.uint32(h.Bits)
+	e.uint32(h.Nonce)
+}
+
+// header reads a block header.
+func (d *decoder) header() BlockHeader {
+	var h BlockHeader
+
+	h.Version = int(d.varint())
+	h.PrevBlockHash = d.bytes()
+	h.MerkleRoot = d.bytes()
+	h.Timestamp = time.Unix(d.varint(), 0)
+	h.Bits = d.uint32()
+	h.Nonce = d.uint32()
+
+	return h
+}
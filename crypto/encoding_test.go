@@ -0,0 +1,112 @@
+package crypto
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionEncoding(t *testing.T) {
+	tx := Transaction{
+		Vin:  []TxInput{{[]byte{1, 2, 3}, 1, []byte("signature script"), SequenceFinal}},
+		Vout: []TxOutput{{42, []byte("public key script")}, {-1, nil}},
+	}
+	tx.ID = tx.Hash()
+
+	decoded, err := DeserializeTransaction(tx.Serialize())
+
+	assert.Nil(t, err, "Transaction is decoded")
+	assert.Equal(t, tx.Serialize(), decoded.Serialize(), "Transaction round trips")
+	assert.Equal(t, tx.ID, decoded.Hash(), "Transaction hash is stable")
+	assert.Equal(t, byte(2), tx.Serialize()[0], "Transaction without locks is version 2")
+
+	tx.LockTime = 100
+	tx.Vin[0].Sequence = RelativeLockBlocks(5)
+	tx.ID = tx.Hash()
+
+	decoded, err = DeserializeTransaction(tx.Serialize())
+
+	assert.Nil(t, err, "Locked transaction is decoded")
+	assert.Equal(t, byte(3), tx.Serialize()[0], "Locked transaction is version 3")
+	assert.Equal(t, tx.LockTime, decoded.LockTime, "Lock time is kept")
+	assert.Equal(t, tx.Vin[0].Sequence, decoded.Vin[0].Sequence, "Sequence is kept")
+}
+
+func TestLegacyTransactionEncoding(t *testing.T) {
+	pubKeyHash := HashPubKey([]byte("public key"))
+
+	// A version 1 transaction, which held keys rather than scripts.
+	e := encoder{}
+	e.uvarint(1)
+	e.bytes([]byte("id"))
+	e.uvarint(1)
+	e.bytes([]byte{1, 2, 3})
+	e.varint(1)
+	e.bytes([]byte("signature"))
+	e.bytes([]byte("public key"))
+	e.uvarint(1)
+	e.varint(42)
+	e.bytes(pubKeyHash)
+
+	decoded, err := DeserializeTransaction(e.Bytes())
+
+	assert.Nil(t, err, "Legacy transaction is decoded")
+	assert.Equal(t, []byte("id"), decoded.ID, "Transaction ID is kept")
+	assert.Equal(t, []byte("public key"), decoded.Vin[0].PubKey(), "Input is converted to a signature script")
+	assert.Equal(t, pubKeyHash, decoded.Vout[0].PubKeyHash(), "Output is converted to pay to public key hash")
+}
+
+func TestBlockEncoding(t *testing.T) {
+	coinbase := &Transaction{Vin: []TxInput{{nil, -1, []byte("data"), SequenceFinal}}, Vout: []TxOutput{{10, []byte("to")}}}
+	coinbase.ID = coinbase.Hash()
+
+	block := &Block{
+		BlockHeader: BlockHeader{
+			Version:       blockVersion,
+			PrevBlockHash: []byte("previous"),
+			Timestamp:     time.Unix(1514764800, 0),
+			Bits:          Net.PowLimitBits,
+			Nonce:         300,
+		},
+		Transactions: []*Transaction{coinbase},
+		Height:       7,
+	}
+	block.MerkleRoot = block.HashTransactions()
+	block.Hash = block.BlockHeader.Hash()
+
+	decoded, err := DeserializeBlock(block.Serialize())
+
+	assert.Nil(t, err, "Block is decoded")
+	assert.Equal(t, block.Serialize(), decoded.Serialize(), "Block round trips")
+	assert.Equal(t, block.Timestamp.Unix(), decoded.Timestamp.Unix(), "Timestamp is kept")
+	assert.Equal(t, coinbase.ID, decoded.Transactions[0].ID, "Transaction ID is kept")
+	assert.Equal(t, block.Hash, decoded.BlockHeader.Hash(), "Header hash is stable")
+}
+
+func TestDecodeRejectsNonCanonical(t *testing.T) {
+	tx := Transaction{Vout: []TxOutput{{1, nil}}}
+	data := tx.Serialize()
+
+	_, err := DeserializeTransaction(append(data, 0))
+	assert.NotNil(t, err, "Trailing data is rejected")
+
+	// The version encoded with a padding byte.
+	_, err = DeserializeTransaction(append([]byte{0x81, 0x00}, data[1:]...))
+	assert.NotNil(t, err, "Padded varint is rejected")
+
+	_, err = DeserializeTransaction(append([]byte{txEncodingVersion + 1}, data[1:]...))
+	assert.NotNil(t, err, "Unknown version is rejected")
+
+	_, err = DeserializeTransaction(data[:len(data)-1])
+	assert.NotNil(t, err, "Truncated data is rejected")
+
+	block := (&Block{Transactions: []*Transaction{&tx}}).Serialize()
+	_, err = DeserializeBlock(append([]byte{blockEncodingVersion - 1}, block[1:]...))
+	assert.NotNil(t, err, "Older block version is rejected")
+
+	// Version 3 with no locks, followed by no inputs, one output and no lock
+	// time.
+	_, err = DeserializeTransaction(append(append([]byte{3}, data[1:]...), 0))
+	assert.NotNil(t, err, "Version 3 without locks is rejected")
+}
@@ -0,0 +1,278 @@
+package crypto
+
+import (
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/tyler-smith/go-bip39"
+)
+
+// HD wallet files derive their keys from a single seed, as described by
+// BIP 32 with the changes SLIP 10 makes for the P-256 curve. The seed comes
+// from a BIP 39 mnemonic, which is all that needs backing up.
+//
+// Keys follow the default BIP 32 wallet layout: addresses handed out to
+// receive coins are m/0'/0/i and addresses for change are m/0'/1/i.
+
+const (
+	// HardenedKeyStart is the index of the first hardened child key.
+	HardenedKeyStart = 0x80000000
+
+	// ReceiveChain and ChangeChain are the chains of keys under the account.
+	ReceiveChain = 0
+	ChangeChain  = 1
+
+	// GapLimit is the number of unused addresses in a row which ends a rescan.
+	GapLimit = 20
+
+	// The entropy of new mnemonics, giving 12 words.
+	mnemonicEntropyBits = 128
+)
+
+// The HMAC key deriving the master key from a seed, set by SLIP 10.
+var masterKeyHMACKey = []byte("Nist256p1 seed")
+
+// extendedKey is a private key together with the chain code needed to derive
+// its children.
+type extendedKey struct {
+	key       []byte
+	chainCode []byte
+}
+
+// newMasterKey derives the master key from a seed.
+func newMasterKey(seed []byte) extendedKey {
+	i := hmacSHA512(masterKeyHMACKey, seed)
+
+	// Try again in the unlikely case the key isn't a valid scalar.
+	for !validScalar(i[:32]) {
+		i = hmacSHA512(masterKeyHMACKey, i)
+	}
+
+	return extendedKey{i[:32], i[32:]}
+}
+
+// child derives the child key at an index. Indexes from HardenedKeyStart up
+// give hardened keys, which can't be derived from the parent public key.
+func (k extendedKey) child(index uint32) extendedKey {
+	var data []byte
+
+	if index >= HardenedKeyStart {
+		data = append([]byte{0}, k.key...)
+	} else {
+		curve := elliptic.P256()
+		x, y := curve.ScalarBaseMult(k.key)
+		data = elliptic.MarshalCompressed(curve, x, y)
+	}
+	var indexBytes [4]byte
+	binary.BigEndian.PutUint32(indexBytes[:], index)
+	data = append(data, indexBytes[:]...)
+
+	n := elliptic.P256().Params().N
+	for {
+		i := hmacSHA512(k.chainCode, data)
+
+		if validScalar(i[:32]) {
+			key := new(big.Int).SetBytes(i[:32])
+			key.Add(key, new(big.Int).SetBytes(k.key))
+			key.Mod(key, n)
+
+			if key.Sign() != 0 {
+				return extendedKey{paddedBytes(key, 32), i[32:]}
+			}
+		}
+
+		// Try again in the unlikely case the key isn't valid.
+		data = append(append([]byte{1}, i[32:]...), indexBytes[:]...)
+	}
+}
+
+// wallet returns the wallet with the key.
+func (k extendedKey) wallet() *Wallet {
+	key := privateKeyFromScalar(k.key)
+
+	return &Wallet{key, pubKeyBytes(&key.PublicKey)}
+}
+
+// hdWallet derives the wallet at an index of a chain from a seed.
+func hdWallet(seed []byte, chain, index uint32) *Wallet {
+	account := newMasterKey(seed).child(HardenedKeyStart)
+
+	return account.child(chain).child(index).wallet()
+}
+
+// validScalar reports whether b is a valid private key for P-256, from 1 to
+// the order of the curve.
+func validScalar(b []byte) bool {
+	k := new(big.Int).SetBytes(b)
+
+	return k.Sign() > 0 && k.Cmp(elliptic.P256().Params().N) < 0
+}
+
+// hmacSHA512 returns the HMAC-SHA512 of data.
+func hmacSHA512(key, data []byte) []byte {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(data)
+
+	return mac.Sum(nil)
+}
+
+// NewMnemonic returns a new random BIP 39 mnemonic.
+func NewMnemonic() (string, error) {
+	entropy, err := bip39.NewEntropy(mnemonicEntropyBits)
+	if err != nil {
+		return "", err
+	}
+
+	return bip39.NewMnemonic(entropy)
+}
+
+// normalizeMnemonic checks a mnemonic, returning it with single spaces
+// between the words.
+func normalizeMnemonic(mnemonic string) (string, error) {
+	mnemonic = strings.Join(strings.Fields(strings.ToLower(mnemonic)), " ")
+	if !bip39.IsMnemonicValid(mnemonic) {
+		return "", errors.New("invalid mnemonic")
+	}
+
+	return mnemonic, nil
+}
+
+// hdState is the HD seed of a wallet file, along with how many keys of each
+// chain have been handed out.
+type hdState struct {
+	// Empty while the wallet file is locked.
+	mnemonic string
+	next     [2]uint32
+}
+
+// seed returns the seed of the mnemonic.
+func (hd *hdState) seed() []byte {
+	return bip39.NewSeed(hd.mnemonic, "")
+}
+
+// IsHD reports whether the wallet file derives its keys from a mnemonic.
+func (ws *Wallets) IsHD() bool {
+	return ws.hd != nil
+}
+
+// SetMnemonic makes the wallet file derive new keys from a mnemonic. Keys
+// already in it are kept. An encrypted wallet file must be unlocked first.
+func (ws *Wallets) SetMnemonic(mnemonic string) error {
+	if ws.hd != nil {
+		return errors.New("wallet already has a mnemonic")
+	}
+
+	if ws.IsLocked() {
+		return ErrWalletLocked
+	}
+
+	mnemonic, err := normalizeMnemonic(mnemonic)
+	if err != nil {
+		return err
+	}
+
+	ws.hd = &hdState{mnemonic: mnemonic}
+
+	if ws.encryption != nil {
+		ws.sealKeys()
+	}
+
+	return nil
+}
+
+// Mnemonic returns the mnemonic of an HD wallet file. An encrypted wallet file
+// must be unlocked first.
+func (ws *Wallets) Mnemonic() (string, error) {
+	if ws.hd == nil {
+		return "", errors.New("wallet has no mnemonic")
+	}
+
+	if ws.IsLocked() {
+		return "", ErrWalletLocked
+	}
+
+	return ws.hd.mnemonic, nil
+}
+
+// NewChangeAddress adds the next wallet of the change chain of an HD wallet
+// file, returning its address.
+func (ws *Wallets) NewChangeAddress() (string, error) {
+	if ws.hd == nil {
+		return "", errors.New("wallet has no mnemonic")
+	}
+
+	return ws.deriveWallet(ChangeChain)
+}
+
+// deriveWallet adds the next wallet of a chain.
+func (ws *Wallets) deriveWallet(chain uint32) (string, error) {
+	if ws.IsLocked() {
+		return "", ErrWalletLocked
+	}
+
+	wallet := hdWallet(ws.hd.seed(), chain, ws.hd.next[chain])
+	address := string(wallet.GetAddress())
+
+	ws.Wallets[address] = wallet
+	ws.hd.next[chain]++
+
+	if ws.encryption != nil {
+		ws.sealKeys()
+	}
+
+	return address, nil
+}
+
+// Rescan derives the keys of each chain of an HD wallet file until GapLimit
+// addresses in a row haven't been used, adding every wallet up to the last
+// used one. Used reports whether coins were ever paid to a public key hash.
+// The number of used addresses found is returned.
+func (ws *Wallets) Rescan(used func(pubKeyHash []byte) bool) (int, error) {
+	if ws.hd == nil {
+		return 0, errors.New("wallet has no mnemonic")
+	}
+
+	if ws.IsLocked() {
+		return 0, ErrWalletLocked
+	}
+
+	seed := ws.hd.seed()
+	found := 0
+
+	for _, chain := range []uint32{ReceiveChain, ChangeChain} {
+		var derived []*Wallet
+
+		for index, gap := uint32(0), 0; gap < GapLimit; index++ {
+			wallet := hdWallet(seed, chain, index)
+			derived = append(derived, wallet)
+
+			if !used(HashPubKey(wallet.PublicKey)) {
+				gap++
+				continue
+			}
+
+			found++
+			gap = 0
+
+			for _, w := range derived {
+				ws.Wallets[string(w.GetAddress())] = w
+			}
+			derived = nil
+
+			if ws.hd.next[chain] <= index {
+				ws.hd.next[chain] = index + 1
+			}
+		}
+	}
+
+	if ws.encryption != nil {
+		ws.sealKeys()
+	}
+
+	return found, nil
+}
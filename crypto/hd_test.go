@@ -0,0 +1,111 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyDerivation(t *testing.T) {
+	// Test vector 1 for nist256p1 from SLIP 10.
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	tests := []struct {
+		path      []uint32
+		chainCode string
+		key       string
+	}{
+		{
+			nil,
+			"beeb672fe4621673f722f38529c07392fecaa61015c80c34f29ce8b41b3cb6ea",
+			"612091aaa12e22dd2abef664f8a01a82cae99ad7441b7ef8110424915c268bc2",
+		},
+		{
+			[]uint32{HardenedKeyStart},
+			"3460cea53e6a6bb5fb391eeef3237ffd8724bf0a40e94943c98b83825342ee11",
+			"6939694369114c67917a182c59ddb8cafc3004e63ca5d3b84403ba8613debc0c",
+		},
+		{
+			[]uint32{HardenedKeyStart, 1},
+			"4187afff1aafa8445010097fb99d23aee9f599450c7bd140b6826ac22ba21d0c",
+			"284e9d38d07d21e4e281b645089a94f4cf5a5a81369acf151a1c3a57f18b2129",
+		},
+		{
+			[]uint32{HardenedKeyStart, 1, HardenedKeyStart + 2},
+			"98c7514f562e64e74170cc3cf304ee1ce54d6b6da4f880f313e8204c2a185318",
+			"694596e8a54f252c960eb771a3c41e7e32496d03b954aeb90f61635b8e092aa7",
+		},
+	}
+
+	for _, test := range tests {
+		key := newMasterKey(seed)
+		for _, index := range test.path {
+			key = key.child(index)
+		}
+
+		assert.Equal(t, test.chainCode, hex.EncodeToString(key.chainCode), "Chain code of %v is derived", test.path)
+		assert.Equal(t, test.key, hex.EncodeToString(key.key), "Key of %v is derived", test.path)
+	}
+}
+
+func TestHDWalletFile(t *testing.T) {
+	inTempDir(t)
+
+	mnemonic, err := NewMnemonic()
+	assert.NoError(t, err, "Mnemonic is created")
+
+	ws, _ := NewWallets("test")
+	assert.NoError(t, ws.SetMnemonic(mnemonic), "Mnemonic is set")
+
+	first, _ := ws.CreateWallet()
+	second, _ := ws.CreateWallet()
+	change, _ := ws.NewChangeAddress()
+	seed := ws.hd.seed()
+	assert.Equal(t, string(hdWallet(seed, ReceiveChain, 0).GetAddress()), first, "First receive key is derived")
+	assert.Equal(t, string(hdWallet(seed, ReceiveChain, 1).GetAddress()), second, "Second receive key is derived")
+	assert.Equal(t, string(hdWallet(seed, ChangeChain, 0).GetAddress()), change, "Change key is derived")
+
+	assert.NoError(t, ws.Encrypt([]byte("secret")), "Wallet file is encrypted")
+	ws.SaveToFile("test")
+
+	ws, _ = NewWallets("test")
+	_, err = ws.Mnemonic()
+	assert.Equal(t, ErrWalletLocked, err, "Mnemonic is encrypted")
+	assert.NoError(t, ws.Unlock([]byte("secret")), "Wallet file is unlocked")
+
+	kept, _ := ws.Mnemonic()
+	assert.Equal(t, mnemonic, kept, "Mnemonic is kept")
+
+	third, _ := ws.CreateWallet()
+	assert.Equal(t, string(hdWallet(seed, ReceiveChain, 2).GetAddress()), third, "Receive chain carries on")
+}
+
+func TestRescan(t *testing.T) {
+	mnemonic, _ := NewMnemonic()
+	ws := &Wallets{Wallets: make(map[string]*Wallet)}
+	ws.SetMnemonic(mnemonic)
+	seed := ws.hd.seed()
+
+	// Receive keys 3 and 4+GapLimit are used, the second just past the gap.
+	used := [][]byte{
+		HashPubKey(hdWallet(seed, ReceiveChain, 3).PublicKey),
+		HashPubKey(hdWallet(seed, ReceiveChain, 4+GapLimit).PublicKey),
+		HashPubKey(hdWallet(seed, ChangeChain, 0).PublicKey),
+	}
+
+	found, err := ws.Rescan(func(pubKeyHash []byte) bool {
+		for _, hash := range used {
+			if bytes.Equal(hash, pubKeyHash) {
+				return true
+			}
+		}
+
+		return false
+	})
+
+	assert.NoError(t, err, "Wallet file is rescanned")
+	assert.Equal(t, 2, found, "Used addresses within the gap limit are found")
+	assert.Len(t, ws.Wallets, 5, "Keys up to the last used ones are added")
+	assert.Equal(t, [2]uint32{4, 1}, ws.hd.next, "Chains carry on after the last used keys")
+}
@@ -0,0 +1,154 @@
+package crypto
+
+import (
+	"encoding/binary"
+	"errors"
+	"log"
+
+	"github.com/boltdb/bolt"
+)
+
+const heightIndexBucket = "heights"
+
+// GetBlockHashByHeight returns the hash of the block at the given height of the
+// main chain.
+func (bc *Blockchain) GetBlockHashByHeight(height int) ([]byte, error) {
+	var hash []byte
+
+	err := bc.db.View(func(tx *bolt.Tx) error {
+		if height < 0 {
+			return errors.New("block is not found")
+		}
+
+		h := tx.Bucket([]byte(heightIndexBucket)).Get(heightKey(height))
+		if h == nil {
+			return errors.New("block is not found")
+		}
+		hash = append([]byte{}, h...)
+
+		return nil
+	})
+
+	return hash, err
+}
+
+// GetBlockByHeight returns the block at the given height of the main chain.
+func (bc *Blockchain) GetBlockByHeight(height int) (Block, error) {
+	hash, err := bc.GetBlockHashByHeight(height)
+	if err != nil {
+		return Block{}, err
+	}
+
+	return bc.GetBlock(hash)
+}
+
+// RangeIterator iterates forward over the blocks of the main chain between
+// two heights.
+type RangeIterator struct {
+	bc   *Blockchain
+	next int
+	to   int
+}
+
+// Range returns an iterator over the blocks of the main chain from height
+// from up to and including height to, oldest first. The range is cut short at
+// the tip.
+func (bc *Blockchain) Range(from, to int) *RangeIterator {
+	if from < 0 {
+		from = 0
+	}
+
+	return &RangeIterator{bc, from, to}
+}
+
+// Next returns the next block in the range, or nil once the range is done.
+func (i *RangeIterator) Next() *Block {
+	if i.next > i.to {
+		return nil
+	}
+
+	block, err := i.bc.GetBlockByHeight(i.next)
+	if err != nil {
+		return nil
+	}
+	i.next++
+
+	return &block
+}
+
+// ReindexHeights rebuilds the height index from the main chain and returns
+// the number of blocks indexed.
+func (bc *Blockchain) ReindexHeights() int {
+	count := 0
+
+	err := bc.db.Update(func(tx *bolt.Tx) error {
+		err := tx.DeleteBucket([]byte(heightIndexBucket))
+		if err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+
+		tip := tx.Bucket([]byte(blocksBucket)).Get([]byte(hashKey))
+		count, err = indexChainHeights(tx, tip)
+
+		return err
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return count
+}
+
+// indexChainHeights creates the height index and fills it with the blocks of
+// the main chain ending at tip.
+func indexChainHeights(tx *bolt.Tx, tip []byte) (int, error) {
+	count := 0
+	b := tx.Bucket([]byte(blocksBucket))
+
+	for hash := tip; len(hash) > 0; {
+		block, err := DeserializeBlock(b.Get(hash))
+		if err != nil {
+			return count, err
+		}
+
+		err = indexHeight(tx, block)
+		if err != nil {
+			return count, err
+		}
+
+		count++
+		hash = block.PrevBlockHash
+	}
+
+	return count, nil
+}
+
+// indexHeight records a block joining the main chain in the height index.
+func indexHeight(tx *bolt.Tx, block *Block) error {
+	h, err := tx.CreateBucketIfNotExists([]byte(heightIndexBucket))
+	if err != nil {
+		return err
+	}
+
+	return h.Put(heightKey(block.Height), block.Hash)
+}
+
+// unindexHeight removes a block leaving the main chain from the height index.
+func unindexHeight(tx *bolt.Tx, block *Block) error {
+	h := tx.Bucket([]byte(heightIndexBucket))
+	if h == nil {
+		return nil
+	}
+
+	return h.Delete(heightKey(block.Height))
+}
+
+// heightKey returns the height index key for a height. Keys are big endian so
+// a cursor visits heights in order.
+func heightKey(height int) []byte {
+	var key [4]byte
+
+	binary.BigEndian.PutUint32(key[:], uint32(height))
+
+	return key[:]
+}
@@ -0,0 +1,40 @@
+package crypto
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetBlockByHeight(t *testing.T) {
+	a := NewWallet()
+	bc := newTestChain(t, a)
+
+	var mined []*Block
+	for i := 0; i < 3; i++ {
+		block, err := bc.MineBlock(context.Background(), []*Transaction{NewCoinbaseTx(string(a.GetAddress()), "", i+1, 0)})
+		assert.NoError(t, err, "Block is mined")
+		mined = append(mined, block)
+	}
+
+	block, err := bc.GetBlockByHeight(2)
+	assert.NoError(t, err, "Block is found by height")
+	assert.Equal(t, mined[1].Hash, block.Hash, "Block at the height is returned")
+
+	_, err = bc.GetBlockByHeight(4)
+	assert.Error(t, err, "Height above the tip is not found")
+
+	var heights []int
+	for i := bc.Range(2, 10); ; {
+		block := i.Next()
+		if block == nil {
+			break
+		}
+		heights = append(heights, block.Height)
+	}
+	assert.Equal(t, []int{2, 3}, heights, "Range stops at the tip")
+
+	assert.Len(t, bc.GetBlockHashes(), 4, "Every block is listed")
+	assert.Equal(t, 4, bc.ReindexHeights(), "Every block is indexed")
+}
@@ -0,0 +1,148 @@
+package crypto
+
+import (
+	"log"
+	"time"
+)
+
+const (
+	// Lock times below the threshold are block heights, the rest are Unix
+	// times.
+	LockTimeThreshold = 500000000
+
+	// SequenceFinal is the sequence of an input with no relative lock. Once
+	// every input is final, the lock time of a transaction no longer applies.
+	SequenceFinal uint32 = 0xffffffff
+
+	// The bit of a sequence which is set when it holds no relative lock.
+	sequenceLockDisabled uint32 = 1 << 31
+
+	// The bit of a sequence which is set when its relative lock is a time
+	// rather than a number of blocks.
+	sequenceLockIsTime uint32 = 1 << 22
+
+	// The bits of a sequence holding the relative lock.
+	sequenceLockMask uint32 = 0xffff
+
+	// Relative lock times are in units of 1 << sequenceLockGranularity
+	// seconds.
+	sequenceLockGranularity = 9
+)
+
+// The longest relative locks an input can have.
+const (
+	MaxRelativeLockBlocks = int(sequenceLockMask)
+	MaxRelativeLockTime   = time.Duration(sequenceLockMask<<sequenceLockGranularity) * time.Second
+)
+
+// RelativeLockBlocks returns the sequence of an input which can't be mined
+// until the output it spends is the given number of blocks deep, up to
+// MaxRelativeLockBlocks.
+func RelativeLockBlocks(blocks int) uint32 {
+	return uint32(blocks) & sequenceLockMask
+}
+
+// RelativeLockTime returns the sequence of an input which can't be mined until
+// the given time has passed since the output it spends was mined. The time is
+// rounded up to a multiple of 512 seconds, up to MaxRelativeLockTime.
+func RelativeLockTime(d time.Duration) uint32 {
+	units := (int64(d/time.Second) + 1<<sequenceLockGranularity - 1) >> sequenceLockGranularity
+
+	return sequenceLockIsTime | uint32(units)&sequenceLockMask
+}
+
+// IsFinal reports whether the transaction can be included in a block at the
+// given height, where medianTime is the median time past of the block before
+// it.
+func (tx *Transaction) IsFinal(height int, medianTime time.Time) bool {
+	if tx.LockTime == 0 {
+		return true
+	}
+
+	lockTime := int64(tx.LockTime)
+	if lockTime < LockTimeThreshold && lockTime < int64(height) {
+		return true
+	}
+	if lockTime >= LockTimeThreshold && lockTime < medianTime.Unix() {
+		return true
+	}
+
+	for _, vin := range tx.Vin {
+		if vin.Sequence != SequenceFinal {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sequenceLocked reports whether an input with the given sequence is still
+// locked in a block at the given height, where medianTime is the median time
+// past of the block before it. The output it spends was mined at prevHeight,
+// and prevMedianTime is the median time past of the block before that.
+func sequenceLocked(sequence uint32, prevHeight int, prevMedianTime time.Time, height int, medianTime time.Time) bool {
+	if sequence&sequenceLockDisabled != 0 {
+		return false
+	}
+
+	value := int64(sequence & sequenceLockMask)
+	if sequence&sequenceLockIsTime != 0 {
+		unlock := prevMedianTime.Unix() + value<<sequenceLockGranularity
+
+		return medianTime.Unix() < unlock
+	}
+
+	return int64(height) < int64(prevHeight)+value
+}
+
+// CheckLocks returns an error unless the lock time of a transaction and the
+// relative lock times of its inputs have passed, so it can be mined in the
+// next block. The outputs it spends must be in the UTXO set.
+func (bc *Blockchain) CheckLocks(tx *Transaction) error {
+	uTxOSet := UTxOSet{Blockchain: bc}
+	bestHeight := bc.GetBestHeight()
+	medianTime := bc.medianTimePastAt(bestHeight)
+
+	if !tx.IsFinal(bestHeight+1, medianTime) {
+		return ruleError(ErrUnfinalizedTx, "transaction %x is locked until %d", tx.ID, tx.LockTime)
+	}
+
+	var prevHeights []int
+	for _, vin := range tx.Vin {
+		outs, _ := uTxOSet.FindTxOutputs(vin.Txid)
+		prevHeights = append(prevHeights, outs.Height)
+	}
+
+	return bc.checkSequenceLocks(tx, prevHeights, bestHeight+1, medianTime)
+}
+
+// checkSequenceLocks returns an error unless the relative lock of every input
+// of a transaction has passed in a block at the given height, where medianTime
+// is the median time past of the block before it. prevHeights holds the height
+// of the block which created the output spent by each input.
+func (bc *Blockchain) checkSequenceLocks(tx *Transaction, prevHeights []int, height int, medianTime time.Time) error {
+	for i, vin := range tx.Vin {
+		prevMedianTime := bc.medianTimePastAt(prevHeights[i] - 1)
+
+		if sequenceLocked(vin.Sequence, prevHeights[i], prevMedianTime, height, medianTime) {
+			return ruleError(ErrSequenceLocked, "input %d of transaction %x is still locked", i, tx.ID)
+		}
+	}
+
+	return nil
+}
+
+// medianTimePastAt returns the median time past of the main chain block at
+// the given height, or of the genesis block for heights before it.
+func (bc *Blockchain) medianTimePastAt(height int) time.Time {
+	if height < 0 {
+		height = 0
+	}
+
+	block, err := bc.GetBlockByHeight(height)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return bc.medianTimePast(&block)
+}
@@ -0,0 +1,51 @@
+package crypto
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockTime(t *testing.T) {
+	a, b := NewWallet(), NewWallet()
+	bc := newTestChain(t, a)
+	uTxOSet := UTxOSet{Blockchain: bc}
+	pool := NewMempool(bc, DefaultMempoolSize, DefaultMempoolExpiry)
+
+	mine := func(txs ...*Transaction) error {
+		cbTx := NewCoinbaseTx(string(a.GetAddress()), "", bc.GetBestHeight()+1, 0)
+		_, err := bc.MineBlock(context.Background(), append([]*Transaction{cbTx}, txs...))
+
+		return err
+	}
+
+	// Locked until after height 2.
+	tx, err := NewLockedUTxOTransaction(a, string(b.GetAddress()), "", 4, 0, 2, SequenceFinal-1, &uTxOSet)
+	assert.NoError(t, err, "Transaction is created")
+	assert.Equal(t, ErrUnfinalizedTx, pool.Add(tx).(RuleError).Code, "Mempool rejects the locked transaction")
+	assert.Equal(t, ErrUnfinalizedTx, mine(tx).(RuleError).Code, "Block with the locked transaction is rejected")
+
+	assert.NoError(t, mine(), "Block is mined")
+	assert.NoError(t, mine(), "Block is mined")
+	assert.NoError(t, pool.Add(tx), "Mempool accepts the transaction once it can be mined")
+	assert.NoError(t, mine(tx), "Transaction is mined once its lock time passes")
+
+	// Spends the output mined at height 3, so is locked until height 5.
+	rel, err := NewLockedUTxOTransaction(b, string(a.GetAddress()), "", 3, 0, 0, RelativeLockBlocks(2), &uTxOSet)
+	assert.NoError(t, err, "Transaction is created")
+	assert.Equal(t, ErrSequenceLocked, pool.Add(rel).(RuleError).Code, "Mempool rejects the relatively locked transaction")
+	assert.Equal(t, ErrSequenceLocked, mine(rel).(RuleError).Code, "Block with the relatively locked transaction is rejected")
+
+	assert.NoError(t, mine(), "Block is mined")
+	assert.NoError(t, mine(rel), "Transaction is mined once its relative lock time passes")
+
+	// Lock times from the threshold up are Unix times.
+	now := time.Now()
+	tx = &Transaction{Vin: []TxInput{{Sequence: 0}}, LockTime: uint32(now.Unix())}
+	assert.False(t, tx.IsFinal(1, now), "Transaction is locked until its time")
+	assert.True(t, tx.IsFinal(1, now.Add(time.Second)), "Transaction is final after its time")
+
+	assert.Equal(t, sequenceLockIsTime|2, RelativeLockTime(1000*time.Second), "Relative times round up to 512 seconds")
+}
@@ -0,0 +1,356 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMempoolSize is the default limit on the total encoded size of
+	// the transactions in a mempool.
+	DefaultMempoolSize = 5 << 20
+
+	// DefaultMempoolExpiry is how long a transaction can wait in a mempool
+	// before it is dropped.
+	DefaultMempoolExpiry = 72 * time.Hour
+)
+
+var (
+	// ErrTxInPool is returned when adding a transaction already in the pool.
+	ErrTxInPool = errors.New("transaction is already in the mempool")
+
+	// ErrTxConflict is returned when a transaction spends an output which a
+	// transaction in the pool already spends.
+	ErrTxConflict = errors.New("transaction conflicts with one in the mempool")
+
+	// ErrMempoolFull is returned when the pool is full of transactions paying
+	// a higher fee rate.
+	ErrMempoolFull = errors.New("mempool is full")
+
+	// ErrTxTooLarge is returned when a transaction is larger than the pool
+	// can ever hold.
+	ErrTxTooLarge = errors.New("transaction is too large for the mempool")
+)
+
+// MempoolEntry is a transaction waiting in the mempool to be mined.
+type MempoolEntry struct {
+	Tx    *Transaction
+	Fee   int       // Value of the inputs beyond the outputs.
+	Size  int       // Size of the encoded transaction in bytes.
+	Added time.Time // When the transaction entered the pool.
+}
+
+// FeeRate returns the fee paid per byte of the transaction.
+func (e *MempoolEntry) FeeRate() float64 {
+	return float64(e.Fee) / float64(e.Size)
+}
+
+// lowerFeeRate reports whether e pays a lower fee rate than other.
+func (e *MempoolEntry) lowerFeeRate(other *MempoolEntry) bool {
+	return e.Fee*other.Size < other.Fee*e.Size
+}
+
+// Mempool holds valid transactions which have not been mined yet. It is safe
+// for concurrent use.
+//
+// Every transaction in the pool spends outputs in the UTXO set and no two
+// transactions spend the same output. The pool is limited in size, with the
+// transactions paying the lowest fee rate evicted first, and transactions
+// that wait too long are dropped.
+type Mempool struct {
+	uTxOSet UTxOSet
+	maxSize int
+	expiry  time.Duration
+
+	mu      sync.RWMutex
+	entries map[string]*MempoolEntry
+	spent   map[string]string // Outpoints spent by the pool and the txid spending them.
+	size    int
+}
+
+// NewMempool creates an empty mempool for the blockchain. The pool holds
+// transactions up to maxSize bytes in total, for at most expiry. An expiry of
+// zero keeps transactions until they are mined.
+func NewMempool(bc *Blockchain, maxSize int, expiry time.Duration) *Mempool {
+	return &Mempool{
+		uTxOSet: UTxOSet{Blockchain: bc},
+		maxSize: maxSize,
+		expiry:  expiry,
+		entries: make(map[string]*MempoolEntry),
+		spent:   make(map[string]string),
+	}
+}
+
+// Add checks a transaction and adds it to the pool. An error is returned if
+// the transaction is invalid, spends an output which is already spent in the
+// pool or the chain, is larger than the pool or pays too low a fee rate to fit
+// in a full pool. Transactions are only evicted to make room if this one is
+// added.
+func (m *Mempool) Add(tx *Transaction) error {
+	if tx.IsCoinbase() {
+		return ruleError(ErrBadCoinbase, "coinbase transaction %x can't be relayed", tx.ID)
+	}
+
+	if err := checkTransaction(tx); err != nil {
+		return err
+	}
+
+	size := len(tx.Serialize())
+	if size > m.maxSize {
+		return ErrTxTooLarge
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expire()
+
+	txID := hex.EncodeToString(tx.ID)
+	if _, ok := m.entries[txID]; ok {
+		return ErrTxInPool
+	}
+
+	inputs := make(map[string]bool)
+	for _, vin := range tx.Vin {
+		outpoint := fmt.Sprintf("%x:%d", vin.Txid, vin.Vout)
+		if inputs[outpoint] {
+			return ruleError(ErrDoubleSpend, "output %s is spent twice in transaction %x", outpoint, tx.ID)
+		}
+		inputs[outpoint] = true
+
+		if _, ok := m.spent[outpoint]; ok {
+			return ErrTxConflict
+		}
+	}
+
+	fee, err := m.uTxOSet.CalculateFee(tx)
+	if _, ok := err.(RuleError); ok {
+		return err
+	} else if err != nil {
+		return ruleError(ErrMissingInput, "transaction %x: %s", tx.ID, err)
+	}
+
+	spendHeight := m.uTxOSet.Blockchain.GetBestHeight() + 1
+	for _, vin := range tx.Vin {
+		if outs, _ := m.uTxOSet.FindTxOutputs(vin.Txid); !outs.IsMature(spendHeight) {
+			return ruleError(ErrImmatureSpend, "transaction %x spends an immature coinbase", tx.ID)
+		}
+	}
+
+	if err := m.uTxOSet.Blockchain.CheckLocks(tx); err != nil {
+		return err
+	}
+
+	if !m.uTxOSet.Blockchain.VerifyTransaction(tx) {
+		return ruleError(ErrBadSignature, "transaction %x has an invalid signature", tx.ID)
+	}
+
+	entry := &MempoolEntry{
+		Tx:    tx,
+		Fee:   fee,
+		Size:  size,
+		Added: time.Now(),
+	}
+
+	// Make room by evicting transactions which pay less than this one. Nothing
+	// is evicted unless the transaction then fits.
+	evicted, err := m.evictions(entry)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range evicted {
+		m.remove(id)
+	}
+
+	m.entries[txID] = entry
+	m.size += entry.Size
+	for outpoint := range inputs {
+		m.spent[outpoint] = txID
+	}
+
+	return nil
+}
+
+// Remove removes a transaction from the pool, if it is there.
+func (m *Mempool) Remove(txID []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.remove(hex.EncodeToString(txID))
+}
+
+// Get returns the transaction in the pool with the given ID.
+func (m *Mempool) Get(txID []byte) (*Transaction, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, ok := m.entries[hex.EncodeToString(txID)]
+	if !ok {
+		return nil, false
+	}
+
+	return entry.Tx, true
+}
+
+// Has reports whether the transaction with the given ID is in the pool.
+func (m *Mempool) Has(txID []byte) bool {
+	_, ok := m.Get(txID)
+
+	return ok
+}
+
+// Count returns the number of transactions in the pool.
+func (m *Mempool) Count() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return len(m.entries)
+}
+
+// Size returns the total encoded size of the transactions in the pool.
+func (m *Mempool) Size() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.size
+}
+
+// Entries returns the entries in the pool, highest fee rate first.
+func (m *Mempool) Entries() []*MempoolEntry {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entries := make([]*MempoolEntry, 0, len(m.entries))
+	for _, entry := range m.entries {
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[j].lowerFeeRate(entries[i])
+	})
+
+	return entries
+}
+
+// Expire drops transactions which have been in the pool for too long and
+// returns how many were dropped.
+func (m *Mempool) Expire() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.expire()
+}
+
+// ApplyChange updates the pool after the main chain has changed. Transactions
+// which were confirmed, or which conflict with confirmed transactions, are
+// removed, and transactions from disconnected blocks are added back.
+func (m *Mempool) ApplyChange(change *ChainChange) {
+	m.mu.Lock()
+
+	// Whether confirmed or in conflict, a transaction spending outputs which
+	// are no longer unspent can't be mined. Nor can one spending a coinbase
+	// which a reorganization has made immature again, or one which it has
+	// locked again.
+	spendHeight := m.uTxOSet.Blockchain.GetBestHeight() + 1
+	for txID, entry := range m.entries {
+		if m.uTxOSet.Blockchain.CheckLocks(entry.Tx) != nil {
+			m.remove(txID)
+			continue
+		}
+
+		for _, vin := range entry.Tx.Vin {
+			outs, _ := m.uTxOSet.FindTxOutputs(vin.Txid)
+			if _, ok := outs.Outputs[vin.Vout]; !ok || !outs.IsMature(spendHeight) {
+				m.remove(txID)
+				break
+			}
+		}
+	}
+
+	m.mu.Unlock()
+
+	for _, tx := range change.Displaced() {
+		m.Add(tx)
+	}
+}
+
+// expire drops transactions which have been in the pool for too long. The
+// caller must hold the lock.
+func (m *Mempool) expire() int {
+	dropped := 0
+	if m.expiry <= 0 {
+		return dropped
+	}
+
+	cutoff := time.Now().Add(-m.expiry)
+
+	for txID, entry := range m.entries {
+		if entry.Added.Before(cutoff) {
+			m.remove(txID)
+			dropped++
+		}
+	}
+
+	return dropped
+}
+
+// evictions returns the IDs of the transactions to evict to make room for
+// entry, lowest fee rate first, without changing the pool. ErrMempoolFull is
+// returned if room can only be made by evicting a transaction paying a fee
+// rate no lower than entry. The caller must hold the lock.
+func (m *Mempool) evictions(entry *MempoolEntry) ([]string, error) {
+	if m.size+entry.Size <= m.maxSize {
+		return nil, nil
+	}
+
+	var txIDs []string
+	for txID := range m.entries {
+		txIDs = append(txIDs, txID)
+	}
+
+	sort.Slice(txIDs, func(i, j int) bool {
+		return m.entries[txIDs[i]].lowerFeeRate(m.entries[txIDs[j]])
+	})
+
+	var evicted []string
+	size := m.size
+
+	for _, txID := range txIDs {
+		if size+entry.Size <= m.maxSize {
+			break
+		}
+
+		if !m.entries[txID].lowerFeeRate(entry) {
+			return nil, ErrMempoolFull
+		}
+
+		evicted = append(evicted, txID)
+		size -= m.entries[txID].Size
+	}
+
+	if size+entry.Size > m.maxSize {
+		return nil, ErrMempoolFull
+	}
+
+	return evicted, nil
+}
+
+// remove removes a transaction from the pool. The caller must hold the lock.
+func (m *Mempool) remove(txID string) {
+	entry, ok := m.entries[txID]
+	if !ok {
+		return
+	}
+
+	for _, vin := range entry.Tx.Vin {
+		delete(m.spent, fmt.Sprintf("%x:%d", vin.Txid, vin.Vout))
+	}
+
+	m.size -= entry.Size
+	delete(m.entries, txID)
+}
@@ -0,0 +1,133 @@
+package crypto
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestChain creates a blockchain in a temporary directory with the genesis
+// reward paid to the given wallet. Coinbase outputs mature straight away,
+// unless the test sets Net.CoinbaseMaturity itself.
+func newTestChain(t *testing.T, w *Wallet) *Blockchain {
+	net := Net
+	params := *Net
+	params.CoinbaseMaturity = 0
+	SetNetwork(&params)
+
+	dir, err := ioutil.TempDir("", "yagocoin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Chdir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bc := CreateBlockchain(string(w.GetAddress()), "test")
+	UTxOSet{Blockchain: bc}.Reindex()
+
+	t.Cleanup(func() {
+		bc.Close()
+		os.Chdir(wd)
+		os.RemoveAll(dir)
+		SetNetwork(net)
+	})
+
+	return bc
+}
+
+func TestMempoolAdd(t *testing.T) {
+	a, b := NewWallet(), NewWallet()
+	bc := newTestChain(t, a)
+	uTxOSet := UTxOSet{Blockchain: bc}
+	pool := NewMempool(bc, DefaultMempoolSize, DefaultMempoolExpiry)
+
+	tx := NewUTxOTransaction(a, string(b.GetAddress()), 4, 1, &uTxOSet)
+	assert.NoError(t, pool.Add(tx), "Transaction is added")
+	assert.True(t, pool.Has(tx.ID), "Transaction is in the pool")
+	assert.Equal(t, 1, pool.Entries()[0].Fee, "Fee is recorded")
+
+	assert.Equal(t, ErrTxInPool, pool.Add(tx), "Transaction can't be added twice")
+
+	conflict := NewUTxOTransaction(a, string(b.GetAddress()), 5, 1, &uTxOSet)
+	assert.Equal(t, ErrTxConflict, pool.Add(conflict), "Conflicting transaction is rejected")
+
+	_, err := bc.MineBlock(context.Background(), []*Transaction{NewCoinbaseTx(string(b.GetAddress()), "", 1, 1), tx})
+	assert.NoError(t, err, "Block is mined")
+
+	pool.ApplyChange(&ChainChange{})
+	assert.Equal(t, 0, pool.Count(), "Confirmed transaction is removed")
+
+	err = pool.Add(conflict)
+	assert.IsType(t, RuleError{}, err, "Spent output is rejected")
+	assert.Equal(t, ErrMissingInput, err.(RuleError).Code, "Spent output is rejected")
+}
+
+func TestMempoolEviction(t *testing.T) {
+	a, b := NewWallet(), NewWallet()
+	bc := newTestChain(t, a)
+	uTxOSet := UTxOSet{Blockchain: bc}
+
+	_, err := bc.MineBlock(context.Background(), []*Transaction{NewCoinbaseTx(string(b.GetAddress()), "", 1, 0)})
+	assert.NoError(t, err, "Block is mined")
+
+	cheap := NewUTxOTransaction(a, string(b.GetAddress()), 5, 1, &uTxOSet)
+	dear := NewUTxOTransaction(b, string(a.GetAddress()), 5, 4, &uTxOSet)
+
+	// Only one of the transactions fits.
+	size := len(cheap.Serialize()) + len(dear.Serialize()) - 1
+	pool := NewMempool(bc, size, DefaultMempoolExpiry)
+
+	assert.NoError(t, pool.Add(dear), "Transaction is added")
+	assert.Equal(t, ErrMempoolFull, pool.Add(cheap), "Lower fee rate is rejected when full")
+
+	pool = NewMempool(bc, size, DefaultMempoolExpiry)
+
+	assert.NoError(t, pool.Add(cheap), "Transaction is added")
+	assert.NoError(t, pool.Add(dear), "Higher fee rate is added when full")
+	assert.False(t, pool.Has(cheap.ID), "Lower fee rate is evicted")
+	assert.Equal(t, len(dear.Serialize()), pool.Size(), "Size is tracked")
+}
+
+func TestMempoolEvictionRejected(t *testing.T) {
+	a, b, c := NewWallet(), NewWallet(), NewWallet()
+	bc := newTestChain(t, a)
+	uTxOSet := UTxOSet{Blockchain: bc}
+
+	_, err := bc.Generate(context.Background(), string(b.GetAddress()), 1)
+	assert.NoError(t, err, "Block is mined")
+	_, err = bc.Generate(context.Background(), string(c.GetAddress()), 1)
+	assert.NoError(t, err, "Block is mined")
+
+	cheap := NewUTxOTransaction(a, string(c.GetAddress()), 5, 1, &uTxOSet)
+	dear := NewUTxOTransaction(b, string(c.GetAddress()), 1, 8, &uTxOSet)
+	large, err := NewDataTransaction(c, make([]byte, 80), 4, &uTxOSet)
+	assert.NoError(t, err, "Data transaction is created")
+
+	// The large transaction only fits if both others are evicted, but it pays
+	// a lower fee rate than one of them.
+	size := len(cheap.Serialize()) + len(dear.Serialize())
+	pool := NewMempool(bc, size, DefaultMempoolExpiry)
+
+	assert.NoError(t, pool.Add(cheap), "Transaction is added")
+	assert.NoError(t, pool.Add(dear), "Transaction is added")
+	assert.Equal(t, ErrMempoolFull, pool.Add(large), "Transaction which doesn't fit is rejected")
+	assert.Equal(t, 2, pool.Count(), "Nothing is evicted for a rejected transaction")
+	assert.Equal(t, size, pool.Size(), "Size is unchanged")
+
+	pool = NewMempool(bc, len(large.Serialize())-1, DefaultMempoolExpiry)
+
+	assert.NoError(t, pool.Add(cheap), "Transaction is added")
+	assert.Equal(t, ErrTxTooLarge, pool.Add(large), "Transaction larger than the pool is rejected")
+	assert.True(t, pool.Has(cheap.ID), "Nothing is evicted for a transaction larger than the pool")
+}
@@ -0,0 +1,280 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/danmrichards/yagocoin/chaincfg"
+)
+
+const (
+	metaBucket     = "meta"
+	versionKey     = "version"
+	networkKey     = "network"
+	migratedTipKey = "migrated"
+
+	// The version of the database layout. Databases without a version were
+	// written with encoding/gob. Version 1 databases don't record which
+	// unspent outputs came from a coinbase.
+	dbVersion = 2
+
+	// Blocks written before targets could change all had a hash below
+	// 1 << (256 - legacyTargetBits), and no Bits field.
+	legacyTargetBits = 16
+)
+
+// The gob encoded types of databases written before the canonical encoding.
+type (
+	legacyBlock struct {
+		Timestamp     time.Time
+		Transactions  []*legacyTransaction
+		PrevBlockHash []byte
+		Hash          []byte
+		Nonce         int
+		Height        int
+		Bits          uint32
+		MerkleRoot    []byte
+	}
+
+	legacyTransaction struct {
+		ID   []byte
+		Vin  []legacyTxInput
+		Vout []legacyTxOutput
+	}
+
+	legacyTxInput struct {
+		Txid      []byte
+		Vout      int
+		Signature []byte
+		PubKey    []byte
+	}
+
+	legacyTxOutput struct {
+		Value      int
+		PubKeyHash []byte
+	}
+)
+
+// toBlock converts a legacy block to a Block, keeping its hash and the IDs of
+// its transactions.
+func (lb *legacyBlock) toBlock() *Block {
+	block := &Block{
+		BlockHeader: BlockHeader{
+			Version:       1,
+			PrevBlockHash: lb.PrevBlockHash,
+			MerkleRoot:    lb.MerkleRoot,
+			Timestamp:     time.Unix(lb.Timestamp.Unix(), 0),
+			Bits:          lb.bits(),
+			Nonce:         uint32(lb.Nonce),
+		},
+		Hash:   lb.Hash,
+		Height: lb.Height,
+	}
+
+	for _, ltx := range lb.Transactions {
+		tx := &Transaction{ID: ltx.ID}
+
+		for _, vin := range ltx.Vin {
+			in := TxInput{Txid: vin.Txid, Vout: vin.Vout, Sequence: SequenceFinal}
+			in.ScriptSig = legacyScriptSig(in, vin.Signature, vin.PubKey)
+			tx.Vin = append(tx.Vin, in)
+		}
+
+		for _, vout := range ltx.Vout {
+			tx.Vout = append(tx.Vout, TxOutput{vout.Value, legacyScriptPubKey(vout.PubKeyHash)})
+		}
+
+		block.Transactions = append(block.Transactions, tx)
+	}
+
+	return block
+}
+
+// bits returns the compact target of a legacy block, filling in the fixed
+// target of blocks written before targets could change. That target is just
+// above the proof-of-work limit of the main network, so it is capped there.
+func (lb *legacyBlock) bits() uint32 {
+	if lb.Bits != 0 {
+		return lb.Bits
+	}
+
+	target := new(big.Int).Lsh(big.NewInt(1), 256-legacyTargetBits)
+	if limit := powLimit(); target.Cmp(limit) > 0 {
+		target = limit
+	}
+
+	return BigToCompact(target)
+}
+
+// isLegacyDB reports whether the database was written before the canonical
+// encoding.
+func isLegacyDB(tx *bolt.Tx) bool {
+	return tx.Bucket([]byte(metaBucket)) == nil
+}
+
+// putDBVersion records the version of the database layout and the network the
+// blockchain belongs to.
+func putDBVersion(tx *bolt.Tx) error {
+	m, err := tx.CreateBucketIfNotExists([]byte(metaBucket))
+	if err != nil {
+		return err
+	}
+
+	err = m.Put([]byte(versionKey), []byte{dbVersion})
+	if err != nil {
+		return err
+	}
+
+	return m.Put([]byte(networkKey), []byte(Net.Name))
+}
+
+// readDBVersion returns the version of the database layout.
+func readDBVersion(tx *bolt.Tx) byte {
+	version := tx.Bucket([]byte(metaBucket)).Get([]byte(versionKey))
+	if len(version) == 0 {
+		return 0
+	}
+
+	return version[0]
+}
+
+// upgradeChainState rebuilds the UTXO set of a version 1 database, so that it
+// records the height and coinbase flag of every output. The undo data lacks
+// them too and is dropped, so reorganizations past the upgrade rebuild the
+// UTXO set instead of disconnecting blocks one at a time.
+func (bc *Blockchain) upgradeChainState() {
+	UTxOSet{Blockchain: bc}.Reindex()
+
+	err := bc.db.Update(func(tx *bolt.Tx) error {
+		err := tx.DeleteBucket([]byte(undoBucket))
+		if err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+
+		return putDBVersion(tx)
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// migratedTip returns the hash of the last block converted by MigrateDB, or
+// nil if the database was never migrated.
+func migratedTip(tx *bolt.Tx) []byte {
+	m := tx.Bucket([]byte(metaBucket))
+	if m == nil {
+		return nil
+	}
+
+	return m.Get([]byte(migratedTipKey))
+}
+
+// dbNetwork returns the name of the network the blockchain belongs to.
+// Databases created before there were several networks are on the main
+// network.
+func dbNetwork(tx *bolt.Tx) string {
+	name := tx.Bucket([]byte(metaBucket)).Get([]byte(networkKey))
+	if name == nil {
+		return chaincfg.MainNetParams.Name
+	}
+
+	return string(name)
+}
+
+// MigrateDB converts a blockchain database written with encoding/gob to the
+// canonical encoding. Every block is re-encoded and the UTXO set is rebuilt.
+//
+// Migrated blocks keep their original hashes and transaction IDs, which were
+// computed from the gob encoding, so the chain remains usable locally. Their
+// headers don't hash to those hashes, so their proof-of-work can't be checked
+// and they are not offered to peers, see GetHeaders. Nodes that sync those
+// blocks from scratch will not be able to validate them, so every node should
+// migrate its own database.
+func MigrateDB(nodeID string) error {
+	dbFile := fmt.Sprintf(Net.DBFile, nodeID)
+	if dbExists(dbFile) == false {
+		return errors.New("no existing blockchain found")
+	}
+
+	db, err := bolt.Open(dbFile, fileMode, nil)
+	if err != nil {
+		return err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if !isLegacyDB(tx) {
+			return errors.New("blockchain is already up to date")
+		}
+
+		b := tx.Bucket([]byte(blocksBucket))
+		blocks := make(map[string][]byte)
+
+		// Buckets can't be changed while we iterate over them, so collect
+		// the re-encoded blocks first.
+		err := b.ForEach(func(k, v []byte) error {
+			if bytes.Equal(k, []byte(hashKey)) {
+				return nil
+			}
+
+			var lb legacyBlock
+			err := gob.NewDecoder(bytes.NewReader(v)).Decode(&lb)
+			if err != nil {
+				return fmt.Errorf("could not decode block %x: %s", k, err)
+			}
+
+			blocks[string(k)] = lb.toBlock().Serialize()
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for k, v := range blocks {
+			if err := b.Put([]byte(k), v); err != nil {
+				return err
+			}
+		}
+
+		// Undo data can't be converted without the UTXO set it was made
+		// against, and the UTXO set is rebuilt below anyway.
+		for _, bucket := range []string{utxoBucket, undoBucket} {
+			err := tx.DeleteBucket([]byte(bucket))
+			if err != nil && err != bolt.ErrBucketNotFound {
+				return err
+			}
+		}
+
+		err = putDBVersion(tx)
+		if err != nil {
+			return err
+		}
+
+		m := tx.Bucket([]byte(metaBucket))
+
+		return m.Put([]byte(migratedTipKey), b.Get([]byte(hashKey)))
+	})
+	if err != nil {
+		db.Close()
+		return err
+	}
+
+	tip, err := readTip(db)
+	if err != nil {
+		db.Close()
+		return err
+	}
+
+	bc := &Blockchain{tip: tip, db: db}
+	defer bc.Close()
+
+	UTxOSet{Blockchain: bc}.Reindex()
+
+	return nil
+}
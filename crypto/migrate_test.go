@@ -0,0 +1,62 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/danmrichards/yagocoin/chaincfg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLegacyBlockBits(t *testing.T) {
+	block := (&legacyBlock{Height: 1}).toBlock()
+	assert.Equal(t, chaincfg.MainNetParams.PowLimitBits, block.Bits, "Block without bits gets the legacy target capped at the limit")
+	assert.True(t, validTarget(CompactToBig(block.Bits)), "Target of the block is valid")
+
+	block = (&legacyBlock{Height: 1, Bits: 0x1f00ffff}).toBlock()
+	assert.Equal(t, uint32(0x1f00ffff), block.Bits, "Bits of the block are kept")
+}
+
+func TestMigratedBlockProof(t *testing.T) {
+	// The hash was computed from the gob encoding of the block, which the
+	// header doesn't hash to.
+	lb := &legacyBlock{Height: 1, Hash: make([]byte, 32), MerkleRoot: make([]byte, 32)}
+	lb.Hash[31] = 1
+
+	block := lb.toBlock()
+	assert.Equal(t, lb.Hash, block.Hash, "Hash of the block is kept")
+	assert.False(t, block.CheckProof(), "Proof of a migrated header can't be checked")
+}
+
+func TestGetHeadersSkipsMigratedBlocks(t *testing.T) {
+	SetNetwork(&chaincfg.RegressionNetParams)
+	t.Cleanup(func() { SetNetwork(&chaincfg.MainNetParams) })
+
+	w := NewWallet()
+	bc := newTestChain(t, w)
+
+	genesis, err := bc.GetBlockByHeight(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrated := mineOn(bc, &genesis, NewCoinbaseTx(string(w.GetAddress()), "", 1, 0))
+	next := mineOn(bc, migrated, NewCoinbaseTx(string(w.GetAddress()), "", 2, 0))
+	for _, block := range []*Block{migrated, next} {
+		if _, err := bc.AddBlock(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = bc.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(metaBucket)).Put([]byte(migratedTipKey), migrated.Hash)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	headers := bc.GetHeaders(nil, MaxHeaders)
+	if assert.Len(t, headers, 1, "Only headers after the migrated blocks are returned") {
+		assert.Equal(t, next.Hash, headers[0].Hash(), "Header of the next block is returned")
+	}
+}
@@ -0,0 +1,128 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/danmrichards/yagocoin/script"
+)
+
+// NewMultiSigAddress returns the address of a pay to script hash output which
+// needs m signatures from the given public keys, along with the redeem script
+// needed to spend from it.
+func NewMultiSigAddress(m int, pubKeys [][]byte) ([]byte, []byte, error) {
+	redeemScript, err := script.MultiSigScript(m, pubKeys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The redeem script is pushed when spending, so must fit in a push.
+	if len(redeemScript) > script.MaxPushSize {
+		return nil, nil, fmt.Errorf("redeem script of %d bytes is larger than %d, use fewer keys", len(redeemScript), script.MaxPushSize)
+	}
+
+	return AddressFromScriptHash(script.Hash160(redeemScript)), redeemScript, nil
+}
+
+// NewMultiSigTransaction creates an unsigned transaction spending from the
+// multisig address of a redeem script, with any change going back to it.
+// Signatures are added with SignMultiSig.
+func NewMultiSigTransaction(redeemScript []byte, to string, amount, fee int, uTxOSet *UTxOSet) (*Transaction, error) {
+	var inputs []TxInput
+	var outputs []TxOutput
+
+	if _, _, ok := script.ExtractMultiSig(redeemScript); !ok {
+		return nil, errors.New("redeem script is not a multisig script")
+	}
+
+	scriptHash := script.Hash160(redeemScript)
+	acc, validOutputs := uTxOSet.FindSpendableOutputs(scriptHash, amount+fee)
+
+	if acc < amount+fee {
+		return nil, errors.New("not enough funds")
+	}
+
+	// Each input starts off with just the redeem script.
+	sigScript, err := script.MultiSigSigScript(nil, redeemScript)
+	if err != nil {
+		return nil, err
+	}
+
+	inputs = newInputs(validOutputs, sigScript, SequenceFinal)
+
+	outputs = append(outputs, *NewTxOutput(amount, to))
+
+	// Change.
+	if acc > amount+fee {
+		outputs = append(outputs, *NewTxOutput(acc-amount-fee, string(AddressFromScriptHash(scriptHash))))
+	}
+
+	tx := Transaction{nil, inputs, outputs, 0}
+	tx.ID = tx.Hash()
+
+	return &tx, nil
+}
+
+// SignMultiSig adds a signature by privKey to each input spending a multisig
+// output which the key is one of. Signatures are kept in the order of their
+// keys, invalid ones are dropped and none are added to an input which already
+// has enough. The number of signatures added is returned.
+func (tx *Transaction) SignMultiSig(privKey ecdsa.PrivateKey, prevTxs map[string]Transaction) int {
+	pubKey := pubKeyBytes(&privKey.PublicKey)
+	added := 0
+
+	for inID, vin := range tx.Vin {
+		sigs, redeemScript, ok := script.ExtractMultiSigSigs(vin.ScriptSig)
+		if !ok {
+			continue
+		}
+		m, pubKeys, _ := script.ExtractMultiSig(redeemScript)
+
+		prevTx, ok := prevTxs[hex.EncodeToString(vin.Txid)]
+		if !ok || vin.Vout < 0 || vin.Vout >= len(prevTx.Vout) {
+			continue
+		}
+		prevScript := prevTx.Vout[vin.Vout].ScriptPubKey
+		checker := sigChecker{tx, inID, prevScript}
+
+		// Match the signatures so far to the keys which made them.
+		keySigs := make([][]byte, len(pubKeys))
+		count := 0
+		for _, sig := range sigs {
+			for i, key := range pubKeys {
+				if keySigs[i] == nil && checker.CheckSig(sig, key) {
+					keySigs[i] = sig
+					count++
+					break
+				}
+			}
+		}
+
+		for i, key := range pubKeys {
+			if count < m && keySigs[i] == nil && bytes.Equal(key, pubKey) {
+				keySigs[i] = signHash(privKey, tx.SignatureHash(inID, prevScript))
+				count++
+				added++
+			}
+		}
+
+		var ordered [][]byte
+		for _, sig := range keySigs {
+			if sig != nil && len(ordered) < m {
+				ordered = append(ordered, sig)
+			}
+		}
+
+		sigScript, err := script.MultiSigSigScript(ordered, redeemScript)
+		if err != nil {
+			log.Panic(err)
+		}
+		tx.Vin[inID].ScriptSig = sigScript
+	}
+
+	return added
+}
@@ -0,0 +1,47 @@
+package crypto
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultiSig(t *testing.T) {
+	a, b, c, d := NewWallet(), NewWallet(), NewWallet(), NewWallet()
+	bc := newTestChain(t, a)
+	uTxOSet := UTxOSet{Blockchain: bc}
+
+	address, redeemScript, err := NewMultiSigAddress(2, [][]byte{a.PublicKey, b.PublicKey, c.PublicKey})
+	assert.NoError(t, err, "Multisig address is created")
+	assert.True(t, ValidateAddress(string(address)), "Multisig address is valid")
+
+	fund := NewUTxOTransaction(a, string(address), 6, 0, &uTxOSet)
+	_, err = bc.MineBlock(context.Background(), []*Transaction{NewCoinbaseTx(string(a.GetAddress()), "", 1, 0), fund})
+	assert.NoError(t, err, "Multisig address is funded")
+
+	spendable, _ := uTxOSet.GetBalance(GetPublicKeyHash(address))
+	assert.Equal(t, 6, spendable, "Multisig address has a balance")
+
+	tx, err := NewMultiSigTransaction(redeemScript, string(d.GetAddress()), 4, 1, &uTxOSet)
+	assert.NoError(t, err, "Spend is created")
+	assert.False(t, bc.VerifyTransaction(tx), "Unsigned spend is not valid")
+
+	assert.Equal(t, 0, bc.SignMultiSigTransaction(tx, d.PrivateKey), "Other keys can't sign")
+	assert.Equal(t, 1, bc.SignMultiSigTransaction(tx, c.PrivateKey), "First signature is added")
+	assert.Equal(t, 0, bc.SignMultiSigTransaction(tx, c.PrivateKey), "Keys can't sign twice")
+	assert.False(t, bc.VerifyTransaction(tx), "One signature is not enough")
+
+	// Signatures are put in key order, whichever order they are added in.
+	assert.Equal(t, 1, bc.SignMultiSigTransaction(tx, a.PrivateKey), "Second signature is added")
+	assert.True(t, bc.VerifyTransaction(tx), "Two signatures are enough")
+
+	_, err = bc.MineBlock(context.Background(), []*Transaction{NewCoinbaseTx(string(a.GetAddress()), "", 2, 1), tx})
+	assert.NoError(t, err, "Spend is mined")
+
+	spendable, _ = uTxOSet.GetBalance(GetPublicKeyHash(address))
+	assert.Equal(t, 1, spendable, "Change goes back to the multisig address")
+
+	spendable, _ = uTxOSet.GetBalance(HashPubKey(d.PublicKey))
+	assert.Equal(t, 4, spendable, "Coins are received")
+}
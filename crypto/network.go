@@ -0,0 +1,22 @@
+package crypto
+
+import (
+	"math/big"
+
+	"github.com/danmrichards/yagocoin/chaincfg"
+)
+
+// Net is the network the blockchain belongs to. Change it with SetNetwork
+// before a blockchain or wallet is created or opened.
+var Net = &chaincfg.MainNetParams
+
+// SetNetwork switches to the given network, along with its emission schedule.
+func SetNetwork(params *chaincfg.Params) {
+	Net = params
+	Emission = newEmissionSchedule(params)
+}
+
+// powLimit returns the easiest target a block may have on the network.
+func powLimit() *big.Int {
+	return CompactToBig(Net.PowLimitBits)
+}
@@ -0,0 +1,23 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/danmrichards/yagocoin/chaincfg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetNetwork(t *testing.T) {
+	t.Cleanup(func() { SetNetwork(&chaincfg.MainNetParams) })
+
+	w := NewWallet()
+	mainAddress := string(w.GetAddress())
+
+	SetNetwork(&chaincfg.RegressionNetParams)
+	regAddress := string(w.GetAddress())
+
+	assert.NotEqual(t, mainAddress, regAddress, "Networks have different addresses")
+	assert.True(t, ValidateAddress(regAddress), "Address of the network is valid")
+	assert.False(t, ValidateAddress(mainAddress), "Address of another network is invalid")
+	assert.Equal(t, chaincfg.RegressionNetParams.HalvingInterval, Emission.HalvingInterval, "Emission schedule follows the network")
+}
@@ -0,0 +1,67 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/danmrichards/yagocoin/base58"
+)
+
+// Private keys are exported as text the same way addresses are: Base58Check
+// encoded, starting with the PrivateKeyVersion of the network and followed by
+// the 32 byte big endian private scalar.
+
+// ErrKeyExists is returned when importing a key already in the wallet file.
+var ErrKeyExists = errors.New("key is already in the wallet file")
+
+// ExportPrivateKey returns the Base58Check encoded private key of the wallet.
+func (w Wallet) ExportPrivateKey() string {
+	return string(encodeAddress(Net.PrivateKeyVersion, privateKeyBytes(&w.PrivateKey)))
+}
+
+// DecodePrivateKey returns the wallet of a private key exported with
+// ExportPrivateKey. Keys of other networks are not valid on this one.
+func DecodePrivateKey(encoded string) (*Wallet, error) {
+	payload := base58.Base58Decode([]byte(encoded))
+	if len(payload) != 1+32+addressChecksumLen {
+		return nil, errors.New("invalid private key")
+	}
+
+	body := payload[:len(payload)-addressChecksumLen]
+	if !bytes.Equal(checksum(body), payload[len(body):]) {
+		return nil, errors.New("invalid private key checksum")
+	}
+
+	if body[0] != Net.PrivateKeyVersion {
+		return nil, errors.New("private key is for another network")
+	}
+
+	if !validScalar(body[1:]) {
+		return nil, errors.New("invalid private key")
+	}
+
+	key := privateKeyFromScalar(body[1:])
+
+	return &Wallet{key, pubKeyBytes(&key.PublicKey)}, nil
+}
+
+// ImportWallet adds a wallet with a key from elsewhere to Wallets, returning
+// its address. An encrypted wallet file must be unlocked first.
+func (ws *Wallets) ImportWallet(wallet *Wallet) (string, error) {
+	if ws.IsLocked() {
+		return "", ErrWalletLocked
+	}
+
+	address := string(wallet.GetAddress())
+	if _, ok := ws.Wallets[address]; ok {
+		return address, ErrKeyExists
+	}
+
+	ws.Wallets[address] = wallet
+
+	if ws.encryption != nil {
+		ws.sealKeys()
+	}
+
+	return address, nil
+}
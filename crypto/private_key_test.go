@@ -0,0 +1,57 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/danmrichards/yagocoin/base58"
+	"github.com/danmrichards/yagocoin/chaincfg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrivateKeyEncoding(t *testing.T) {
+	t.Cleanup(func() { SetNetwork(&chaincfg.MainNetParams) })
+
+	w := NewWallet()
+	encoded := w.ExportPrivateKey()
+
+	decoded, err := DecodePrivateKey(encoded)
+	assert.NoError(t, err, "Private key is decoded")
+	assert.Equal(t, *w, *decoded, "Keys survive the round trip")
+
+	payload := base58.Base58Decode([]byte(encoded))
+	payload[len(payload)-1] ^= 1
+	_, err = DecodePrivateKey(string(base58.Base58Encode(payload)))
+	assert.Error(t, err, "Private key with a bad checksum is invalid")
+
+	_, err = DecodePrivateKey(string(w.GetAddress()))
+	assert.Error(t, err, "Address isn't a private key")
+
+	SetNetwork(&chaincfg.RegressionNetParams)
+	_, err = DecodePrivateKey(encoded)
+	assert.Error(t, err, "Private key of another network is invalid")
+}
+
+func TestImportWallet(t *testing.T) {
+	w := NewWallet()
+
+	ws := &Wallets{Wallets: make(map[string]*Wallet)}
+	ws.Encrypt([]byte("secret"))
+	ws.Lock()
+
+	_, err := ws.ImportWallet(w)
+	assert.Equal(t, ErrWalletLocked, err, "Locked wallet file can't import keys")
+
+	ws.Unlock([]byte("secret"))
+	address, err := ws.ImportWallet(w)
+	assert.NoError(t, err, "Key is imported")
+	assert.Equal(t, string(w.GetAddress()), address, "Address of the key is returned")
+
+	_, err = ws.ImportWallet(w)
+	assert.Equal(t, ErrKeyExists, err, "Key can't be imported twice")
+
+	ws.Lock()
+	ws.Unlock([]byte("secret"))
+	imported, err := ws.GetWallet(address)
+	assert.NoError(t, err, "Imported key is sealed with the others")
+	assert.Equal(t, *w, imported, "Imported key is kept")
+}
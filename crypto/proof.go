@@ -1,20 +1,28 @@
 package crypto
 
 import (
-	"bytes"
+	"context"
 	"crypto/sha256"
+	"encoding/binary"
 	"fmt"
 	"math"
 	"math/big"
-
-	"github.com/danmrichards/yagocoin/domain"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
-// Maximum counter for proof-of-work algorithm.
-var maxNonce = math.MaxInt64
+const (
+	// How often the hash rate is reported while mining.
+	hashRateInterval = 10 * time.Second
+
+	// How many nonces a worker tries between checks for cancellation.
+	nonceBatch = 1 << 14
+)
 
-// Sets the upper boundary of the hash target.
-const targetBits = 16
+// MinerThreads is the number of goroutines that search for a proof-of-work.
+var MinerThreads = runtime.NumCPU()
 
 // Proof represents a proof-of-work.
 type Proof struct {
@@ -22,68 +30,197 @@ type Proof struct {
 	target *big.Int
 }
 
-// Prepare the data needed to hash a block.
-func (p *Proof) prepareData(nonce int) []byte {
-	data := bytes.Join(
-		[][]byte{
-			p.block.PrevBlockHash,
-			p.block.HashTransactions(),
-			domain.IntToHex(p.block.Timestamp.Unix()),
-			domain.IntToHex(int64(targetBits)),
-			domain.IntToHex(int64(nonce)),
-		},
-		[]byte{},
-	)
-
-	return data
+// Run performs a proof-of-work run for a block. The nonce space is split
+// between the given number of workers, each of which hashes the block header
+// with the nonces in its range and compares the hash to the proof target. If
+// every nonce is tried without success the timestamp is moved on and the
+// search starts again. The header that meets the target is returned along with
+// its hash, or an error if the context is cancelled first.
+func (p *Proof) Run(ctx context.Context, workers int) (BlockHeader, []byte, error) {
+	var hashes uint64
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	started := time.Now()
+	done := make(chan struct{})
+	defer close(done)
+	go reportHashRate(&hashes, started, done)
+
+	fmt.Printf("Mining a new block with %d workers\n", workers)
+
+	header := p.block.BlockHeader
+	for {
+		found, err := p.search(ctx, header, workers, &hashes)
+		if err != nil {
+			fmt.Println("Mining cancelled")
+			return BlockHeader{}, nil, err
+		}
+
+		if found != nil {
+			hash := found.Hash()
+			fmt.Printf(
+				"Found %x after %d hashes (%s)\n",
+				hash, atomic.LoadUint64(&hashes), hashRate(&hashes, started),
+			)
+
+			return *found, hash, nil
+		}
+
+		// Every nonce has been tried, so a new timestamp is needed to get
+		// different hashes.
+		header.Timestamp = nextTimestamp(header.Timestamp)
+	}
 }
 
-// Run performs a proof-of-work run for a block. We iteratively hash the block
-// and compare the hash to the proof target. The comparison is done by writing
-// the block hash bytes to a big int. If the block hash is less than the target
-// then the proof is valid and vice versa.
-func (p *Proof) Run() (int, []byte) {
-	var hashInt big.Int
-	var hash [32]byte
-	nonce := 0
+// search looks for a nonce which gives the header a hash below the target.
+// Nil is returned if there is no such nonce.
+func (p *Proof) search(ctx context.Context, header BlockHeader, workers int, hashes *uint64) (*BlockHeader, error) {
+	var wg sync.WaitGroup
+
+	found := make(chan BlockHeader, workers)
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+
+	span := (uint64(math.MaxUint32) + 1) / uint64(workers)
+	for i := 0; i < workers; i++ {
+		first := uint64(i) * span
+		last := first + span
+		if i == workers-1 {
+			last = uint64(math.MaxUint32) + 1
+		}
 
-	fmt.Println("Mining a new block")
-	for nonce < maxNonce {
-		data := p.prepareData(nonce)
-		hash = sha256.Sum256(data)
+		wg.Add(1)
+		go func(first, last uint64) {
+			defer wg.Done()
+
+			var hashInt big.Int
+			var tried uint64
+			defer func() { atomic.AddUint64(hashes, tried) }()
+
+			// The nonce is the last field of the header encoding, so it can
+			// be changed in place rather than encoding every attempt.
+			data := header.Serialize()
+			nonce := data[len(data)-4:]
+
+			for n := first; n < last; n++ {
+				if tried%nonceBatch == 0 {
+					select {
+					case <-ctx.Done():
+						return
+					case <-stop:
+						return
+					default:
+					}
+				}
+
+				binary.LittleEndian.PutUint32(nonce, uint32(n))
+				hash := sha256.Sum256(data)
+				tried++
+
+				hashInt.SetBytes(hash[:])
+				if hashInt.Cmp(p.target) == -1 {
+					h := header
+					h.Nonce = uint32(n)
+					found <- h
+
+					stopOnce.Do(func() { close(stop) })
+					return
+				}
+			}
+		}(first, last)
+	}
+	wg.Wait()
 
-		fmt.Printf("\r%x", hash)
+	select {
+	case h := <-found:
+		return &h, nil
+	default:
+	}
 
-		hashInt.SetBytes(hash[:])
+	return nil, ctx.Err()
+}
 
-		if hashInt.Cmp(p.target) == -1 {
-			break
-		} else {
-			nonce++
+// Validate validates a blocks proof-of-work. The target of the block must be
+// the one the chain requires at the height of the block, and the hash of the
+// block must be below it.
+func (p *Proof) Validate(bc *Blockchain) bool {
+	requiredBits := Net.PowLimitBits
+	if len(p.block.PrevBlockHash) > 0 {
+		parent, err := bc.GetBlock(p.block.PrevBlockHash)
+		if err != nil {
+			return false
 		}
+
+		requiredBits = bc.requiredBits(&parent)
+	}
+
+	if p.block.Bits != requiredBits {
+		return false
 	}
-	fmt.Print("\n\n")
 
-	return nonce, hash[:]
+	return p.block.BlockHeader.CheckProof()
 }
 
-// Validate validates a blocks proof-of-work.
-func (p *Proof) Validate() bool {
-	var hashInt big.Int
+// Work returns the expected number of hashes needed to meet the proof target.
+// Chains are compared by the sum of the work of their blocks. A target of zero
+// or below can't be met, so counts for no work.
+func (p *Proof) Work() *big.Int {
+	if p.target.Sign() <= 0 {
+		return big.NewInt(0)
+	}
 
-	data := p.prepareData(p.block.Nonce)
-	hash := sha256.Sum256(data)
-	hashInt.SetBytes(hash[:])
+	denominator := new(big.Int).Add(p.target, big.NewInt(1))
+	work := new(big.Int).Lsh(big.NewInt(1), 256)
 
-	return hashInt.Cmp(p.target) == -1
+	return work.Div(work, denominator)
 }
 
-// NewProof builds a new proof with a target.  The target for the proof is 256
-// minus our targetBits value, because 256 is the length of the hash we get
-// from a block.
+// NewProof builds a new proof with the target given by the compact bits of
+// the block.
 func NewProof(b *Block) *Proof {
-	target := big.NewInt(1)
-	target.Lsh(target, uint(256-targetBits))
+	return &Proof{b, CompactToBig(b.Bits)}
+}
+
+// nextTimestamp returns the current time, or a second after prev if the
+// current time is not later.
+func nextTimestamp(prev time.Time) time.Time {
+	now := time.Unix(time.Now().Unix(), 0)
+	if !now.After(prev) {
+		now = prev.Add(time.Second)
+	}
+
+	return now
+}
+
+// reportHashRate prints the hash rate periodically until done is closed.
+func reportHashRate(hashes *uint64, started time.Time, done <-chan struct{}) {
+	ticker := time.NewTicker(hashRateInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			fmt.Printf("Hash rate: %s\n", hashRate(hashes, started))
+		case <-done:
+			return
+		}
+	}
+}
+
+// hashRate formats the average number of hashes per second since started.
+func hashRate(hashes *uint64, started time.Time) string {
+	rate := float64(atomic.LoadUint64(hashes)) / time.Since(started).Seconds()
+
+	switch {
+	case rate >= 1e9:
+		return fmt.Sprintf("%.2f GH/s", rate/1e9)
+	case rate >= 1e6:
+		return fmt.Sprintf("%.2f MH/s", rate/1e6)
+	case rate >= 1e3:
+		return fmt.Sprintf("%.2f kH/s", rate/1e3)
+	}
 
-	return &Proof{b, target}
+	return fmt.Sprintf("%.0f H/s", rate)
 }
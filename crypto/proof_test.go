@@ -0,0 +1,49 @@
+package crypto
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProofRun(t *testing.T) {
+	block := &Block{
+		BlockHeader: BlockHeader{
+			Version:   blockVersion,
+			Timestamp: time.Unix(1514764800, 0),
+			Bits:      Net.PowLimitBits,
+		},
+	}
+
+	header, hash, err := NewProof(block).Run(context.Background(), 4)
+
+	assert.NoError(t, err, "Block is mined")
+	assert.Equal(t, header.Hash(), hash, "Hash is of the mined header")
+	assert.True(t, header.CheckProof(), "Mined header meets the target")
+}
+
+func TestProofRunCancelled(t *testing.T) {
+	// No hash can meet a zero target, so mining only stops when cancelled.
+	block := &Block{}
+	pow := NewProof(block)
+	pow.target.SetInt64(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, _, err := pow.Run(ctx, 2)
+
+	assert.Equal(t, context.DeadlineExceeded, err, "Mining stops when cancelled")
+}
+
+func TestProofWork(t *testing.T) {
+	block := &Block{BlockHeader: BlockHeader{Bits: Net.PowLimitBits}}
+	assert.Equal(t, 1, NewProof(block).Work().Sign(), "Target has work")
+
+	for _, bits := range []uint32{0, 0x01920000} {
+		block.Bits = bits
+		assert.Equal(t, 0, NewProof(block).Work().Sign(), "Target %08x has no work", bits)
+	}
+}
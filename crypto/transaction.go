@@ -1,26 +1,27 @@
 package crypto
 
 import (
-	"bytes"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
-	"encoding/gob"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"math/big"
 	"os"
-)
 
-const subsidy = 10 // The amount of reward for mining.
+	"github.com/danmrichards/yagocoin/script"
+)
 
-// Transaction represents a yagocoin transaction.
+// Transaction represents a yagocoin transaction. It can't be included in a
+// block until its LockTime has passed, see IsFinal.
 type Transaction struct {
-	ID   []byte
-	Vin  []TxInput
-	Vout []TxOutput
+	ID       []byte
+	Vin      []TxInput
+	Vout     []TxOutput
+	LockTime uint32
 }
 
 // IsCoinbase checks whether the transaction is a 'coinbase' transaction.
@@ -28,17 +29,13 @@ func (tx Transaction) IsCoinbase() bool {
 	return len(tx.Vin) == 1 && len(tx.Vin[0].Txid) == 0 && tx.Vin[0].Vout == -1
 }
 
-// Serialize returns a serialized Transaction.
+// Serialize returns a Transaction serialized in the canonical encoding.
 func (tx *Transaction) Serialize() []byte {
-	var encoded bytes.Buffer
+	var e encoder
 
-	enc := gob.NewEncoder(&encoded)
-	err := enc.Encode(tx)
-	if err != nil {
-		log.Panic(err)
-	}
+	e.transaction(tx)
 
-	return encoded.Bytes()
+	return e.Bytes()
 }
 
 // Hash returns the hash of the Transaction.
@@ -59,23 +56,24 @@ func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTxs map[string]Transac
 		return
 	}
 
-	txCopy := tx.TrimmedCopy()
-	for inID, vin := range txCopy.Vin {
+	pubKey := pubKeyBytes(&privKey.PublicKey)
+
+	for inID, vin := range tx.Vin {
 		prevTx := prevTxs[hex.EncodeToString(vin.Txid)]
-		txCopy.Vin[inID].Signature = nil
-		txCopy.Vin[inID].PubKey = prevTx.Vout[vin.Vout].PubKeyHash
-		txCopy.ID = txCopy.Hash()
-		txCopy.Vin[inID].PubKey = nil
+		hash := tx.SignatureHash(inID, prevTx.Vout[vin.Vout].ScriptPubKey)
 
-		r, s, err := ecdsa.Sign(rand.Reader, &privKey, txCopy.ID)
-		if err != nil {
-			log.Panic(err)
-		}
+		tx.Vin[inID].ScriptSig = script.SignatureScript(signHash(privKey, hash), pubKey)
+	}
+}
 
-		signature := append(r.Bytes(), s.Bytes()...)
+// SignatureHash returns the hash signed for input inID, which spends an output
+// locked with prevScript. It covers the whole transaction apart from the
+// signature scripts, with prevScript in place of the script of the input.
+func (tx *Transaction) SignatureHash(inID int, prevScript []byte) []byte {
+	txCopy := tx.TrimmedCopy()
+	txCopy.Vin[inID].ScriptSig = prevScript
 
-		tx.Vin[inID].Signature = signature
-	}
+	return txCopy.Hash()
 }
 
 // TrimmedCopy creates a trimmed copy of Transaction to be used in signing.
@@ -84,44 +82,30 @@ func (tx *Transaction) TrimmedCopy() Transaction {
 	var outputs []TxOutput
 
 	for _, vin := range tx.Vin {
-		inputs = append(inputs, TxInput{vin.Txid, vin.Vout, nil, nil})
+		inputs = append(inputs, TxInput{vin.Txid, vin.Vout, nil, vin.Sequence})
 	}
 
 	for _, vout := range tx.Vout {
-		outputs = append(outputs, TxOutput{vout.Value, vout.PubKeyHash})
+		outputs = append(outputs, TxOutput{vout.Value, vout.ScriptPubKey})
 	}
 
-	txCopy := Transaction{tx.ID, inputs, outputs}
+	txCopy := Transaction{tx.ID, inputs, outputs, tx.LockTime}
 
 	return txCopy
 }
 
-// Verify verifies signatures of Transaction inputs.
+// Verify runs the script of each input against the script of the output it
+// spends.
 func (tx *Transaction) Verify(prevTXs map[string]Transaction) bool {
-	txCopy := tx.TrimmedCopy()
-	curve := elliptic.P256()
-
 	for inID, vin := range tx.Vin {
-		prevTx := prevTXs[hex.EncodeToString(vin.Txid)]
-		txCopy.Vin[inID].Signature = nil
-		txCopy.Vin[inID].PubKey = prevTx.Vout[vin.Vout].PubKeyHash
-		txCopy.ID = txCopy.Hash()
-		txCopy.Vin[inID].PubKey = nil
-
-		r := big.Int{}
-		s := big.Int{}
-		sigLen := len(vin.Signature)
-		r.SetBytes(vin.Signature[:(sigLen / 2)])
-		s.SetBytes(vin.Signature[(sigLen / 2):])
-
-		x := big.Int{}
-		y := big.Int{}
-		keyLen := len(vin.PubKey)
-		x.SetBytes(vin.PubKey[:(keyLen / 2)])
-		y.SetBytes(vin.PubKey[(keyLen / 2):])
-
-		rawPubKey := ecdsa.PublicKey{Curve: curve, X: &x, Y: &y}
-		if ecdsa.Verify(&rawPubKey, txCopy.ID, &r, &s) == false {
+		prevTx, ok := prevTXs[hex.EncodeToString(vin.Txid)]
+		if !ok || vin.Vout < 0 || vin.Vout >= len(prevTx.Vout) {
+			return false
+		}
+
+		prevScript := prevTx.Vout[vin.Vout].ScriptPubKey
+		err := script.Execute(vin.ScriptSig, prevScript, sigChecker{tx, inID, prevScript})
+		if err != nil {
 			return false
 		}
 	}
@@ -129,11 +113,80 @@ func (tx *Transaction) Verify(prevTXs map[string]Transaction) bool {
 	return true
 }
 
+// sigChecker checks signatures for an input of a transaction.
+type sigChecker struct {
+	tx         *Transaction
+	inID       int
+	prevScript []byte
+}
+
+// CheckSig reports whether sig is a signature of the input by pubKey.
+func (c sigChecker) CheckSig(sig, pubKey []byte) bool {
+	if len(sig) == 0 || len(sig)%2 != 0 || len(pubKey) == 0 || len(pubKey)%2 != 0 {
+		return false
+	}
+
+	curve := elliptic.P256()
+
+	r := big.Int{}
+	s := big.Int{}
+	sigLen := len(sig)
+	r.SetBytes(sig[:(sigLen / 2)])
+	s.SetBytes(sig[(sigLen / 2):])
+
+	x := big.Int{}
+	y := big.Int{}
+	keyLen := len(pubKey)
+	x.SetBytes(pubKey[:(keyLen / 2)])
+	y.SetBytes(pubKey[(keyLen / 2):])
+
+	if !curve.IsOnCurve(&x, &y) {
+		return false
+	}
+
+	rawPubKey := ecdsa.PublicKey{Curve: curve, X: &x, Y: &y}
+
+	return ecdsa.Verify(&rawPubKey, c.tx.SignatureHash(c.inID, c.prevScript), &r, &s)
+}
+
+// signHash signs a hash, returning the r and s values of the signature each
+// padded to the size of the curve.
+func signHash(privKey ecdsa.PrivateKey, hash []byte) []byte {
+	r, s, err := ecdsa.Sign(rand.Reader, &privKey, hash)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	size := (privKey.Curve.Params().BitSize + 7) / 8
+
+	return append(paddedBytes(r, size), paddedBytes(s, size)...)
+}
+
+// pubKeyBytes returns a public key as its x and y co-ordinates, each padded to
+// the size of the curve.
+func pubKeyBytes(pub *ecdsa.PublicKey) []byte {
+	size := (pub.Curve.Params().BitSize + 7) / 8
+
+	return append(paddedBytes(pub.X, size), paddedBytes(pub.Y, size)...)
+}
+
+// paddedBytes returns the big endian bytes of n, left padded with zeros to
+// size bytes.
+func paddedBytes(n *big.Int, size int) []byte {
+	b := n.Bytes()
+	if len(b) >= size {
+		return b
+	}
+
+	return append(make([]byte, size-len(b)), b...)
+}
+
 // NewCoinbaseTx creates a new 'coinbase' transaction. This is a special type
 // of transactions, which doesn’t require previously existing outputs. It
 // creates outputs (i.e. coins) out of nowhere becoming the reward miners get
-// for mining new blocks.
-func NewCoinbaseTx(to, data string) *Transaction {
+// for mining new blocks. The reward is the subsidy for the height of the block
+// plus the fees of the other transactions in the block.
+func NewCoinbaseTx(to, data string, height, fees int) *Transaction {
 	if data == "" {
 		randData := make([]byte, 20)
 		_, err := rand.Read(randData)
@@ -144,66 +197,126 @@ func NewCoinbaseTx(to, data string) *Transaction {
 		data = fmt.Sprintf("%x", randData)
 	}
 
-	txIn := TxInput{[]byte{}, -1, nil, []byte(data)}
-	txOut := NewTxOutput(subsidy, to)
+	txIn := TxInput{[]byte{}, -1, []byte(data), SequenceFinal}
+	txOut := NewTxOutput(Emission.Subsidy(height)+fees, to)
 
-	tx := Transaction{nil, []TxInput{txIn}, []TxOutput{*txOut}}
+	tx := Transaction{nil, []TxInput{txIn}, []TxOutput{*txOut}, 0}
 	tx.ID = tx.Hash()
 
 	return &tx
 }
 
-// NewUTxOTransaction creates a new transaction.
-func NewUTxOTransaction(wallet *Wallet, to string, amount int, uTxOSet *UTxOSet) *Transaction {
+// NewUTxOTransaction creates a new transaction. The fee is left over from the
+// inputs once the outputs are paid, for the miner of the block to collect.
+func NewUTxOTransaction(wallet *Wallet, to string, amount, fee int, uTxOSet *UTxOSet) *Transaction {
+	tx, err := NewLockedUTxOTransaction(wallet, to, "", amount, fee, 0, SequenceFinal, uTxOSet)
+	if err != nil {
+		fmt.Printf("ERROR: %s\n", err)
+		os.Exit(1)
+	}
+
+	return tx
+}
+
+// NewLockedUTxOTransaction creates a new transaction which can't be mined
+// until lockTime, and whose inputs each have the given sequence. Pass a lock
+// time of 0 and SequenceFinal for a transaction with no locks. The lock time
+// only applies if the sequence isn't SequenceFinal. Any change goes to the
+// change address, or back to the wallet if it is empty.
+func NewLockedUTxOTransaction(wallet *Wallet, to, change string, amount, fee int, lockTime, sequence uint32, uTxOSet *UTxOSet) (*Transaction, error) {
 	var inputs []TxInput
 	var outputs []TxOutput
 
 	pubKeyHash := HashPubKey(wallet.PublicKey)
-	acc, validOutputs := uTxOSet.FindSpendableOutputs(pubKeyHash, amount)
+	acc, validOutputs := uTxOSet.FindSpendableOutputs(pubKeyHash, amount+fee)
 
-	if acc < amount {
-		fmt.Println("ERROR: Not enough funds")
-		os.Exit(1)
+	if acc < amount+fee {
+		return nil, errors.New("not enough funds")
 	}
 
 	// Build a list of inputs
-	for txid, outs := range validOutputs {
-		txID, err := hex.DecodeString(txid)
-		if err != nil {
-			log.Panic(err)
-		}
-
-		for _, out := range outs {
-			input := TxInput{txID, out, nil, wallet.PublicKey}
-			inputs = append(inputs, input)
-		}
-	}
+	inputs = newInputs(validOutputs, nil, sequence)
 
 	// Build a list of outputs
-	from := fmt.Sprintf("%s", wallet.GetAddress())
+	if change == "" {
+		change = fmt.Sprintf("%s", wallet.GetAddress())
+	}
 	outputs = append(outputs, *NewTxOutput(amount, to))
 
 	// Change.
-	if acc > amount {
-		outputs = append(outputs, *NewTxOutput(acc-amount, from))
+	if acc > amount+fee {
+		outputs = append(outputs, *NewTxOutput(acc-amount-fee, change))
 	}
 
-	tx := Transaction{nil, inputs, outputs}
+	// The ID covers the signatures, so it can only be set once signed.
+	tx := Transaction{nil, inputs, outputs, lockTime}
+	uTxOSet.Blockchain.SignTransaction(&tx, wallet.PrivateKey)
 	tx.ID = tx.Hash()
+
+	return &tx, nil
+}
+
+// NewDataTransaction creates a new transaction with an output carrying data,
+// paid for by the wallet. Whatever the inputs are worth beyond the fee goes
+// back to the wallet as change.
+func NewDataTransaction(wallet *Wallet, data []byte, fee int, uTxOSet *UTxOSet) (*Transaction, error) {
+	dataOut, err := NewDataOutput(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every transaction needs an input, even one with no fee.
+	needed := fee
+	if needed < 1 {
+		needed = 1
+	}
+
+	pubKeyHash := HashPubKey(wallet.PublicKey)
+	acc, validOutputs := uTxOSet.FindSpendableOutputs(pubKeyHash, needed)
+	if acc < needed {
+		return nil, errors.New("not enough funds")
+	}
+
+	outputs := []TxOutput{*dataOut}
+	if acc > fee {
+		outputs = append(outputs, *NewTxOutput(acc-fee, string(wallet.GetAddress())))
+	}
+
+	tx := Transaction{nil, newInputs(validOutputs, nil, SequenceFinal), outputs, 0}
 	uTxOSet.Blockchain.SignTransaction(&tx, wallet.PrivateKey)
+	tx.ID = tx.Hash()
 
-	return &tx
+	return &tx, nil
 }
 
-// DeserializeTransaction deserializes a transaction
-func DeserializeTransaction(data []byte) Transaction {
-	var transaction Transaction
+// newInputs returns inputs spending the outputs found by FindSpendableOutputs,
+// each with the given signature script and sequence.
+func newInputs(validOutputs map[string][]int, scriptSig []byte, sequence uint32) []TxInput {
+	var inputs []TxInput
 
-	decoder := gob.NewDecoder(bytes.NewReader(data))
-	err := decoder.Decode(&transaction)
-	if err != nil {
-		log.Panic(err)
+	for txid, outs := range validOutputs {
+		txID, err := hex.DecodeString(txid)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		for _, out := range outs {
+			inputs = append(inputs, TxInput{txID, out, scriptSig, sequence})
+		}
+	}
+
+	return inputs
+}
+
+// DeserializeTransaction deserializes a transaction from the canonical
+// encoding.
+func DeserializeTransaction(data []byte) (Transaction, error) {
+	d := decoder{data: data}
+	tx := d.transaction()
+
+	if err := d.finish(); err != nil {
+		return Transaction{}, fmt.Errorf("could not decode transaction: %s", err)
 	}
 
-	return transaction
+	return *tx, nil
 }
@@ -1,18 +1,34 @@
 package crypto
 
-import "bytes"
+import (
+	"bytes"
 
-// TxInput respresents a transaction input.
+	"github.com/danmrichards/yagocoin/script"
+)
+
+// TxInput respresents a transaction input. The ScriptSig satisfies the public
+// key script of the output being spent. Coinbase inputs hold arbitrary data
+// in its place. The Sequence can hold a relative lock time, see
+// RelativeLockBlocks and RelativeLockTime, and is SequenceFinal otherwise.
 type TxInput struct {
 	Txid      []byte
 	Vout      int
-	Signature []byte
-	PubKey    []byte
+	ScriptSig []byte
+	Sequence  uint32
+}
+
+// PubKey returns the public key which signed an input spending a pay to public
+// key hash output, or nil for other inputs.
+func (in *TxInput) PubKey() []byte {
+	return script.ExtractSigPubKey(in.ScriptSig)
 }
 
 // UsesKey checks whether the address initiated the transaction.
 func (in *TxInput) UsesKey(pubKeyHash []byte) bool {
-	lockingHash := HashPubKey(in.PubKey)
+	pubKey := in.PubKey()
+	if pubKey == nil {
+		return false
+	}
 
-	return bytes.Compare(lockingHash, pubKeyHash) == 0
+	return bytes.Compare(HashPubKey(pubKey), pubKeyHash) == 0
 }
@@ -2,24 +2,83 @@ package crypto
 
 import (
 	"bytes"
-	"encoding/gob"
 	"log"
+	"sort"
+
+	"github.com/danmrichards/yagocoin/script"
 )
 
-// TxOutput represents a transaction output.
+// TxOutput represents a transaction output. The ScriptPubKey sets the
+// conditions for spending it.
 type TxOutput struct {
-	Value      int
-	PubKeyHash []byte
+	Value        int
+	ScriptPubKey []byte
 }
 
-// Lock signs the output.
+// Lock locks the output to an address.
 func (out *TxOutput) Lock(address []byte) {
-	out.PubKeyHash = GetPublicKeyHash(address)
+	out.ScriptPubKey = AddressScript(address)
+}
+
+// PubKeyHash returns the public key hash the output pays to, or nil if it
+// isn't a pay to public key hash output.
+func (out *TxOutput) PubKeyHash() []byte {
+	return script.ExtractPubKeyHash(out.ScriptPubKey)
+}
+
+// AddressHash returns the hash in the address the output pays to, which is
+// either a public key hash or a redeem script hash. Nil is returned if the
+// output doesn't pay to an address.
+func (out *TxOutput) AddressHash() []byte {
+	if pubKeyHash := script.ExtractPubKeyHash(out.ScriptPubKey); pubKeyHash != nil {
+		return pubKeyHash
+	}
+
+	return script.ExtractScriptHash(out.ScriptPubKey)
+}
+
+// Address returns the base58 encoded address the output pays to, or nil if it
+// doesn't pay to an address.
+func (out *TxOutput) Address() []byte {
+	if pubKeyHash := script.ExtractPubKeyHash(out.ScriptPubKey); pubKeyHash != nil {
+		return AddressFromPubKeyHash(pubKeyHash)
+	}
+
+	if scriptHash := script.ExtractScriptHash(out.ScriptPubKey); scriptHash != nil {
+		return AddressFromScriptHash(scriptHash)
+	}
+
+	return nil
 }
 
 // IsLockedWithKey checks if the output can be used by the owner of the pubkey.
 func (out *TxOutput) IsLockedWithKey(pubKeyHash []byte) bool {
-	return bytes.Compare(out.PubKeyHash, pubKeyHash) == 0
+	return script.IsPayToPubKeyHash(out.ScriptPubKey, pubKeyHash)
+}
+
+// IsLockedWithHash checks if the output pays to the address with the given
+// hash, as returned by GetPublicKeyHash.
+func (out *TxOutput) IsLockedWithHash(hash []byte) bool {
+	addressHash := out.AddressHash()
+
+	return addressHash != nil && bytes.Equal(addressHash, hash)
+}
+
+// IsUnspendable reports whether the output can never be spent, such as a data
+// output. Unspendable outputs are left out of the UTXO set.
+func (out *TxOutput) IsUnspendable() bool {
+	return script.IsUnspendable(out.ScriptPubKey)
+}
+
+// NewDataOutput creates an unspendable TXOutput carrying up to
+// script.MaxDataCarrierSize bytes of data.
+func NewDataOutput(data []byte) (*TxOutput, error) {
+	dataScript, err := script.NullDataScript(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TxOutput{0, dataScript}, nil
 }
 
 // NewTxOutput create a new TXOutput.
@@ -30,31 +89,73 @@ func NewTxOutput(value int, address string) *TxOutput {
 	return txo
 }
 
-// TXOutputs collects TXOutput
+// TXOutputs collects the unspent TXOutputs of a transaction, keyed by their
+// index in the transaction, along with the height of the block holding the
+// transaction and whether it is a coinbase.
 type TxOutputs struct {
-	Outputs []TxOutput
+	Outputs  map[int]TxOutput
+	Height   int
+	Coinbase bool
+}
+
+// NewTxOutputs creates an empty TxOutputs.
+func NewTxOutputs() TxOutputs {
+	return TxOutputs{Outputs: make(map[int]TxOutput)}
+}
+
+// IsMature reports whether the outputs can be spent by a block at the given
+// height. Coinbase outputs must be buried under CoinbaseMaturity blocks of the
+// network first.
+func (outs TxOutputs) IsMature(height int) bool {
+	return isMature(outs.Coinbase, outs.Height, height)
+}
+
+// isMature reports whether an output created at a height, by a coinbase or
+// not, can be spent by a block at spendHeight.
+func isMature(coinbase bool, height, spendHeight int) bool {
+	return !coinbase || spendHeight-height >= Net.CoinbaseMaturity
 }
 
-// Serialize serializes TXOutputs
+// Serialize serializes TXOutputs in the canonical encoding, in order of their
+// index.
 func (outs TxOutputs) Serialize() []byte {
-	var buff bytes.Buffer
+	var e encoder
+	var indexes []int
 
-	enc := gob.NewEncoder(&buff)
-	err := enc.Encode(outs)
-	if err != nil {
-		log.Panic(err)
+	for outIdx := range outs.Outputs {
+		indexes = append(indexes, outIdx)
 	}
+	sort.Ints(indexes)
 
-	return buff.Bytes()
+	e.uvarint(utxoEncodingVersion)
+	e.uvarint(uint64(outs.Height))
+	e.bool(outs.Coinbase)
+	e.uvarint(uint64(len(indexes)))
+	for _, outIdx := range indexes {
+		e.uvarint(uint64(outIdx))
+		e.output(outs.Outputs[outIdx])
+	}
+
+	return e.Bytes()
 }
 
-// DeserializeOutputs deserializes TXOutputs
+// DeserializeOutputs deserializes TXOutputs from the canonical encoding.
 func DeserializeOutputs(data []byte) TxOutputs {
-	var outputs TxOutputs
+	outputs := NewTxOutputs()
+	d := decoder{data: data}
 
-	dec := gob.NewDecoder(bytes.NewReader(data))
-	err := dec.Decode(&outputs)
-	if err != nil {
+	version := d.version(utxoEncodingVersion)
+	if version >= 2 {
+		outputs.Height = int(d.uvarint())
+		outputs.Coinbase = d.bool()
+	}
+
+	for i, n := 0, d.count(); i < n; i++ {
+		outIdx := int(d.uvarint())
+		outputs.Outputs[outIdx] = d.utxoOutput(version)
+	}
+
+	if err := d.finish(); err != nil {
 		log.Panic(err)
 	}
 
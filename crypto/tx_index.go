@@ -0,0 +1,178 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/boltdb/bolt"
+)
+
+const txIndexBucket = "txindex"
+
+// txLocation records where a transaction is on the main chain.
+type txLocation struct {
+	BlockHash []byte
+	Position  int // Index of the transaction in the block.
+}
+
+// Serialize serializes a transaction location in the canonical encoding.
+func (l txLocation) Serialize() []byte {
+	var e encoder
+
+	e.uvarint(encodingVersion)
+	e.bytes(l.BlockHash)
+	e.uvarint(uint64(l.Position))
+
+	return e.Bytes()
+}
+
+// deserializeTxLocation deserializes a transaction location.
+func deserializeTxLocation(data []byte) (txLocation, error) {
+	var l txLocation
+	d := decoder{data: data}
+
+	d.version(encodingVersion)
+	l.BlockHash = d.bytes()
+	l.Position = int(d.uvarint())
+
+	if err := d.finish(); err != nil {
+		return l, fmt.Errorf("could not decode transaction location: %s", err)
+	}
+
+	return l, nil
+}
+
+// FindTransactionBlock finds a transaction on the main chain by its ID using
+// the transaction index, and returns it along with the block it is in.
+func (bc *Blockchain) FindTransactionBlock(ID []byte) (Transaction, Block, error) {
+	var transaction *Transaction
+	var block *Block
+
+	err := bc.db.View(func(tx *bolt.Tx) error {
+		var err error
+		transaction, block, err = locateTransaction(tx, ID)
+
+		return err
+	})
+	if err != nil {
+		return Transaction{}, Block{}, err
+	}
+
+	return *transaction, *block, nil
+}
+
+// ReindexTransactions rebuilds the transaction index from the main chain and
+// returns the number of transactions indexed.
+func (bc *Blockchain) ReindexTransactions() int {
+	count := 0
+
+	err := bc.db.Update(func(tx *bolt.Tx) error {
+		err := tx.DeleteBucket([]byte(txIndexBucket))
+		if err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+
+		tip := tx.Bucket([]byte(blocksBucket)).Get([]byte(hashKey))
+		count, err = indexChainTransactions(tx, tip)
+
+		return err
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return count
+}
+
+// locateTransaction finds a transaction on the main chain by its ID using the
+// transaction index, and returns it along with the block it is in.
+func locateTransaction(tx *bolt.Tx, ID []byte) (*Transaction, *Block, error) {
+	data := tx.Bucket([]byte(txIndexBucket)).Get(ID)
+	if data == nil {
+		return nil, nil, errors.New("transaction is not found")
+	}
+
+	location, err := deserializeTxLocation(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	blockData := tx.Bucket([]byte(blocksBucket)).Get(location.BlockHash)
+	if blockData == nil {
+		return nil, nil, errors.New("block is not found")
+	}
+
+	block, err := DeserializeBlock(blockData)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if location.Position >= len(block.Transactions) ||
+		!bytes.Equal(block.Transactions[location.Position].ID, ID) {
+		return nil, nil, errors.New("transaction index is corrupt, run reindex")
+	}
+
+	return block.Transactions[location.Position], block, nil
+}
+
+// indexChainTransactions creates the transaction index and fills it with the
+// transactions of the main chain ending at tip.
+func indexChainTransactions(tx *bolt.Tx, tip []byte) (int, error) {
+	count := 0
+	b := tx.Bucket([]byte(blocksBucket))
+
+	for hash := tip; len(hash) > 0; {
+		block, err := DeserializeBlock(b.Get(hash))
+		if err != nil {
+			return count, err
+		}
+
+		err = indexTransactions(tx, block)
+		if err != nil {
+			return count, err
+		}
+
+		count += len(block.Transactions)
+		hash = block.PrevBlockHash
+	}
+
+	return count, nil
+}
+
+// indexTransactions adds the transactions of a block joining the main chain
+// to the transaction index.
+func indexTransactions(tx *bolt.Tx, block *Block) error {
+	t, err := tx.CreateBucketIfNotExists([]byte(txIndexBucket))
+	if err != nil {
+		return err
+	}
+
+	for i, transaction := range block.Transactions {
+		err := t.Put(transaction.ID, txLocation{block.Hash, i}.Serialize())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unindexTransactions removes the transactions of a block leaving the main
+// chain from the transaction index.
+func unindexTransactions(tx *bolt.Tx, block *Block) error {
+	t := tx.Bucket([]byte(txIndexBucket))
+	if t == nil {
+		return nil
+	}
+
+	for _, transaction := range block.Transactions {
+		err := t.Delete(transaction.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
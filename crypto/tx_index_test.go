@@ -0,0 +1,28 @@
+package crypto
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindTransactionBlock(t *testing.T) {
+	a, b := NewWallet(), NewWallet()
+	bc := newTestChain(t, a)
+	uTxOSet := UTxOSet{Blockchain: bc}
+
+	tx := NewUTxOTransaction(a, string(b.GetAddress()), 4, 0, &uTxOSet)
+	block, err := bc.MineBlock(context.Background(), []*Transaction{NewCoinbaseTx(string(b.GetAddress()), "", 1, 0), tx})
+	assert.NoError(t, err, "Block is mined")
+
+	found, foundBlock, err := bc.FindTransactionBlock(tx.ID)
+	assert.NoError(t, err, "Transaction is found")
+	assert.Equal(t, tx.ID, found.ID, "Transaction is found")
+	assert.Equal(t, block.Hash, foundBlock.Hash, "Block of the transaction is found")
+
+	assert.Equal(t, 3, bc.ReindexTransactions(), "Every transaction is indexed")
+
+	_, err = bc.FindTransaction([]byte("missing"))
+	assert.Error(t, err, "Unknown transaction is not found")
+}
@@ -0,0 +1,79 @@
+package crypto
+
+import (
+	"log"
+)
+
+// Undo data is encoded with the values described in encoding.go as:
+//
+//	uvarint  format version, currently 3
+//	uvarint  number of spent outputs, followed by each output as
+//	bytes    ID of the transaction the output belongs to
+//	uvarint  index of the output in its transaction
+//	TxOutput the output
+//	uvarint  height of the block holding the transaction
+//	uvarint  1 if the transaction is a coinbase, 0 otherwise
+//
+// Version 1 undo data has no height or coinbase flag. Before version 3 each
+// output was written as its value and the public key hash it was locked to,
+// rather than its script.
+
+// spentOutput is an output removed from the UTXO set when a block was
+// connected.
+type spentOutput struct {
+	Txid     []byte
+	Index    int
+	Output   TxOutput
+	Height   int
+	Coinbase bool
+}
+
+// blockUndo holds the data needed to disconnect a block from the UTXO set, in
+// the order the block spent it.
+type blockUndo struct {
+	Spent []spentOutput
+}
+
+// Serialize serializes the undo data in the canonical encoding for storage.
+func (u blockUndo) Serialize() []byte {
+	var e encoder
+
+	e.uvarint(utxoEncodingVersion)
+	e.uvarint(uint64(len(u.Spent)))
+	for _, s := range u.Spent {
+		e.bytes(s.Txid)
+		e.uvarint(uint64(s.Index))
+		e.output(s.Output)
+		e.uvarint(uint64(s.Height))
+		e.bool(s.Coinbase)
+	}
+
+	return e.Bytes()
+}
+
+// deserializeUndo deserializes undo data from the canonical encoding.
+func deserializeUndo(data []byte) blockUndo {
+	var undo blockUndo
+	d := decoder{data: data}
+
+	version := d.version(utxoEncodingVersion)
+	for i, n := 0, d.count(); i < n; i++ {
+		var s spentOutput
+
+		s.Txid = d.bytes()
+		s.Index = int(d.uvarint())
+		s.Output = d.utxoOutput(version)
+		if version >= 2 {
+			s.Height = int(d.uvarint())
+			s.Coinbase = d.bool()
+		}
+
+		undo.Spent = append(undo.Spent, s)
+	}
+
+	if err := d.finish(); err != nil {
+		log.Panic(err)
+	}
+
+	return undo
+}
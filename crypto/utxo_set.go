@@ -2,22 +2,30 @@ package crypto
 
 import (
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"log"
 
 	"github.com/boltdb/bolt"
 )
 
-const utxoBucket = "chainstate"
+const (
+	utxoBucket = "chainstate"
+	undoBucket = "undo"
+)
 
 type UTxOSet struct {
 	Blockchain *Blockchain
 }
 
-// FindSpendableOutputs finds and returns unspent outputs to reference in inputs.
-func (u UTxOSet) FindSpendableOutputs(pubkeyHash []byte, amount int) (int, map[string][]int) {
+// FindSpendableOutputs finds and returns unspent outputs to reference in inputs,
+// for the address with the given public key or script hash. Coinbase outputs
+// which could not be spent in the next block are skipped.
+func (u UTxOSet) FindSpendableOutputs(hash []byte, amount int) (int, map[string][]int) {
 	unspentOutputs := make(map[string][]int)
 	accumulated := 0
 	db := u.Blockchain.db
+	spendHeight := u.Blockchain.GetBestHeight() + 1
 
 	err := db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(utxoBucket))
@@ -26,9 +34,12 @@ func (u UTxOSet) FindSpendableOutputs(pubkeyHash []byte, amount int) (int, map[s
 		for k, v := c.First(); k != nil; k, v = c.Next() {
 			txID := hex.EncodeToString(k)
 			outs := DeserializeOutputs(v)
+			if !outs.IsMature(spendHeight) {
+				continue
+			}
 
 			for outIdx, out := range outs.Outputs {
-				if out.IsLockedWithKey(pubkeyHash) && accumulated < amount {
+				if out.IsLockedWithHash(hash) && accumulated < amount {
 					accumulated += out.Value
 					unspentOutputs[txID] = append(unspentOutputs[txID], outIdx)
 				}
@@ -44,7 +55,79 @@ func (u UTxOSet) FindSpendableOutputs(pubkeyHash []byte, amount int) (int, map[s
 	return accumulated, unspentOutputs
 }
 
-// FindUTXO finds UTXO for a public key hash.
+// FindOutput returns the unspent output at index vout of the transaction txID.
+// False is returned if the output does not exist or has been spent.
+func (u UTxOSet) FindOutput(txID []byte, vout int) (TxOutput, bool) {
+	outs, found := u.FindTxOutputs(txID)
+	if !found {
+		return TxOutput{}, false
+	}
+
+	out, found := outs.Outputs[vout]
+
+	return out, found
+}
+
+// FindTxOutputs returns the unspent outputs of the transaction txID. False is
+// returned if the transaction has no unspent outputs.
+func (u UTxOSet) FindTxOutputs(txID []byte) (TxOutputs, bool) {
+	var outs TxOutputs
+	var found bool
+	db := u.Blockchain.db
+
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(utxoBucket))
+
+		outsBytes := b.Get(txID)
+		if outsBytes == nil {
+			return nil
+		}
+
+		outs, found = DeserializeOutputs(outsBytes), true
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return outs, found
+}
+
+// CalculateFee returns the fee paid by a transaction, which is whatever its
+// inputs are worth beyond its outputs. An error is returned if the transaction
+// spends outputs that are not in the UTXO set, or a RuleError if its values are
+// out of range or it spends more than its inputs.
+func (u UTxOSet) CalculateFee(tx *Transaction) (int, error) {
+	if tx.IsCoinbase() {
+		return 0, nil
+	}
+
+	inputValue := 0
+	for _, vin := range tx.Vin {
+		out, ok := u.FindOutput(vin.Txid, vin.Vout)
+		if !ok {
+			return 0, fmt.Errorf("output %x:%d is spent or does not exist", vin.Txid, vin.Vout)
+		}
+
+		if inputValue, ok = addValue(inputValue, out.Value); !ok {
+			return 0, ruleError(ErrBadTxOutValue, "transaction %x inputs are out of range", tx.ID)
+		}
+	}
+
+	value, err := outputValue(tx)
+	if err != nil {
+		return 0, err
+	}
+
+	if value > inputValue {
+		return 0, ruleError(ErrBadTxOutValue, "transaction %x spends more than its inputs", tx.ID)
+	}
+
+	return inputValue - value, nil
+}
+
+// FindUTXO finds UTXO for a public key or script hash.
 func (u UTxOSet) FindUTxO(pubKeyHash []byte) []TxOutput {
 	var UTXOs []TxOutput
 	db := u.Blockchain.db
@@ -57,7 +140,7 @@ func (u UTxOSet) FindUTxO(pubKeyHash []byte) []TxOutput {
 			outs := DeserializeOutputs(v)
 
 			for _, out := range outs.Outputs {
-				if out.IsLockedWithKey(pubKeyHash) {
+				if out.IsLockedWithHash(pubKeyHash) {
 					UTXOs = append(UTXOs, out)
 				}
 			}
@@ -72,34 +155,69 @@ func (u UTxOSet) FindUTxO(pubKeyHash []byte) []TxOutput {
 	return UTXOs
 }
 
+// GetBalance returns the value of the unspent outputs for a public key or
+// script hash which could be spent in the next block, and separately the value
+// of the coinbase outputs which are not yet mature.
+func (u UTxOSet) GetBalance(hash []byte) (spendable, immature int) {
+	db := u.Blockchain.db
+	spendHeight := u.Blockchain.GetBestHeight() + 1
+
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(utxoBucket))
+		c := b.Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			outs := DeserializeOutputs(v)
+
+			for _, out := range outs.Outputs {
+				if !out.IsLockedWithHash(hash) {
+					continue
+				}
+
+				if outs.IsMature(spendHeight) {
+					spendable += out.Value
+				} else {
+					immature += out.Value
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return spendable, immature
+}
+
 // Update updates the UTXO set with transactions from the Block. The Block is
-// considered to be the tip of a blockchain.
+// considered to be the tip of a blockchain. The outputs spent by the block are
+// kept as undo data so the update can be reverted by Disconnect. Unspendable
+// outputs are left out.
 func (u UTxOSet) Update(block *Block) {
 	db := u.Blockchain.db
 
 	err := db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(utxoBucket))
+		undo := blockUndo{}
 
 		for _, tx := range block.Transactions {
 			if tx.IsCoinbase() == false {
 				for _, vin := range tx.Vin {
-					updatedOuts := TxOutputs{}
 					outsBytes := b.Get(vin.Txid)
 					outs := DeserializeOutputs(outsBytes)
 
-					for outIdx, out := range outs.Outputs {
-						if outIdx != vin.Vout {
-							updatedOuts.Outputs = append(updatedOuts.Outputs, out)
-						}
-					}
+					undo.Spent = append(undo.Spent, spentOutput{vin.Txid, vin.Vout, outs.Outputs[vin.Vout], outs.Height, outs.Coinbase})
+					delete(outs.Outputs, vin.Vout)
 
-					if len(updatedOuts.Outputs) == 0 {
+					if len(outs.Outputs) == 0 {
 						err := b.Delete(vin.Txid)
 						if err != nil {
 							log.Panic(err)
 						}
 					} else {
-						err := b.Put(vin.Txid, updatedOuts.Serialize())
+						err := b.Put(vin.Txid, outs.Serialize())
 						if err != nil {
 							log.Panic(err)
 						}
@@ -108,9 +226,18 @@ func (u UTxOSet) Update(block *Block) {
 				}
 			}
 
-			newOutputs := TxOutputs{}
-			for _, out := range tx.Vout {
-				newOutputs.Outputs = append(newOutputs.Outputs, out)
+			newOutputs := NewTxOutputs()
+			newOutputs.Height = block.Height
+			newOutputs.Coinbase = tx.IsCoinbase()
+			for outIdx, out := range tx.Vout {
+				if !out.IsUnspendable() {
+					newOutputs.Outputs[outIdx] = out
+				}
+			}
+
+			// A transaction of only data outputs leaves nothing to spend.
+			if len(newOutputs.Outputs) == 0 {
+				continue
 			}
 
 			err := b.Put(tx.ID, newOutputs.Serialize())
@@ -119,13 +246,75 @@ func (u UTxOSet) Update(block *Block) {
 			}
 		}
 
-		return nil
+		ub, err := tx.CreateBucketIfNotExists([]byte(undoBucket))
+		if err != nil {
+			log.Panic(err)
+		}
+
+		return ub.Put(block.Hash, undo.Serialize())
 	})
 	if err != nil {
 		log.Panic(err)
 	}
 }
 
+// Disconnect reverts the changes made to the UTXO set by Update for the Block.
+// The Block is considered to be the tip of a blockchain. The outputs created
+// by the block are removed and the outputs it spent are restored from the
+// undo data.
+func (u UTxOSet) Disconnect(block *Block) error {
+	db := u.Blockchain.db
+
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(utxoBucket))
+		ub := tx.Bucket([]byte(undoBucket))
+
+		var undoData []byte
+		if ub != nil {
+			undoData = ub.Get(block.Hash)
+		}
+		if undoData == nil {
+			return errors.New("undo data is not found")
+		}
+		undo := deserializeUndo(undoData)
+
+		// Undo records are in spending order, so walk the transactions
+		// backwards to restore outputs created and spent in the same block.
+		for i := len(block.Transactions) - 1; i >= 0; i-- {
+			tx := block.Transactions[i]
+
+			err := b.Delete(tx.ID)
+			if err != nil {
+				log.Panic(err)
+			}
+
+			if tx.IsCoinbase() {
+				continue
+			}
+
+			spent := undo.Spent[len(undo.Spent)-len(tx.Vin):]
+			undo.Spent = undo.Spent[:len(undo.Spent)-len(tx.Vin)]
+
+			for _, s := range spent {
+				outs := NewTxOutputs()
+				if outsBytes := b.Get(s.Txid); outsBytes != nil {
+					outs = DeserializeOutputs(outsBytes)
+				}
+				outs.Outputs[s.Index] = s.Output
+				outs.Height = s.Height
+				outs.Coinbase = s.Coinbase
+
+				err := b.Put(s.Txid, outs.Serialize())
+				if err != nil {
+					log.Panic(err)
+				}
+			}
+		}
+
+		return ub.Delete(block.Hash)
+	})
+}
+
 // CountTransactions returns the number of transactions in the UTXO set.
 func (u UTxOSet) CountTransactions() int {
 	db := u.Blockchain.db
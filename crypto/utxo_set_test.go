@@ -0,0 +1,168 @@
+package crypto
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoinbaseMaturity(t *testing.T) {
+	a, b := NewWallet(), NewWallet()
+	bc := newTestChain(t, a)
+	Net.CoinbaseMaturity = 3
+	uTxOSet := UTxOSet{Blockchain: bc}
+	pool := NewMempool(bc, DefaultMempoolSize, DefaultMempoolExpiry)
+
+	spendable, immature := uTxOSet.GetBalance(HashPubKey(a.PublicKey))
+	assert.Equal(t, 0, spendable, "Genesis coinbase can't be spent yet")
+	assert.Equal(t, 10, immature, "Genesis coinbase is immature")
+
+	acc, _ := uTxOSet.FindSpendableOutputs(HashPubKey(a.PublicKey), 1)
+	assert.Equal(t, 0, acc, "Immature outputs are not spendable")
+
+	// Spend the genesis coinbase by hand, as there are no spendable outputs.
+	genesis, err := bc.GetBlockByHeight(0)
+	assert.NoError(t, err, "Genesis block is found")
+	tx := &Transaction{nil, []TxInput{{genesis.Transactions[0].ID, 0, nil, SequenceFinal}}, []TxOutput{*NewTxOutput(10, string(b.GetAddress()))}, 0}
+	bc.SignTransaction(tx, a.PrivateKey)
+	tx.ID = tx.Hash()
+
+	err = pool.Add(tx)
+	assert.Equal(t, ErrImmatureSpend, err.(RuleError).Code, "Mempool rejects the immature spend")
+
+	_, err = bc.MineBlock(context.Background(), []*Transaction{NewCoinbaseTx(string(b.GetAddress()), "", 1, 0), tx})
+	assert.Equal(t, ErrImmatureSpend, err.(RuleError).Code, "Block spending an immature coinbase is rejected")
+
+	_, err = bc.Generate(context.Background(), string(b.GetAddress()), 2)
+	assert.NoError(t, err, "Blocks are generated")
+
+	spendable, immature = uTxOSet.GetBalance(HashPubKey(a.PublicKey))
+	assert.Equal(t, 10, spendable, "Genesis coinbase has matured")
+	assert.Equal(t, 0, immature, "Nothing is left immature")
+
+	assert.NoError(t, pool.Add(tx), "Mempool accepts the mature spend")
+	_, err = bc.MineBlock(context.Background(), []*Transaction{NewCoinbaseTx(string(b.GetAddress()), "", 3, 0), tx})
+	assert.NoError(t, err, "Block spending a mature coinbase is mined")
+}
+
+func TestDataOutputs(t *testing.T) {
+	a := NewWallet()
+	bc := newTestChain(t, a)
+	uTxOSet := UTxOSet{Blockchain: bc}
+	pool := NewMempool(bc, DefaultMempoolSize, DefaultMempoolExpiry)
+
+	_, err := NewDataTransaction(a, make([]byte, 81), 1, &uTxOSet)
+	assert.Error(t, err, "Too much data is rejected")
+
+	tx, err := NewDataTransaction(a, []byte("document hash"), 1, &uTxOSet)
+	assert.NoError(t, err, "Data transaction is created")
+	assert.NoError(t, pool.Add(tx), "Mempool accepts the data transaction")
+
+	_, err = bc.MineBlock(context.Background(), []*Transaction{NewCoinbaseTx(string(a.GetAddress()), "", 1, 1), tx})
+	assert.NoError(t, err, "Data transaction is mined")
+
+	outs, found := uTxOSet.FindTxOutputs(tx.ID)
+	assert.True(t, found, "Change is unspent")
+	assert.Len(t, outs.Outputs, 1, "Data output is left out of the UTXO set")
+
+	uTxOSet.Reindex()
+	outs, _ = uTxOSet.FindTxOutputs(tx.ID)
+	assert.Len(t, outs.Outputs, 1, "Data output is left out when reindexing")
+
+	// Data outputs can't carry a value, or come more than one to a transaction.
+	for _, extra := range []TxOutput{{1, tx.Vout[0].ScriptPubKey}, tx.Vout[0]} {
+		bad := &Transaction{nil, tx.Vin, []TxOutput{tx.Vout[0], extra}, 0}
+		bad.ID = bad.Hash()
+		assert.Equal(t, ErrBadDataOutput, checkTransaction(bad).(RuleError).Code, "Bad data outputs are rejected")
+	}
+}
+
+func TestCalculateFee(t *testing.T) {
+	a, b := NewWallet(), NewWallet()
+	bc := newTestChain(t, a)
+	uTxOSet := UTxOSet{Blockchain: bc}
+	maxInt := int(^uint(0) >> 1)
+
+	tx := NewUTxOTransaction(a, string(b.GetAddress()), 4, 1, &uTxOSet)
+	fee, err := uTxOSet.CalculateFee(tx)
+	assert.NoError(t, err, "Fee is calculated")
+	assert.Equal(t, 1, fee, "Fee is what the inputs are worth beyond the outputs")
+
+	overspend := &Transaction{nil, tx.Vin, []TxOutput{*NewTxOutput(11, string(b.GetAddress()))}, 0}
+	overspend.ID = overspend.Hash()
+	_, err = uTxOSet.CalculateFee(overspend)
+	assert.Equal(t, ErrBadTxOutValue, err.(RuleError).Code, "Spending more than the inputs is rejected")
+
+	// Put outputs worth more than can exist in the UTXO set, whose sum wraps.
+	outs := NewTxOutputs()
+	outs.Outputs[0] = *NewTxOutput(maxInt, string(a.GetAddress()))
+	outs.Outputs[1] = *NewTxOutput(maxInt, string(a.GetAddress()))
+	err = bc.db.Update(func(dbTx *bolt.Tx) error {
+		return dbTx.Bucket([]byte(utxoBucket)).Put([]byte("huge"), outs.Serialize())
+	})
+	assert.NoError(t, err, "Outputs are stored")
+
+	wrapped := &Transaction{
+		nil,
+		[]TxInput{{[]byte("huge"), 0, nil, SequenceFinal}, {[]byte("huge"), 1, nil, SequenceFinal}},
+		[]TxOutput{*NewTxOutput(1, string(b.GetAddress()))},
+		0,
+	}
+	wrapped.ID = wrapped.Hash()
+	_, err = uTxOSet.CalculateFee(wrapped)
+	assert.Equal(t, ErrBadTxOutValue, err.(RuleError).Code, "Inputs out of range are rejected")
+}
+
+// utxoSnapshot returns a copy of every entry in the UTXO set.
+func utxoSnapshot(t *testing.T, bc *Blockchain) map[string]string {
+	snapshot := make(map[string]string)
+
+	err := bc.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(utxoBucket)).ForEach(func(k, v []byte) error {
+			snapshot[hex.EncodeToString(k)] = hex.EncodeToString(v)
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return snapshot
+}
+
+func TestDisconnect(t *testing.T) {
+	a, b := NewWallet(), NewWallet()
+	bc := newTestChain(t, a)
+	uTxOSet := UTxOSet{Blockchain: bc}
+	before := utxoSnapshot(t, bc)
+
+	genesis, err := bc.GetBlockByHeight(0)
+	assert.NoError(t, err, "Genesis block is found")
+
+	// The first transaction spends the genesis coinbase, and the second spends
+	// an output of the first in the same block.
+	first := NewUTxOTransaction(a, string(b.GetAddress()), 4, 1, &uTxOSet)
+	second := &Transaction{nil, []TxInput{{first.ID, 0, nil, SequenceFinal}}, []TxOutput{*NewTxOutput(3, string(a.GetAddress()))}, 0}
+	second.ID = second.Hash()
+
+	block := NewBlock(
+		[]*Transaction{NewCoinbaseTx(string(b.GetAddress()), "", 1, 2), first, second},
+		genesis.Hash, 1, bc.requiredBits(&genesis),
+	)
+
+	uTxOSet.Update(block)
+	_, found := uTxOSet.FindOutput(genesis.Transactions[0].ID, 0)
+	assert.False(t, found, "Coinbase output is spent")
+	_, found = uTxOSet.FindOutput(first.ID, 0)
+	assert.False(t, found, "Output spent in the same block is spent")
+	_, found = uTxOSet.FindOutput(second.ID, 0)
+	assert.True(t, found, "Output of the block is unspent")
+
+	assert.NoError(t, uTxOSet.Disconnect(block), "Block is disconnected")
+	assert.Equal(t, before, utxoSnapshot(t, bc), "UTXO set is as it was before the block")
+
+	assert.Error(t, uTxOSet.Disconnect(block), "Undo data is used up")
+}
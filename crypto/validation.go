@@ -0,0 +1,408 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/danmrichards/yagocoin/script"
+)
+
+const (
+	// How far into the future a block timestamp may be.
+	maxTimeOffset = 2 * time.Hour
+
+	// The number of blocks used to calculate the median time past.
+	medianTimeBlocks = 11
+)
+
+// ErrorCode identifies the consensus rule a block or transaction broke.
+type ErrorCode int
+
+const (
+	// ErrDuplicateBlock indicates the block is already known.
+	ErrDuplicateBlock ErrorCode = iota
+
+	// ErrOrphanBlock indicates the previous block is not known.
+	ErrOrphanBlock
+
+	// ErrBadHeight indicates the height doesn't follow the previous block.
+	ErrBadHeight
+
+	// ErrBadHash indicates the hash doesn't match the block contents.
+	ErrBadHash
+
+	// ErrBadProof indicates the proof-of-work target is wrong or not met.
+	ErrBadProof
+
+	// ErrBadMerkleRoot indicates the merkle root doesn't match the
+	// transactions.
+	ErrBadMerkleRoot
+
+	// ErrTimeTooOld indicates the timestamp is before the median time of the
+	// previous blocks.
+	ErrTimeTooOld
+
+	// ErrTimeTooNew indicates the timestamp is too far in the future.
+	ErrTimeTooNew
+
+	// ErrNoTransactions indicates the block has no transactions.
+	ErrNoTransactions
+
+	// ErrBadCoinbase indicates the block doesn't start with exactly one
+	// coinbase transaction.
+	ErrBadCoinbase
+
+	// ErrBadTxID indicates a transaction ID doesn't match its contents.
+	ErrBadTxID
+
+	// ErrDuplicateTx indicates the block contains a transaction twice.
+	ErrDuplicateTx
+
+	// ErrBadTxOutValue indicates an output value is invalid or a
+	// transaction spends more than its inputs.
+	ErrBadTxOutValue
+
+	// ErrDoubleSpend indicates an output is spent more than once.
+	ErrDoubleSpend
+
+	// ErrMissingInput indicates an input refers to an output which doesn't
+	// exist or has already been spent.
+	ErrMissingInput
+
+	// ErrBadSignature indicates an input signature is invalid.
+	ErrBadSignature
+
+	// ErrBadCoinbaseValue indicates the coinbase pays more than allowed.
+	ErrBadCoinbaseValue
+
+	// ErrImmatureSpend indicates an input spends a coinbase output before
+	// it has matured.
+	ErrImmatureSpend
+
+	// ErrUnfinalizedTx indicates a transaction's lock time hasn't passed.
+	ErrUnfinalizedTx
+
+	// ErrSequenceLocked indicates an input's relative lock time hasn't
+	// passed.
+	ErrSequenceLocked
+
+	// ErrBadDataOutput indicates an unspendable data output is malformed,
+	// carries a value or is one of several in a transaction.
+	ErrBadDataOutput
+)
+
+// RuleError describes a block or transaction that breaks a consensus rule.
+type RuleError struct {
+	Code        ErrorCode
+	Description string
+}
+
+// Error returns a description of the broken rule.
+func (e RuleError) Error() string {
+	return e.Description
+}
+
+// ruleError creates a RuleError with a formatted description.
+func ruleError(code ErrorCode, format string, a ...interface{}) RuleError {
+	return RuleError{code, fmt.Sprintf(format, a...)}
+}
+
+// ValidateBlock checks a block received from elsewhere before it is stored.
+// The block must link to a known block and have a valid header, proof-of-work
+// and merkle root, and its transactions must be well formed. Checks which
+// depend on the outputs the block spends are made when the block is connected
+// to the main chain.
+func (bc *Blockchain) ValidateBlock(block *Block) error {
+	if _, err := bc.GetBlock(block.Hash); err == nil {
+		return ruleError(ErrDuplicateBlock, "block %x is already known", block.Hash)
+	}
+
+	parent, err := bc.GetBlock(block.PrevBlockHash)
+	if err != nil {
+		return ruleError(ErrOrphanBlock, "previous block %x is not known", block.PrevBlockHash)
+	}
+
+	if block.Height != parent.Height+1 {
+		return ruleError(ErrBadHeight, "block height %d does not follow %d", block.Height, parent.Height)
+	}
+
+	if len(block.Transactions) == 0 {
+		return ruleError(ErrNoTransactions, "block has no transactions")
+	}
+
+	if !bytes.Equal(block.MerkleRoot, block.HashTransactions()) {
+		return ruleError(ErrBadMerkleRoot, "merkle root does not match transactions")
+	}
+
+	if !bytes.Equal(block.Hash, block.BlockHeader.Hash()) {
+		return ruleError(ErrBadHash, "block hash %x does not match header", block.Hash)
+	}
+
+	if !NewProof(block).Validate(bc) {
+		return ruleError(ErrBadProof, "block %x does not meet the required target", block.Hash)
+	}
+
+	if block.Timestamp.Before(bc.medianTimePast(&parent)) {
+		return ruleError(ErrTimeTooOld, "block timestamp %s is too old", block.Timestamp)
+	}
+
+	if block.Timestamp.After(time.Now().Add(maxTimeOffset)) {
+		return ruleError(ErrTimeTooNew, "block timestamp %s is too far in the future", block.Timestamp)
+	}
+
+	return checkTransactions(block)
+}
+
+// checkTransactions makes the checks on the transactions of a block which
+// don't need the outputs they spend.
+func checkTransactions(block *Block) error {
+	txIDs := make(map[string]bool)
+	spent := make(map[string]bool)
+
+	for i, tx := range block.Transactions {
+		if tx.IsCoinbase() != (i == 0) {
+			return ruleError(ErrBadCoinbase, "block must start with exactly one coinbase")
+		}
+
+		if err := checkTransaction(tx); err != nil {
+			return err
+		}
+
+		txID := hex.EncodeToString(tx.ID)
+		if txIDs[txID] {
+			return ruleError(ErrDuplicateTx, "transaction %x is in the block twice", tx.ID)
+		}
+		txIDs[txID] = true
+
+		if tx.IsCoinbase() {
+			continue
+		}
+
+		for _, vin := range tx.Vin {
+			outpoint := fmt.Sprintf("%x:%d", vin.Txid, vin.Vout)
+			if spent[outpoint] {
+				return ruleError(ErrDoubleSpend, "output %s is spent twice in the block", outpoint)
+			}
+			spent[outpoint] = true
+		}
+	}
+
+	return nil
+}
+
+// checkTransaction makes the checks on a transaction which need nothing but
+// the transaction itself. Its ID must match its contents, it must have inputs
+// and outputs, and every output must have a value except a single data output.
+// No output, nor the sum of them, may be more than the coins that can ever
+// exist.
+func checkTransaction(tx *Transaction) error {
+	if !bytes.Equal(tx.ID, tx.Hash()) {
+		return ruleError(ErrBadTxID, "transaction %x ID does not match contents", tx.ID)
+	}
+
+	if len(tx.Vin) == 0 {
+		return ruleError(ErrMissingInput, "transaction %x has no inputs", tx.ID)
+	}
+
+	if len(tx.Vout) == 0 {
+		return ruleError(ErrBadTxOutValue, "transaction %x has no outputs", tx.ID)
+	}
+
+	dataOutputs := 0
+	for _, out := range tx.Vout {
+		if !out.IsUnspendable() {
+			if out.Value <= 0 {
+				return ruleError(ErrBadTxOutValue, "transaction %x has an output of %d", tx.ID, out.Value)
+			}
+			continue
+		}
+
+		if _, ok := script.ExtractNullData(out.ScriptPubKey); !ok || out.Value != 0 {
+			return ruleError(ErrBadDataOutput, "transaction %x has a malformed data output", tx.ID)
+		}
+
+		dataOutputs++
+		if dataOutputs > 1 {
+			return ruleError(ErrBadDataOutput, "transaction %x has more than one data output", tx.ID)
+		}
+	}
+
+	_, err := outputValue(tx)
+
+	return err
+}
+
+// checkConnectBlock checks that a block can be connected to the tip of the
+// main chain. Every input must spend an output in the UTXO set, or one created
+// earlier in the block, with a valid signature. Coinbase outputs can only be
+// spent once they have matured. Transactions can't spend more
+// than their inputs and the coinbase can't pay more than the subsidy for the
+// height of the block plus the fees of the block. The lock times of the
+// transactions and their inputs must have passed.
+func (bc *Blockchain) checkConnectBlock(block *Block) error {
+	uTxOSet := UTxOSet{Blockchain: bc}
+	created := make(map[string]Transaction)
+	fees := 0
+
+	// Blocks we mine ourselves haven't been through ValidateBlock.
+	if err := checkTransactions(block); err != nil {
+		return err
+	}
+
+	parent, err := bc.GetBlock(block.PrevBlockHash)
+	if err != nil {
+		return ruleError(ErrOrphanBlock, "previous block %x is not known", block.PrevBlockHash)
+	}
+	medianTime := bc.medianTimePast(&parent)
+
+	for _, tx := range block.Transactions {
+		if !tx.IsFinal(block.Height, medianTime) {
+			return ruleError(ErrUnfinalizedTx, "transaction %x is locked until %d", tx.ID, tx.LockTime)
+		}
+
+		if tx.IsCoinbase() {
+			created[hex.EncodeToString(tx.ID)] = *tx
+			continue
+		}
+
+		var ok bool
+		inputValue := 0
+		prevTXs := make(map[string]Transaction)
+		var prevHeights []int
+
+		for _, vin := range tx.Vin {
+			txID := hex.EncodeToString(vin.Txid)
+
+			if prevTx, ok := created[txID]; ok {
+				if vin.Vout < 0 || vin.Vout >= len(prevTx.Vout) {
+					return ruleError(ErrMissingInput, "output %x:%d does not exist", vin.Txid, vin.Vout)
+				}
+
+				if !isMature(prevTx.IsCoinbase(), block.Height, block.Height) {
+					return ruleError(ErrImmatureSpend, "output %x:%d is an immature coinbase", vin.Txid, vin.Vout)
+				}
+
+				if inputValue, ok = addValue(inputValue, prevTx.Vout[vin.Vout].Value); !ok {
+					return ruleError(ErrBadTxOutValue, "transaction %x inputs are out of range", tx.ID)
+				}
+				prevTXs[txID] = prevTx
+				prevHeights = append(prevHeights, block.Height)
+				continue
+			}
+
+			outs, _ := uTxOSet.FindTxOutputs(vin.Txid)
+			out, ok := outs.Outputs[vin.Vout]
+			if !ok {
+				return ruleError(ErrMissingInput, "output %x:%d is spent or does not exist", vin.Txid, vin.Vout)
+			}
+			if inputValue, ok = addValue(inputValue, out.Value); !ok {
+				return ruleError(ErrBadTxOutValue, "transaction %x inputs are out of range", tx.ID)
+			}
+
+			if !outs.IsMature(block.Height) {
+				return ruleError(ErrImmatureSpend, "output %x:%d is an immature coinbase", vin.Txid, vin.Vout)
+			}
+			prevHeights = append(prevHeights, outs.Height)
+
+			prevTx, err := bc.FindTransaction(vin.Txid)
+			if err != nil {
+				return ruleError(ErrMissingInput, "transaction %x is not found", vin.Txid)
+			}
+			prevTXs[txID] = prevTx
+		}
+
+		if !tx.Verify(prevTXs) {
+			return ruleError(ErrBadSignature, "transaction %x has an invalid signature", tx.ID)
+		}
+
+		if err := bc.checkSequenceLocks(tx, prevHeights, block.Height, medianTime); err != nil {
+			return err
+		}
+
+		value, err := outputValue(tx)
+		if err != nil {
+			return err
+		}
+
+		if value > inputValue {
+			return ruleError(ErrBadTxOutValue, "transaction %x spends more than its inputs", tx.ID)
+		}
+
+		if fees, ok = addValue(fees, inputValue-value); !ok {
+			return ruleError(ErrBadTxOutValue, "fees of the block are out of range")
+		}
+
+		created[hex.EncodeToString(tx.ID)] = *tx
+	}
+
+	coinbaseValue, err := outputValue(block.Transactions[0])
+	if err != nil {
+		return err
+	}
+
+	subsidy := Emission.Subsidy(block.Height)
+	if coinbaseValue > subsidy+fees {
+		return ruleError(
+			ErrBadCoinbaseValue,
+			"coinbase pays more than the subsidy of %d plus fees of %d", subsidy, fees,
+		)
+	}
+
+	return nil
+}
+
+// medianTimePast returns the median timestamp of the block and the blocks
+// before it. New blocks can't have an earlier timestamp.
+func (bc *Blockchain) medianTimePast(block *Block) time.Time {
+	var timestamps []time.Time
+
+	for b := *block; ; {
+		timestamps = append(timestamps, b.Timestamp)
+
+		if len(timestamps) == medianTimeBlocks || len(b.PrevBlockHash) == 0 {
+			break
+		}
+
+		parent, err := bc.GetBlock(b.PrevBlockHash)
+		if err != nil {
+			break
+		}
+		b = parent
+	}
+
+	sort.Slice(timestamps, func(i, j int) bool {
+		return timestamps[i].Before(timestamps[j])
+	})
+
+	return timestamps[len(timestamps)/2]
+}
+
+// outputValue returns the sum of the outputs of a transaction. An error is
+// returned if an output or the sum is out of range.
+func outputValue(tx *Transaction) (int, error) {
+	value := 0
+
+	for _, out := range tx.Vout {
+		var ok bool
+		if value, ok = addValue(value, out.Value); !ok {
+			return 0, ruleError(ErrBadTxOutValue, "transaction %x outputs are out of range", tx.ID)
+		}
+	}
+
+	return value, nil
+}
+
+// addValue adds a value to a running total, reporting false if the value or
+// the new total is negative or more than the coins that can ever exist.
+// Keeping both below the supply cap means the sum can't overflow.
+func addValue(total, value int) (int, bool) {
+	maxSupply := Emission.MaxSupply()
+	if value < 0 || value > maxSupply || total+value > maxSupply {
+		return total, false
+	}
+
+	return total + value, true
+}
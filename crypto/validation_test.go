@@ -0,0 +1,170 @@
+package crypto
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/danmrichards/yagocoin/chaincfg"
+	"github.com/stretchr/testify/assert"
+)
+
+// testBlock mines a block with the given transactions on top of parent, after
+// letting change break it.
+func testBlock(t *testing.T, bc *Blockchain, parent *Block, transactions []*Transaction, change func(*Block)) *Block {
+	block := &Block{
+		BlockHeader: BlockHeader{
+			Version:       blockVersion,
+			PrevBlockHash: parent.Hash,
+			Timestamp:     time.Unix(time.Now().Unix(), 0),
+			Bits:          bc.requiredBits(parent),
+		},
+		Transactions: transactions,
+		Height:       parent.Height + 1,
+	}
+	block.MerkleRoot = block.HashTransactions()
+
+	if change != nil {
+		change(block)
+	}
+
+	header, hash, err := NewProof(block).Run(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block.BlockHeader = header
+	block.Hash = hash
+
+	return block
+}
+
+func TestRuleErrors(t *testing.T) {
+	SetNetwork(&chaincfg.RegressionNetParams)
+	t.Cleanup(func() { SetNetwork(&chaincfg.MainNetParams) })
+
+	a, b := NewWallet(), NewWallet()
+	bc := newTestChain(t, a)
+
+	genesis, err := bc.GetBlockByHeight(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prevOut := TxInput{genesis.Transactions[0].ID, 0, nil, SequenceFinal}
+
+	// spend returns a signed transaction spending the genesis coinbase with
+	// the given inputs, paying value to b.
+	spend := func(value int, vin ...TxInput) *Transaction {
+		tx := &Transaction{nil, vin, []TxOutput{*NewTxOutput(value, string(b.GetAddress()))}, 0}
+		bc.SignTransaction(tx, a.PrivateKey)
+		tx.ID = tx.Hash()
+
+		return tx
+	}
+	coinbase := func(fees int) *Transaction {
+		return NewCoinbaseTx(string(a.GetAddress()), "", 1, fees)
+	}
+
+	valid := testBlock(t, bc, &genesis, []*Transaction{coinbase(1), spend(9, prevOut)}, nil)
+	assert.NoError(t, bc.ValidateBlock(valid), "Valid block passes validation")
+	assert.NoError(t, bc.checkConnectBlock(valid), "Valid block can be connected")
+
+	tests := []struct {
+		name         string
+		transactions []*Transaction
+		change       func(*Block)
+		connect      bool // Whether the rule is checked when connecting.
+		code         ErrorCode
+	}{
+		{
+			"bad merkle root",
+			[]*Transaction{coinbase(0)},
+			func(b *Block) { b.MerkleRoot = make([]byte, 32) },
+			false,
+			ErrBadMerkleRoot,
+		},
+		{
+			"duplicate input",
+			[]*Transaction{coinbase(0), spend(9, prevOut, prevOut)},
+			nil,
+			false,
+			ErrDoubleSpend,
+		},
+		{
+			"overspend",
+			[]*Transaction{coinbase(0), spend(11, prevOut)},
+			nil,
+			true,
+			ErrBadTxOutValue,
+		},
+		{
+			"wrong coinbase amount",
+			[]*Transaction{coinbase(2), spend(9, prevOut)},
+			nil,
+			true,
+			ErrBadCoinbaseValue,
+		},
+		{
+			"bad proof of work",
+			[]*Transaction{coinbase(0)},
+			func(b *Block) { b.Bits = chaincfg.MainNetParams.PowLimitBits },
+			false,
+			ErrBadProof,
+		},
+		{
+			"timestamp before the median time",
+			[]*Transaction{coinbase(0)},
+			func(b *Block) { b.Timestamp = genesis.Timestamp.Add(-time.Second) },
+			false,
+			ErrTimeTooOld,
+		},
+	}
+
+	for _, test := range tests {
+		block := testBlock(t, bc, &genesis, test.transactions, test.change)
+
+		check := bc.ValidateBlock
+		if test.connect {
+			assert.NoError(t, bc.ValidateBlock(block), "%s passes validation", test.name)
+			check = bc.checkConnectBlock
+		}
+
+		err := check(block)
+		if assert.IsType(t, RuleError{}, err, test.name) {
+			assert.Equal(t, test.code, err.(RuleError).Code, test.name)
+		}
+	}
+}
+
+func TestOutputValueRange(t *testing.T) {
+	w := NewWallet()
+	maxInt := int(^uint(0) >> 1)
+	input := []TxInput{{[]byte("prev"), 0, nil, SequenceFinal}}
+
+	tests := []struct {
+		name   string
+		values []int
+	}{
+		{"output above the supply cap", []int{Emission.MaxSupply() + 1}},
+		{"outputs which wrap around", []int{maxInt, maxInt, 3}},
+		{"outputs adding up to more than the supply cap", []int{Emission.MaxSupply(), 1}},
+	}
+
+	for _, test := range tests {
+		var outputs []TxOutput
+		for _, value := range test.values {
+			outputs = append(outputs, *NewTxOutput(value, string(w.GetAddress())))
+		}
+
+		tx := &Transaction{nil, input, outputs, 0}
+		tx.ID = tx.Hash()
+
+		err := checkTransaction(tx)
+		if assert.IsType(t, RuleError{}, err, test.name) {
+			assert.Equal(t, ErrBadTxOutValue, err.(RuleError).Code, test.name)
+		}
+	}
+
+	tx := &Transaction{nil, input, []TxOutput{*NewTxOutput(Emission.MaxSupply(), string(w.GetAddress()))}, 0}
+	tx.ID = tx.Hash()
+	assert.NoError(t, checkTransaction(tx), "Output of the whole supply is valid")
+}
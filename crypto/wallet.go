@@ -9,14 +9,12 @@ import (
 	"log"
 
 	"github.com/danmrichards/yagocoin/base58"
+	"github.com/danmrichards/yagocoin/script"
 
 	"golang.org/x/crypto/ripemd160"
 )
 
-const (
-	version            = byte(0x00)
-	addressChecksumLen = 4
-)
+const addressChecksumLen = 4
 
 // Wallet stores private and public keys.
 type Wallet struct {
@@ -26,9 +24,25 @@ type Wallet struct {
 
 // GetAddress returns the wallet address.
 func (w Wallet) GetAddress() []byte {
-	pubKeyHash := HashPubKey(w.PublicKey)
+	return AddressFromPubKeyHash(HashPubKey(w.PublicKey))
+}
+
+// AddressFromPubKeyHash returns the base58 encoded address of a public key
+// hash.
+func AddressFromPubKeyHash(pubKeyHash []byte) []byte {
+	return encodeAddress(Net.AddressVersion, pubKeyHash)
+}
 
-	versionedPayload := append([]byte{version}, pubKeyHash...)
+// AddressFromScriptHash returns the base58 encoded address of a redeem script
+// hash.
+func AddressFromScriptHash(scriptHash []byte) []byte {
+	return encodeAddress(Net.ScriptHashAddressVersion, scriptHash)
+}
+
+// encodeAddress returns the base58 encoded address of a hash with the given
+// version.
+func encodeAddress(version byte, hash []byte) []byte {
+	versionedPayload := append([]byte{version}, hash...)
 	checksum := checksum(versionedPayload)
 
 	fullPayload := append(versionedPayload, checksum...)
@@ -56,6 +70,9 @@ func HashPubKey(pubKey []byte) []byte {
 func ValidateAddress(address string) bool {
 	// Decode the hash.
 	pubKeyHash := base58.Base58Decode([]byte(address))
+	if len(pubKeyHash) <= 1+addressChecksumLen {
+		return false
+	}
 
 	// Get the checksum and version.
 	actualChecksum := pubKeyHash[len(pubKeyHash)-addressChecksumLen:]
@@ -64,6 +81,11 @@ func ValidateAddress(address string) bool {
 	// Extract the public key hash from the address.
 	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-addressChecksumLen]
 
+	// Addresses of other networks are not valid on this one.
+	if version != Net.AddressVersion && version != Net.ScriptHashAddressVersion {
+		return false
+	}
+
 	// Create new checksum and compare.
 	targetChecksum := checksum(append([]byte{version}, pubKeyHash...))
 	return bytes.Compare(actualChecksum, targetChecksum) == 0
@@ -77,12 +99,26 @@ func NewWallet() *Wallet {
 	return &wallet
 }
 
-// GetPublicKeyHash returns the public key hash from a base58 encoded address.
+// GetPublicKeyHash returns the hash from a base58 encoded address. This is the
+// public key hash, or for a script hash address the redeem script hash.
 func GetPublicKeyHash(address []byte) []byte {
 	pubKeyHash := base58.Base58Decode(address)
 	return pubKeyHash[1 : len(pubKeyHash)-addressChecksumLen]
 }
 
+// AddressScript returns the public key script paying to a base58 encoded
+// address.
+func AddressScript(address []byte) []byte {
+	payload := base58.Base58Decode(address)
+	hash := payload[1 : len(payload)-addressChecksumLen]
+
+	if payload[0] == Net.ScriptHashAddressVersion {
+		return script.PayToScriptHash(hash)
+	}
+
+	return script.PayToPubKeyHash(hash)
+}
+
 // Generates and returns a SHA256 checksum for the given payload.
 // Hash will be of the length defined by addressChecksumLen.
 func checksum(payload []byte) []byte {
@@ -102,7 +138,7 @@ func newKeyPair() (ecdsa.PrivateKey, []byte) {
 
 	// In ecdsa public keys are on a curve hence the public key is a combination
 	// of the x and y co-ordinates.
-	pubKey := append(private.PublicKey.X.Bytes(), private.PublicKey.Y.Bytes()...)
+	pubKey := pubKeyBytes(&private.PublicKey)
 
 	return *private, pubKey
 }
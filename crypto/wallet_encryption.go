@@ -0,0 +1,276 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"log"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// The scrypt cost of new passphrases, with N as a power of two. Deriving a
+	// key takes 32MB of memory.
+	scryptLogN = 15
+	scryptR    = 8
+	scryptP    = 1
+
+	// The highest scrypt cost we'll read from a wallet file, so a corrupt one
+	// can't make us allocate gigabytes.
+	maxScryptLogN = 20
+	maxScryptRP   = 16
+
+	walletSaltLen = 16
+	walletKeyLen  = 32
+)
+
+var (
+	// ErrWalletLocked is returned when the private keys of an encrypted
+	// wallet file are needed before it is unlocked.
+	ErrWalletLocked = errors.New("wallet is locked")
+
+	// ErrWrongPassphrase is returned when unlocking with the wrong passphrase.
+	ErrWrongPassphrase = errors.New("wrong wallet passphrase")
+
+	// ErrWalletNotEncrypted is returned when unlocking a wallet file which
+	// isn't encrypted.
+	ErrWalletNotEncrypted = errors.New("wallet is not encrypted")
+
+	// ErrWalletEncrypted is returned when encrypting a wallet file again.
+	ErrWalletEncrypted = errors.New("wallet is already encrypted")
+
+	errEmptyPassphrase = errors.New("passphrase can't be empty")
+)
+
+// walletEncryption holds the encrypted private keys of a wallet file, along
+// with the key to them while it is unlocked.
+type walletEncryption struct {
+	salt   []byte
+	logN   uint64
+	r, p   uint64
+	header []byte
+	nonce  []byte
+	sealed []byte
+	key    []byte
+}
+
+// newWalletEncryption creates the encryption for a new passphrase, with a
+// fresh salt.
+func newWalletEncryption(passphrase []byte) (*walletEncryption, error) {
+	if len(passphrase) == 0 {
+		return nil, errEmptyPassphrase
+	}
+
+	salt := make([]byte, walletSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	enc := &walletEncryption{salt: salt, logN: scryptLogN, r: scryptR, p: scryptP}
+
+	key, err := enc.deriveKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	enc.key = key
+
+	return enc, nil
+}
+
+// deriveKey derives the encryption key from a passphrase.
+func (enc *walletEncryption) deriveKey(passphrase []byte) ([]byte, error) {
+	return scrypt.Key(passphrase, enc.salt, 1<<enc.logN, int(enc.r), int(enc.p), walletKeyLen)
+}
+
+// aead returns the cipher for a key.
+func (enc *walletEncryption) aead(key []byte) cipher.AEAD {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return gcm
+}
+
+// seal encrypts the private keys, authenticating the header of the wallet
+// file with them. The wallet file must be unlocked.
+func (enc *walletEncryption) seal(header, privateKeys []byte) {
+	gcm := enc.aead(enc.key)
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		log.Panic(err)
+	}
+
+	enc.header = header
+	enc.nonce = nonce
+	enc.sealed = gcm.Seal(nil, nonce, privateKeys, header)
+}
+
+// open decrypts the private keys with a key.
+func (enc *walletEncryption) open(key []byte) ([]byte, error) {
+	gcm := enc.aead(key)
+	if len(enc.nonce) != gcm.NonceSize() {
+		return nil, errors.New("invalid nonce")
+	}
+
+	privateKeys, err := gcm.Open(nil, enc.nonce, enc.sealed, enc.header)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+
+	return privateKeys, nil
+}
+
+// write writes the encrypted private keys.
+func (enc *walletEncryption) write(e *encoder) {
+	e.bytes(enc.salt)
+	e.uvarint(enc.logN)
+	e.uvarint(enc.r)
+	e.uvarint(enc.p)
+	e.bytes(enc.nonce)
+	e.bytes(enc.sealed)
+}
+
+// readWalletEncryption reads the encrypted private keys following header.
+func readWalletEncryption(d *decoder, header []byte) *walletEncryption {
+	enc := &walletEncryption{header: header}
+
+	enc.salt = d.bytes()
+	enc.logN = d.uvarint()
+	enc.r = d.uvarint()
+	enc.p = d.uvarint()
+	enc.nonce = d.bytes()
+	enc.sealed = d.bytes()
+
+	if enc.logN < 1 || enc.logN > maxScryptLogN || enc.r < 1 || enc.r > maxScryptRP || enc.p < 1 || enc.p > maxScryptRP {
+		d.fail(errors.New("invalid scrypt parameters"))
+	}
+
+	return enc
+}
+
+// IsEncrypted reports whether the private keys are encrypted with a
+// passphrase.
+func (ws *Wallets) IsEncrypted() bool {
+	return ws.encryption != nil
+}
+
+// IsLocked reports whether the private keys are encrypted and haven't been
+// unlocked.
+func (ws *Wallets) IsLocked() bool {
+	return ws.encryption != nil && ws.encryption.key == nil
+}
+
+// Encrypt encrypts the private keys with a passphrase. They stay unlocked
+// until Lock is called. Save the wallets to replace the unencrypted file.
+func (ws *Wallets) Encrypt(passphrase []byte) error {
+	if ws.encryption != nil {
+		return ErrWalletEncrypted
+	}
+
+	enc, err := newWalletEncryption(passphrase)
+	if err != nil {
+		return err
+	}
+
+	ws.encryption = enc
+	ws.sealKeys()
+
+	return nil
+}
+
+// Unlock decrypts the private keys with the passphrase.
+func (ws *Wallets) Unlock(passphrase []byte) error {
+	if ws.encryption == nil {
+		return ErrWalletNotEncrypted
+	}
+
+	key, err := ws.encryption.deriveKey(passphrase)
+	if err != nil {
+		return err
+	}
+
+	// Already unlocked, so there's nothing to decrypt.
+	if ws.encryption.key != nil {
+		if subtle.ConstantTimeCompare(key, ws.encryption.key) != 1 {
+			return ErrWrongPassphrase
+		}
+
+		return nil
+	}
+
+	privateKeys, err := ws.encryption.open(key)
+	if err != nil {
+		return err
+	}
+
+	err = ws.setPrivateKeys(privateKeys)
+	if err != nil {
+		return err
+	}
+	ws.encryption.key = key
+
+	return nil
+}
+
+// Lock clears the private keys of an encrypted wallet file from memory, until
+// it is unlocked again.
+func (ws *Wallets) Lock() {
+	if ws.encryption == nil {
+		return
+	}
+
+	for _, wallet := range ws.Wallets {
+		if wallet.PrivateKey.D != nil {
+			words := wallet.PrivateKey.D.Bits()
+			for i := range words {
+				words[i] = 0
+			}
+		}
+		wallet.PrivateKey = ecdsa.PrivateKey{}
+	}
+
+	if ws.hd != nil {
+		ws.hd.mnemonic = ""
+	}
+
+	for i := range ws.encryption.key {
+		ws.encryption.key[i] = 0
+	}
+	ws.encryption.key = nil
+}
+
+// ChangePassphrase encrypts the private keys with a new passphrase, once
+// unlocked with the old one.
+func (ws *Wallets) ChangePassphrase(oldPassphrase, newPassphrase []byte) error {
+	err := ws.Unlock(oldPassphrase)
+	if err != nil {
+		return err
+	}
+
+	enc, err := newWalletEncryption(newPassphrase)
+	if err != nil {
+		return err
+	}
+
+	ws.encryption = enc
+	ws.sealKeys()
+
+	return nil
+}
+
+// sealKeys encrypts the private keys after they change. The wallet file must
+// be unlocked.
+func (ws *Wallets) sealKeys() {
+	ws.encryption.seal(ws.header(), ws.privateKeys())
+}
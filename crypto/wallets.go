@@ -2,19 +2,69 @@ package crypto
 
 import (
 	"bytes"
+	"crypto/ecdsa"
 	"crypto/elliptic"
 	"encoding/gob"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math/big"
 	"os"
+	"sort"
 )
 
-const walletFile = "wallet_%s.dat"
+// The wallet file holds the keys of every wallet, written with the building
+// blocks of the canonical encoding as:
+//
+//	magic    "yagowallet"
+//	uvarint  format version, currently 2
+//	uvarint  number of wallets, followed by the PublicKey of each as bytes,
+//	         in order of their addresses
+//	uvarint  1 if the keys are derived from a mnemonic, 0 otherwise
+//	uvarint  if derived, the next index of the receive chain
+//	uvarint  if derived, the next index of the change chain
+//	uvarint  1 if the private keys are encrypted, 0 otherwise
+//
+// followed by the private keys, each as bytes holding the big endian private
+// scalar, in the same order as the public keys, and then the mnemonic as
+// bytes if the keys are derived from one. Encrypted private keys and mnemonic
+// are instead sealed with AES-256-GCM, under a key derived from the passphrase
+// with scrypt, and written as:
+//
+//	bytes    scrypt salt
+//	uvarint  scrypt N, as a power of two
+//	uvarint  scrypt r
+//	uvarint  scrypt p
+//	bytes    GCM nonce
+//	bytes    sealed private keys
+//
+// Everything before the private keys is authenticated along with them, so the
+// addresses of a locked wallet file can be listed but not swapped for others.
+//
+// Version 1 wallet files, written before keys could be derived from a
+// mnemonic, have no mnemonic flag or chain indexes.
+//
+// Wallet files written before this format are gob encoded. They are still
+// read, and written in this format the next time they are saved.
 
-// Wallets stores a collection of wallets
+const (
+	walletMagic = "yagowallet"
+
+	// The current version of the wallet file format.
+	walletEncodingVersion = 2
+)
+
+// Wallets stores a collection of wallets. The private keys of an encrypted
+// wallet file are only held while it is unlocked.
 type Wallets struct {
 	Wallets map[string]*Wallet
+
+	// Set if new keys are derived from a mnemonic.
+	hd *hdState
+
+	// Set if the private keys are encrypted with a passphrase.
+	encryption *walletEncryption
 }
 
 // NewWallets creates Wallets and fills it from a file if it exists.
@@ -27,14 +77,27 @@ func NewWallets(nodeID string) (*Wallets, error) {
 	return &wallets, err
 }
 
-// CreateWallet adds a Wallet to Wallets.
-func (ws *Wallets) CreateWallet() string {
+// CreateWallet adds a Wallet to Wallets, the next of the receive chain if the
+// wallet file has a mnemonic. An encrypted wallet file must be unlocked first.
+func (ws *Wallets) CreateWallet() (string, error) {
+	if ws.IsLocked() {
+		return "", ErrWalletLocked
+	}
+
+	if ws.hd != nil {
+		return ws.deriveWallet(ReceiveChain)
+	}
+
 	wallet := NewWallet()
 	address := fmt.Sprintf("%s", wallet.GetAddress())
 
 	ws.Wallets[address] = wallet
 
-	return address
+	if ws.encryption != nil {
+		ws.sealKeys()
+	}
+
+	return address, nil
 }
 
 // GetAddresses returns an array of addresses stored in the wallet file.
@@ -48,14 +111,24 @@ func (ws *Wallets) GetAddresses() []string {
 	return addresses
 }
 
-// GetWallet returns a Wallet by its address.
-func (ws Wallets) GetWallet(address string) Wallet {
-	return *ws.Wallets[address]
+// GetWallet returns a Wallet by its address. An encrypted wallet file must be
+// unlocked first, so the wallet can sign.
+func (ws Wallets) GetWallet(address string) (Wallet, error) {
+	wallet, ok := ws.Wallets[address]
+	if !ok {
+		return Wallet{}, fmt.Errorf("address '%s' is not in the wallet file", address)
+	}
+
+	if ws.IsLocked() {
+		return Wallet{}, ErrWalletLocked
+	}
+
+	return *wallet, nil
 }
 
 // LoadFromFile loads wallets from the file.
 func (ws *Wallets) LoadFromFile(nodeID string) error {
-	walletFile := fmt.Sprintf(walletFile, nodeID)
+	walletFile := fmt.Sprintf(Net.WalletFile, nodeID)
 	if _, err := os.Stat(walletFile); os.IsNotExist(err) {
 		return err
 	}
@@ -65,35 +138,269 @@ func (ws *Wallets) LoadFromFile(nodeID string) error {
 		log.Panic(err)
 	}
 
-	// Decode the Wallet data in the file.
-	var wallets Wallets
-	gob.Register(elliptic.P256())
-	decoder := gob.NewDecoder(bytes.NewReader(fileContent))
-	err = decoder.Decode(&wallets)
+	if bytes.HasPrefix(fileContent, []byte(walletMagic)) {
+		err = ws.deserialize(fileContent)
+	} else {
+		err = ws.deserializeLegacy(fileContent)
+	}
 	if err != nil {
-		log.Panic(err)
+		return fmt.Errorf("could not read wallet file %s: %s", walletFile, err)
 	}
 
-	ws.Wallets = wallets.Wallets
-
 	return nil
 }
 
-// SaveToFile saves wallets to a file
-func (ws Wallets) SaveToFile(nodeID string) {
-	var content bytes.Buffer
-	walletFile := fmt.Sprintf(walletFile, nodeID)
+// SaveToFile saves wallets to a file, which only the user can read. The file
+// is replaced in one step, so a crash can't leave it half written.
+func (ws *Wallets) SaveToFile(nodeID string) {
+	walletFile := fmt.Sprintf(Net.WalletFile, nodeID)
+	tmpFile := walletFile + ".tmp"
 
-	gob.Register(elliptic.P256())
+	// Make sure the file is created with our permissions.
+	os.Remove(tmpFile)
 
-	encoder := gob.NewEncoder(&content)
-	err := encoder.Encode(ws)
+	err := ioutil.WriteFile(tmpFile, ws.serialize(), 0600)
 	if err != nil {
 		log.Panic(err)
 	}
 
-	err = ioutil.WriteFile(walletFile, content.Bytes(), 0644)
+	err = os.Rename(tmpFile, walletFile)
 	if err != nil {
 		log.Panic(err)
 	}
 }
+
+// addresses returns the addresses of the wallets in the order they are
+// written to the wallet file.
+func (ws *Wallets) addresses() []string {
+	addresses := ws.GetAddresses()
+	sort.Strings(addresses)
+
+	return addresses
+}
+
+// header encodes the wallet file up to the private keys.
+func (ws *Wallets) header() []byte {
+	var e encoder
+
+	e.WriteString(walletMagic)
+	e.uvarint(walletEncodingVersion)
+
+	addresses := ws.addresses()
+	e.uvarint(uint64(len(addresses)))
+	for _, address := range addresses {
+		e.bytes(ws.Wallets[address].PublicKey)
+	}
+
+	e.bool(ws.hd != nil)
+	if ws.hd != nil {
+		e.uvarint(uint64(ws.hd.next[ReceiveChain]))
+		e.uvarint(uint64(ws.hd.next[ChangeChain]))
+	}
+
+	e.bool(ws.encryption != nil)
+
+	return e.Bytes()
+}
+
+// privateKeys encodes the private keys, in the order of the header, followed
+// by the mnemonic if there is one.
+func (ws *Wallets) privateKeys() []byte {
+	var e encoder
+
+	for _, address := range ws.addresses() {
+		e.bytes(privateKeyBytes(&ws.Wallets[address].PrivateKey))
+	}
+
+	if ws.hd != nil {
+		e.bytes([]byte(ws.hd.mnemonic))
+	}
+
+	return e.Bytes()
+}
+
+// setPrivateKeys decodes private keys encoded by privateKeys, checking each
+// matches its public key.
+func (ws *Wallets) setPrivateKeys(data []byte) error {
+	d := decoder{data: data}
+	keys := make([]ecdsa.PrivateKey, 0, len(ws.Wallets))
+
+	for _, address := range ws.addresses() {
+		key, err := privateKeyFromBytes(d.bytes(), ws.Wallets[address].PublicKey)
+		if d.err == nil && err != nil {
+			d.fail(fmt.Errorf("wallet %s: %s", address, err))
+		}
+		keys = append(keys, key)
+	}
+
+	var mnemonic string
+	if ws.hd != nil {
+		mnemonic = string(d.bytes())
+	}
+
+	if err := d.finish(); err != nil {
+		return err
+	}
+
+	for i, address := range ws.addresses() {
+		ws.Wallets[address].PrivateKey = keys[i]
+	}
+
+	if ws.hd != nil {
+		ws.hd.mnemonic = mnemonic
+	}
+
+	return nil
+}
+
+// serialize encodes the wallets in the wallet file format.
+func (ws *Wallets) serialize() []byte {
+	var e encoder
+
+	// The sealed private keys are only valid with the header they were
+	// sealed with.
+	if ws.encryption == nil {
+		e.Write(ws.header())
+		e.Write(ws.privateKeys())
+	} else {
+		e.Write(ws.encryption.header)
+		ws.encryption.write(&e)
+	}
+
+	return e.Bytes()
+}
+
+// deserialize decodes wallets from the wallet file format.
+func (ws *Wallets) deserialize(data []byte) error {
+	d := decoder{data: data[len(walletMagic):]}
+	wallets := make(map[string]*Wallet)
+
+	version := d.version(walletEncodingVersion)
+
+	var last string
+	for i, n := 0, d.count(); i < n; i++ {
+		wallet := &Wallet{PublicKey: d.bytes()}
+		address := string(wallet.GetAddress())
+
+		// Wallets are written in order of their addresses.
+		if i > 0 && address <= last {
+			d.fail(errNonCanonical)
+		}
+		wallets[address] = wallet
+		last = address
+	}
+
+	var hd *hdState
+	if version >= 2 && d.bool() {
+		hd = &hdState{}
+		hd.next[ReceiveChain] = d.uint32v()
+		hd.next[ChangeChain] = d.uint32v()
+	}
+
+	encrypted := d.bool()
+	if d.err != nil {
+		return d.err
+	}
+
+	ws.Wallets = wallets
+	ws.hd = hd
+	ws.encryption = nil
+
+	if !encrypted {
+		return ws.setPrivateKeys(d.data)
+	}
+
+	header := data[:len(data)-len(d.data)]
+	ws.encryption = readWalletEncryption(&d, header)
+
+	return d.finish()
+}
+
+// legacyWallets mirrors the gob encoding of Wallets in wallet files written
+// before the current format.
+type legacyWallets struct {
+	Wallets map[string]*legacyWallet
+}
+
+// legacyWallet mirrors the gob encoding of a Wallet in legacy wallet files.
+type legacyWallet struct {
+	PrivateKey legacyPrivateKey
+	PublicKey  []byte
+}
+
+// legacyPrivateKey mirrors the gob encoding of an ecdsa.PrivateKey.
+type legacyPrivateKey struct {
+	PublicKey struct {
+		Curve interface{}
+		X, Y  *big.Int
+	}
+	D *big.Int
+}
+
+// legacyCurve stands in for the P-256 curve, whose type gob recorded in legacy
+// wallet files. Its fields are ignored.
+type legacyCurve struct {
+	CurveParams *elliptic.CurveParams
+}
+
+func init() {
+	gob.RegisterName("crypto/elliptic.p256Curve", legacyCurve{})
+}
+
+// deserializeLegacy decodes wallets from a gob encoded wallet file.
+func (ws *Wallets) deserializeLegacy(data []byte) error {
+	var legacy legacyWallets
+
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&legacy)
+	if err != nil {
+		return err
+	}
+
+	wallets := make(map[string]*Wallet)
+	for address, w := range legacy.Wallets {
+		if w.PrivateKey.D == nil {
+			return fmt.Errorf("wallet %s has no private key", address)
+		}
+
+		key, err := privateKeyFromBytes(w.PrivateKey.D.Bytes(), w.PublicKey)
+		if err != nil {
+			return fmt.Errorf("wallet %s: %s", address, err)
+		}
+		wallets[address] = &Wallet{key, w.PublicKey}
+	}
+
+	ws.Wallets = wallets
+	ws.hd = nil
+	ws.encryption = nil
+
+	return nil
+}
+
+// privateKeyBytes returns the big endian private scalar of a key, padded to
+// the size of the curve.
+func privateKeyBytes(key *ecdsa.PrivateKey) []byte {
+	return paddedBytes(key.D, (key.Curve.Params().BitSize+7)/8)
+}
+
+// privateKeyFromScalar returns the P-256 private key with the given scalar.
+func privateKeyFromScalar(d []byte) ecdsa.PrivateKey {
+	curve := elliptic.P256()
+
+	key := ecdsa.PrivateKey{D: new(big.Int).SetBytes(d)}
+	key.PublicKey.Curve = curve
+	key.PublicKey.X, key.PublicKey.Y = curve.ScalarBaseMult(d)
+
+	return key
+}
+
+// privateKeyFromBytes returns the P-256 private key with the given scalar,
+// which must be the key of pubKey.
+func privateKeyFromBytes(d, pubKey []byte) (ecdsa.PrivateKey, error) {
+	key := privateKeyFromScalar(d)
+
+	if key.D.Sign() == 0 || !bytes.Equal(pubKeyBytes(&key.PublicKey), pubKey) {
+		return ecdsa.PrivateKey{}, errors.New("private key doesn't match the public key")
+	}
+
+	return key, nil
+}
@@ -0,0 +1,133 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// inTempDir runs the rest of a test in a temporary directory, where wallet
+// files can be written.
+func inTempDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yagocoin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Chdir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		os.Chdir(wd)
+		os.RemoveAll(dir)
+	})
+}
+
+func TestWalletFile(t *testing.T) {
+	inTempDir(t)
+
+	ws, err := NewWallets("test")
+	assert.True(t, os.IsNotExist(err), "Wallet file doesn't exist yet")
+
+	address, err := ws.CreateWallet()
+	assert.NoError(t, err, "Wallet is created")
+	ws.SaveToFile("test")
+
+	info, err := os.Stat(fmt.Sprintf(Net.WalletFile, "test"))
+	assert.NoError(t, err, "Wallet file is written")
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "Only the user can read the wallet file")
+
+	loaded, err := NewWallets("test")
+	assert.NoError(t, err, "Wallet file is read")
+	assert.False(t, loaded.IsEncrypted(), "Wallet file isn't encrypted")
+
+	wallet, err := loaded.GetWallet(address)
+	assert.NoError(t, err, "Wallet can sign")
+	assert.Equal(t, *ws.Wallets[address], wallet, "Keys are kept")
+}
+
+func TestWalletEncryption(t *testing.T) {
+	inTempDir(t)
+
+	ws, _ := NewWallets("test")
+	address, _ := ws.CreateWallet()
+	privateKey := privateKeyBytes(&ws.Wallets[address].PrivateKey)
+
+	assert.Equal(t, ErrWalletNotEncrypted, ws.Unlock([]byte("secret")), "Unencrypted wallet file can't be unlocked")
+	assert.NoError(t, ws.Encrypt([]byte("secret")), "Wallet file is encrypted")
+	assert.Equal(t, ErrWalletEncrypted, ws.Encrypt([]byte("secret")), "Wallet file can't be encrypted twice")
+	ws.SaveToFile("test")
+
+	data, _ := ioutil.ReadFile(fmt.Sprintf(Net.WalletFile, "test"))
+	assert.False(t, bytes.Contains(data, privateKey), "Private key isn't written in plaintext")
+
+	ws, err := NewWallets("test")
+	assert.NoError(t, err, "Encrypted wallet file is read")
+	assert.True(t, ws.IsLocked(), "Wallet file is locked")
+	assert.Equal(t, []string{address}, ws.GetAddresses(), "Addresses are listed while locked")
+
+	_, err = ws.GetWallet(address)
+	assert.Equal(t, ErrWalletLocked, err, "Locked wallet can't sign")
+	_, err = ws.CreateWallet()
+	assert.Equal(t, ErrWalletLocked, err, "Wallet can't be added while locked")
+
+	assert.Equal(t, ErrWrongPassphrase, ws.Unlock([]byte("guess")), "Wrong passphrase is rejected")
+	assert.NoError(t, ws.Unlock([]byte("secret")), "Wallet file is unlocked")
+
+	wallet, err := ws.GetWallet(address)
+	assert.NoError(t, err, "Unlocked wallet can sign")
+	assert.Equal(t, privateKey, privateKeyBytes(&wallet.PrivateKey), "Private key is decrypted")
+
+	other, err := ws.CreateWallet()
+	assert.NoError(t, err, "Wallet is added while unlocked")
+	assert.NoError(t, ws.ChangePassphrase([]byte("secret"), []byte("new secret")), "Passphrase is changed")
+	ws.SaveToFile("test")
+
+	ws.Lock()
+	assert.True(t, ws.IsLocked(), "Wallet file is locked again")
+	assert.Nil(t, ws.Wallets[address].PrivateKey.D, "Private key is cleared")
+
+	ws, _ = NewWallets("test")
+	assert.Equal(t, ErrWrongPassphrase, ws.Unlock([]byte("secret")), "Old passphrase is rejected")
+	assert.NoError(t, ws.Unlock([]byte("new secret")), "New passphrase unlocks")
+	assert.Len(t, ws.Wallets, 2, "Added wallet is kept")
+	assert.NotNil(t, ws.Wallets[other].PrivateKey.D, "Added wallet is decrypted")
+}
+
+func TestLegacyWalletFile(t *testing.T) {
+	// A wallet file written by the original gob encoded format, holding a
+	// single key.
+	const (
+		address    = "1PS7rFPTRJPAzBJBbuZp521Wc8PfRh1gda"
+		privateKey = "e26ff470d2e9266de79c5c0350c21bba61f20c956c92170e74a35b37edc42e44"
+	)
+
+	content, err := ioutil.ReadFile("testdata/wallet_legacy.dat")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inTempDir(t)
+	ioutil.WriteFile(fmt.Sprintf(Net.WalletFile, "test"), content, 0644)
+
+	ws, err := NewWallets("test")
+	assert.NoError(t, err, "Legacy wallet file is read")
+	assert.Equal(t, []string{address}, ws.GetAddresses(), "Addresses are kept")
+
+	loaded, err := ws.GetWallet(address)
+	assert.NoError(t, err, "Wallet can sign")
+	assert.Equal(t, privateKey, hex.EncodeToString(privateKeyBytes(&loaded.PrivateKey)), "Private key is kept")
+	assert.Equal(t, address, string(loaded.GetAddress()), "Public key is kept")
+}
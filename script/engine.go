@@ -0,0 +1,431 @@
+package script
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+
+	"golang.org/x/crypto/ripemd160"
+)
+
+const (
+	// The most values the stack may hold.
+	maxStackSize = 1000
+
+	// The most opcodes other than pushes a script may execute.
+	maxOps = 201
+
+	// The most public keys a multisig check may use.
+	MaxMultiSigKeys = 16
+)
+
+// Errors returned when a script fails.
+var (
+	ErrEvalFalse        = errors.New("script evaluated to false")
+	ErrVerify           = errors.New("verify failed")
+	ErrEarlyReturn      = errors.New("script returned early")
+	ErrStackUnderflow   = errors.New("not enough values on the stack")
+	ErrStackOverflow    = errors.New("too many values on the stack")
+	ErrTooManyOps       = errors.New("too many operations")
+	ErrUnbalancedIf     = errors.New("unbalanced conditional")
+	ErrSigScriptNotPush = errors.New("signature script is not push only")
+)
+
+// SigChecker checks signatures against the transaction spending an output.
+type SigChecker interface {
+	// CheckSig reports whether sig is a valid signature of the spending
+	// transaction by pubKey.
+	CheckSig(sig, pubKey []byte) bool
+}
+
+// Execute runs the signature script of an input followed by the public key
+// script of the output it spends. The output can be spent if both run without
+// error and leave a true value on top of the stack. Signature scripts may
+// only push values, so they can't change what the public key script does.
+//
+// If the public key script pays to a script hash, the last value pushed by
+// the signature script is the redeem script. Once it is shown to match the
+// hash, it is run against the rest of the values and must succeed as well.
+func Execute(sigScript, pubKeyScript []byte, checker SigChecker) error {
+	if !IsPushOnly(sigScript) {
+		return ErrSigScriptNotPush
+	}
+
+	e := &engine{checker: checker}
+
+	if err := e.run(sigScript); err != nil {
+		return err
+	}
+	sigStack := append([][]byte{}, e.stack...)
+
+	if err := e.run(pubKeyScript); err != nil {
+		return err
+	}
+	if err := e.checkTrue(); err != nil {
+		return err
+	}
+
+	if Classify(pubKeyScript) != ScriptHash {
+		return nil
+	}
+
+	// The redeem script matched the hash, so there is at least one value.
+	e.stack = sigStack[:len(sigStack)-1]
+	if err := e.run(sigStack[len(sigStack)-1]); err != nil {
+		return err
+	}
+
+	return e.checkTrue()
+}
+
+// checkTrue returns an error unless the value on top of the stack is true.
+func (e *engine) checkTrue() error {
+	if len(e.stack) == 0 || !asBool(e.stack[len(e.stack)-1]) {
+		return ErrEvalFalse
+	}
+
+	return nil
+}
+
+// engine holds the state of a running script.
+type engine struct {
+	checker SigChecker
+	stack   [][]byte
+
+	// Whether each enclosing conditional branch is being executed.
+	conds []bool
+
+	ops int
+}
+
+// executing reports whether every enclosing conditional branch is being
+// executed.
+func (e *engine) executing() bool {
+	for _, c := range e.conds {
+		if !c {
+			return false
+		}
+	}
+
+	return true
+}
+
+// run executes a script against the current stack.
+func (e *engine) run(script []byte) error {
+	if len(script) > MaxScriptSize {
+		return fmt.Errorf("script of %d bytes is larger than %d", len(script), MaxScriptSize)
+	}
+
+	instructions, err := parse(script)
+	if err != nil {
+		return err
+	}
+
+	e.conds = nil
+	for _, in := range instructions {
+		if len(in.data) > MaxPushSize {
+			return fmt.Errorf("push of %d bytes is larger than %d", len(in.data), MaxPushSize)
+		}
+
+		if !in.isPush() {
+			e.ops++
+			if e.ops > maxOps {
+				return ErrTooManyOps
+			}
+		}
+
+		if err := e.step(in); err != nil {
+			return err
+		}
+
+		if len(e.stack) > maxStackSize {
+			return ErrStackOverflow
+		}
+	}
+
+	if len(e.conds) != 0 {
+		return ErrUnbalancedIf
+	}
+
+	return nil
+}
+
+// step executes a single instruction.
+func (e *engine) step(in instruction) error {
+	// Conditionals are tracked even within branches which aren't executed.
+	switch in.op {
+	case OpIf, OpNotIf:
+		cond := false
+		if e.executing() {
+			v, err := e.pop()
+			if err != nil {
+				return err
+			}
+			cond = asBool(v) == (in.op == OpIf)
+		}
+		e.conds = append(e.conds, cond)
+
+		return nil
+	case OpElse:
+		if len(e.conds) == 0 {
+			return ErrUnbalancedIf
+		}
+		e.conds[len(e.conds)-1] = !e.conds[len(e.conds)-1]
+
+		return nil
+	case OpEndIf:
+		if len(e.conds) == 0 {
+			return ErrUnbalancedIf
+		}
+		e.conds = e.conds[:len(e.conds)-1]
+
+		return nil
+	}
+
+	if !e.executing() {
+		return nil
+	}
+
+	if in.isPush() {
+		e.push(pushValue(in))
+		return nil
+	}
+
+	switch in.op {
+	case OpNop:
+	case OpVerify:
+		v, err := e.pop()
+		if err != nil {
+			return err
+		}
+		if !asBool(v) {
+			return ErrVerify
+		}
+	case OpReturn:
+		return ErrEarlyReturn
+	case OpDrop:
+		_, err := e.pop()
+		return err
+	case OpDup:
+		v, err := e.peek(0)
+		if err != nil {
+			return err
+		}
+		e.push(v)
+	case OpSwap:
+		a, err := e.pop()
+		if err != nil {
+			return err
+		}
+		b, err := e.pop()
+		if err != nil {
+			return err
+		}
+		e.push(a)
+		e.push(b)
+	case OpEqual, OpEqualVerify:
+		a, err := e.pop()
+		if err != nil {
+			return err
+		}
+		b, err := e.pop()
+		if err != nil {
+			return err
+		}
+
+		equal := bytes.Equal(a, b)
+		if in.op == OpEqualVerify {
+			if !equal {
+				return ErrVerify
+			}
+			return nil
+		}
+		e.push(fromBool(equal))
+	case OpSHA256:
+		v, err := e.pop()
+		if err != nil {
+			return err
+		}
+		hash := sha256.Sum256(v)
+		e.push(hash[:])
+	case OpHash160:
+		v, err := e.pop()
+		if err != nil {
+			return err
+		}
+		e.push(Hash160(v))
+	case OpCheckSig, OpCheckSigVerify:
+		pubKey, err := e.pop()
+		if err != nil {
+			return err
+		}
+		sig, err := e.pop()
+		if err != nil {
+			return err
+		}
+
+		valid := e.checker.CheckSig(sig, pubKey)
+		if in.op == OpCheckSigVerify {
+			if !valid {
+				return ErrVerify
+			}
+			return nil
+		}
+		e.push(fromBool(valid))
+	case OpCheckMultiSig, OpCheckMultiSigVerify:
+		valid, err := e.checkMultiSig()
+		if err != nil {
+			return err
+		}
+
+		if in.op == OpCheckMultiSigVerify {
+			if !valid {
+				return ErrVerify
+			}
+			return nil
+		}
+		e.push(fromBool(valid))
+	default:
+		return fmt.Errorf("unknown opcode %s", opcodeName(in.op))
+	}
+
+	return nil
+}
+
+// checkMultiSig pops the number of public keys, the keys, the number of
+// signatures needed and the signatures from the stack, and reports whether
+// the signatures are valid. Signatures must be in the same order as the keys
+// they belong to.
+func (e *engine) checkMultiSig() (bool, error) {
+	n, err := e.popInt()
+	if err != nil {
+		return false, err
+	}
+	if n > MaxMultiSigKeys {
+		return false, fmt.Errorf("multisig of %d keys is more than %d", n, MaxMultiSigKeys)
+	}
+
+	// Each key is checked as an operation of its own.
+	e.ops += n
+	if e.ops > maxOps {
+		return false, ErrTooManyOps
+	}
+
+	pubKeys := make([][]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		if pubKeys[i], err = e.pop(); err != nil {
+			return false, err
+		}
+	}
+
+	m, err := e.popInt()
+	if err != nil {
+		return false, err
+	}
+	if m > n {
+		return false, fmt.Errorf("multisig needs %d signatures from %d keys", m, n)
+	}
+
+	sigs := make([][]byte, m)
+	for i := m - 1; i >= 0; i-- {
+		if sigs[i], err = e.pop(); err != nil {
+			return false, err
+		}
+	}
+
+	// Match each signature to the next key which made it. A signature can't
+	// be matched if there are fewer keys left than signatures.
+	for len(sigs) > 0 {
+		if len(sigs) > len(pubKeys) {
+			return false, nil
+		}
+
+		if e.checker.CheckSig(sigs[0], pubKeys[0]) {
+			sigs = sigs[1:]
+		}
+		pubKeys = pubKeys[1:]
+	}
+
+	return true, nil
+}
+
+// popInt removes a small number, as pushed by Op0 to Op16, from the top of
+// the stack.
+func (e *engine) popInt() (int, error) {
+	v, err := e.pop()
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case len(v) == 0:
+		return 0, nil
+	case len(v) == 1 && v[0] <= 16:
+		return int(v[0]), nil
+	default:
+		return 0, fmt.Errorf("expected a number from 0 to 16, found %x", v)
+	}
+}
+
+// push puts a value on top of the stack.
+func (e *engine) push(v []byte) {
+	e.stack = append(e.stack, v)
+}
+
+// pop removes and returns the value on top of the stack.
+func (e *engine) pop() ([]byte, error) {
+	v, err := e.peek(0)
+	if err != nil {
+		return nil, err
+	}
+	e.stack = e.stack[:len(e.stack)-1]
+
+	return v, nil
+}
+
+// peek returns the value n places below the top of the stack.
+func (e *engine) peek(n int) ([]byte, error) {
+	if n >= len(e.stack) {
+		return nil, ErrStackUnderflow
+	}
+
+	return e.stack[len(e.stack)-1-n], nil
+}
+
+// asBool interprets a stack value as a boolean. Empty values and values of
+// only zero bytes, or of zero bytes followed by the sign bit (negative zero),
+// are false.
+func asBool(v []byte) bool {
+	for i, b := range v {
+		if b != 0 && !(i == len(v)-1 && b == 0x80) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fromBool returns the stack value of a boolean.
+func fromBool(b bool) []byte {
+	if b {
+		return []byte{1}
+	}
+
+	return nil
+}
+
+// Hash160 returns the RIPEMD-160 hash of the SHA-256 hash of data, which is
+// how public keys are hashed.
+func Hash160(data []byte) []byte {
+	sha := sha256.Sum256(data)
+
+	hasher := ripemd160.New()
+	_, err := hasher.Write(sha[:])
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return hasher.Sum(nil)
+}
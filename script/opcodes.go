@@ -0,0 +1,74 @@
+package script
+
+import "fmt"
+
+// Opcodes of the script language. Their values match those of Bitcoin script,
+// so familiar scripts disassemble the same way.
+const (
+	Op0         byte = 0x00 // Pushes an empty byte string, which is false.
+	OpPushData1 byte = 0x4c // The next byte is the length of the data to push.
+	OpPushData2 byte = 0x4d // The next two bytes, little endian, are the length.
+	Op1         byte = 0x51 // Op1 to Op16 push the numbers 1 to 16.
+	Op16        byte = 0x60
+
+	OpNop    byte = 0x61
+	OpIf     byte = 0x63
+	OpNotIf  byte = 0x64
+	OpElse   byte = 0x67
+	OpEndIf  byte = 0x68
+	OpVerify byte = 0x69
+	OpReturn byte = 0x6a
+
+	OpDrop byte = 0x75
+	OpDup  byte = 0x76
+	OpSwap byte = 0x7c
+
+	OpEqual       byte = 0x87
+	OpEqualVerify byte = 0x88
+
+	OpSHA256              byte = 0xa8
+	OpHash160             byte = 0xa9
+	OpCheckSig            byte = 0xac
+	OpCheckSigVerify      byte = 0xad
+	OpCheckMultiSig       byte = 0xae
+	OpCheckMultiSigVerify byte = 0xaf
+)
+
+// The largest opcode which pushes the data following it. Opcodes from 0x01 to
+// 0x4b push that many bytes.
+const maxDirectPush = 0x4b
+
+// opcodeNames maps the opcodes which don't push data to their names.
+var opcodeNames = map[byte]string{
+	OpNop:                 "OP_NOP",
+	OpIf:                  "OP_IF",
+	OpNotIf:               "OP_NOTIF",
+	OpElse:                "OP_ELSE",
+	OpEndIf:               "OP_ENDIF",
+	OpVerify:              "OP_VERIFY",
+	OpReturn:              "OP_RETURN",
+	OpDrop:                "OP_DROP",
+	OpDup:                 "OP_DUP",
+	OpSwap:                "OP_SWAP",
+	OpEqual:               "OP_EQUAL",
+	OpEqualVerify:         "OP_EQUALVERIFY",
+	OpSHA256:              "OP_SHA256",
+	OpHash160:             "OP_HASH160",
+	OpCheckSig:            "OP_CHECKSIG",
+	OpCheckSigVerify:      "OP_CHECKSIGVERIFY",
+	OpCheckMultiSig:       "OP_CHECKMULTISIG",
+	OpCheckMultiSigVerify: "OP_CHECKMULTISIGVERIFY",
+}
+
+// opcodeName returns the name of an opcode which doesn't push data.
+func opcodeName(op byte) string {
+	if op >= Op1 && op <= Op16 {
+		return fmt.Sprintf("OP_%d", op-Op1+1)
+	}
+
+	if name, ok := opcodeNames[op]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("OP_UNKNOWN%d", op)
+}
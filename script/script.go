@@ -0,0 +1,204 @@
+package script
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// The largest script that can be executed.
+	MaxScriptSize = 10000
+
+	// The largest byte string a script can push.
+	MaxPushSize = 520
+)
+
+// ErrMalformedPush is returned when a script ends part way through pushing
+// data.
+var ErrMalformedPush = errors.New("script ends in the middle of a push")
+
+// instruction is a parsed opcode along with the data it pushes, if any.
+type instruction struct {
+	op   byte
+	data []byte
+}
+
+// isPush reports whether the instruction only pushes a value onto the stack.
+func (in instruction) isPush() bool {
+	return in.op <= OpPushData2 || (in.op >= Op1 && in.op <= Op16)
+}
+
+// parse splits a script into its instructions. If the script is malformed, the
+// instructions before the error are returned with it.
+func parse(script []byte) ([]instruction, error) {
+	var instructions []instruction
+
+	for len(script) > 0 {
+		op := script[0]
+		script = script[1:]
+
+		var n int
+		switch {
+		case op > Op0 && op <= maxDirectPush:
+			n = int(op)
+		case op == OpPushData1:
+			if len(script) < 1 {
+				return instructions, ErrMalformedPush
+			}
+			n, script = int(script[0]), script[1:]
+		case op == OpPushData2:
+			if len(script) < 2 {
+				return instructions, ErrMalformedPush
+			}
+			n, script = int(binary.LittleEndian.Uint16(script)), script[2:]
+		}
+
+		if len(script) < n {
+			return instructions, ErrMalformedPush
+		}
+
+		instructions = append(instructions, instruction{op, script[:n]})
+		script = script[n:]
+	}
+
+	return instructions, nil
+}
+
+// IsPushOnly reports whether a script is well formed and only pushes values
+// onto the stack.
+func IsPushOnly(script []byte) bool {
+	instructions, err := parse(script)
+	if err != nil {
+		return false
+	}
+
+	for _, in := range instructions {
+		if !in.isPush() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// PushedData returns the byte strings pushed by a push only script. Small
+// numbers pushed with Op1 to Op16 are returned as a single byte.
+func PushedData(script []byte) ([][]byte, error) {
+	instructions, err := parse(script)
+	if err != nil {
+		return nil, err
+	}
+
+	var data [][]byte
+	for _, in := range instructions {
+		if !in.isPush() {
+			return nil, fmt.Errorf("script is not push only, found %s", opcodeName(in.op))
+		}
+
+		data = append(data, pushValue(in))
+	}
+
+	return data, nil
+}
+
+// pushValue returns the value a push instruction puts on the stack.
+func pushValue(in instruction) []byte {
+	if in.op >= Op1 && in.op <= Op16 {
+		return []byte{in.op - Op1 + 1}
+	}
+
+	return in.data
+}
+
+// Disassemble returns a script in a human readable form, with opcodes by name
+// and pushed data in hex. Malformed scripts are marked as such.
+func Disassemble(script []byte) string {
+	instructions, err := parse(script)
+
+	var parts []string
+	for _, in := range instructions {
+		switch {
+		case in.op == Op0:
+			parts = append(parts, "0")
+		case in.op <= OpPushData2:
+			parts = append(parts, hex.EncodeToString(in.data))
+		default:
+			parts = append(parts, opcodeName(in.op))
+		}
+	}
+
+	if err != nil {
+		parts = append(parts, "[error]")
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// Builder builds a script one instruction at a time, using the smallest
+// push for each piece of data.
+type Builder struct {
+	script []byte
+	err    error
+}
+
+// NewBuilder returns an empty script Builder.
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// AddOp adds an opcode to the script.
+func (b *Builder) AddOp(op byte) *Builder {
+	b.script = append(b.script, op)
+
+	return b
+}
+
+// AddData adds a push of the given data to the script.
+func (b *Builder) AddData(data []byte) *Builder {
+	switch n := len(data); {
+	case n > MaxPushSize:
+		b.err = fmt.Errorf("push of %d bytes is larger than %d", n, MaxPushSize)
+	case n == 0:
+		b.script = append(b.script, Op0)
+	case n <= maxDirectPush:
+		b.script = append(b.script, byte(n))
+	case n <= 0xff:
+		b.script = append(b.script, OpPushData1, byte(n))
+	default:
+		var l [2]byte
+		binary.LittleEndian.PutUint16(l[:], uint16(n))
+		b.script = append(append(b.script, OpPushData2), l[:]...)
+	}
+
+	if b.err == nil {
+		b.script = append(b.script, data...)
+	}
+
+	return b
+}
+
+// AddInt adds a push of a number from 0 to 16 to the script.
+func (b *Builder) AddInt(n int) *Builder {
+	switch {
+	case n == 0:
+		b.script = append(b.script, Op0)
+	case n >= 1 && n <= 16:
+		b.script = append(b.script, Op1+byte(n-1))
+	default:
+		b.err = fmt.Errorf("%d can't be pushed as a small number", n)
+	}
+
+	return b
+}
+
+// Script returns the built script, or the first error encountered.
+func (b *Builder) Script() ([]byte, error) {
+	if b.err == nil && len(b.script) > MaxScriptSize {
+		b.err = fmt.Errorf("script of %d bytes is larger than %d", len(b.script), MaxScriptSize)
+	}
+
+	return b.script, b.err
+}
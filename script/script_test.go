@@ -0,0 +1,122 @@
+package script
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeChecker accepts one signature by one public key.
+type fakeChecker struct {
+	sig, pubKey []byte
+}
+
+func (c fakeChecker) CheckSig(sig, pubKey []byte) bool {
+	return bytes.Equal(sig, c.sig) && bytes.Equal(pubKey, c.pubKey)
+}
+
+func TestPayToPubKeyHash(t *testing.T) {
+	sig, pubKey := []byte("signature"), []byte("public key")
+	checker := fakeChecker{sig, pubKey}
+
+	pkScript := PayToPubKeyHash(Hash160(pubKey))
+	assert.Equal(t, PubKeyHash, Classify(pkScript), "Script is pay to public key hash")
+	assert.Equal(t, Hash160(pubKey), ExtractPubKeyHash(pkScript), "Hash is extracted")
+
+	err := Execute(SignatureScript(sig, pubKey), pkScript, checker)
+	assert.NoError(t, err, "Output is spent with the right key")
+
+	err = Execute(SignatureScript([]byte("forged"), pubKey), pkScript, checker)
+	assert.Equal(t, ErrEvalFalse, err, "Bad signature is rejected")
+
+	err = Execute(SignatureScript(sig, []byte("other key")), pkScript, checker)
+	assert.Equal(t, ErrVerify, err, "Wrong public key is rejected")
+
+	err = Execute(append(SignatureScript(sig, pubKey), OpDup), pkScript, checker)
+	assert.Equal(t, ErrSigScriptNotPush, err, "Signature script must be push only")
+
+	assert.Equal(t, NonStandard, Classify(append(pkScript, OpNop)), "Extra opcodes are nonstandard")
+}
+
+func TestConditionals(t *testing.T) {
+	pkScript, err := NewBuilder().
+		AddOp(OpIf).AddInt(2).
+		AddOp(OpElse).AddInt(3).
+		AddOp(OpEndIf).
+		AddInt(3).AddOp(OpEqual).
+		Script()
+	assert.NoError(t, err, "Script is built")
+
+	sigScript, _ := NewBuilder().AddInt(0).Script()
+	assert.NoError(t, Execute(sigScript, pkScript, nil), "Else branch is taken")
+
+	sigScript, _ = NewBuilder().AddInt(1).Script()
+	assert.Equal(t, ErrEvalFalse, Execute(sigScript, pkScript, nil), "If branch is taken")
+
+	unbalanced, _ := NewBuilder().AddOp(OpIf).AddInt(1).Script()
+	assert.Equal(t, ErrUnbalancedIf, Execute(sigScript, unbalanced, nil), "Unbalanced if is rejected")
+
+	assert.Equal(t, ErrStackUnderflow, Execute(nil, []byte{OpDup}, nil), "Empty stack underflows")
+	assert.Equal(t, ErrMalformedPush, Execute(nil, []byte{5, 1, 2}, nil), "Truncated push is rejected")
+}
+
+func TestDisassemble(t *testing.T) {
+	pkScript := PayToPubKeyHash(bytes.Repeat([]byte{0xab}, 20))
+
+	assert.Equal(
+		t,
+		"OP_DUP OP_HASH160 abababababababababababababababababababab OP_EQUALVERIFY OP_CHECKSIG",
+		Disassemble(pkScript),
+		"Script is disassembled",
+	)
+	assert.Equal(t, "OP_2 [error]", Disassemble([]byte{Op1 + 1, OpPushData1}), "Malformed script is marked")
+}
+
+func TestMultiSig(t *testing.T) {
+	keys := [][]byte{[]byte("key 1"), []byte("key 2"), []byte("key 3")}
+	checker := multiChecker{}
+
+	redeemScript, err := MultiSigScript(2, keys)
+	assert.NoError(t, err, "Script is built")
+	assert.Equal(t, MultiSig, Classify(redeemScript), "Script is multisig")
+
+	m, pubKeys, ok := ExtractMultiSig(redeemScript)
+	assert.True(t, ok, "Multisig is extracted")
+	assert.Equal(t, 2, m, "Signatures needed are extracted")
+	assert.Equal(t, keys, pubKeys, "Keys are extracted")
+
+	pkScript := PayToScriptHash(Hash160(redeemScript))
+	assert.Equal(t, ScriptHash, Classify(pkScript), "Script is pay to script hash")
+
+	sigScript, _ := MultiSigSigScript([][]byte{[]byte("sig key 1"), []byte("sig key 3")}, redeemScript)
+	assert.NoError(t, Execute(sigScript, pkScript, checker), "Signatures in key order are accepted")
+
+	sigScript, _ = MultiSigSigScript([][]byte{[]byte("sig key 3"), []byte("sig key 1")}, redeemScript)
+	assert.Equal(t, ErrEvalFalse, Execute(sigScript, pkScript, checker), "Signatures out of order are rejected")
+
+	sigScript, _ = MultiSigSigScript([][]byte{[]byte("sig key 2")}, redeemScript)
+	assert.Equal(t, ErrStackUnderflow, Execute(sigScript, pkScript, checker), "Too few signatures are rejected")
+
+	otherScript, _ := MultiSigScript(1, keys)
+	sigScript, _ = MultiSigSigScript([][]byte{[]byte("sig key 1")}, otherScript)
+	assert.Equal(t, ErrEvalFalse, Execute(sigScript, pkScript, checker), "Another redeem script is rejected")
+}
+
+// multiChecker accepts "sig " followed by the public key as its signature.
+type multiChecker struct{}
+
+func (multiChecker) CheckSig(sig, pubKey []byte) bool {
+	return bytes.Equal(sig, append([]byte("sig "), pubKey...))
+}
+
+func TestNullData(t *testing.T) {
+	dataScript, err := NullDataScript([]byte("document hash"))
+	assert.NoError(t, err, "Script is built")
+	assert.Equal(t, NullData, Classify(dataScript), "Script is null data")
+	assert.True(t, IsUnspendable(dataScript), "Script is unspendable")
+	assert.Equal(t, ErrEarlyReturn, Execute(nil, dataScript, nil), "Script can't be spent")
+
+	_, err = NullDataScript(make([]byte, MaxDataCarrierSize+1))
+	assert.Error(t, err, "Too much data is rejected")
+}
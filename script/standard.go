@@ -0,0 +1,295 @@
+package script
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+)
+
+// Class identifies the standard template a public key script follows.
+type Class int
+
+const (
+	// NonStandard scripts don't follow any of the templates.
+	NonStandard Class = iota
+
+	// PubKeyHash scripts pay to the hash of a public key:
+	//	OP_DUP OP_HASH160 <hash> OP_EQUALVERIFY OP_CHECKSIG
+	// and are spent with:
+	//	<signature> <public key>
+	PubKeyHash
+
+	// ScriptHash scripts pay to the hash of a redeem script:
+	//	OP_HASH160 <hash> OP_EQUAL
+	// and are spent with the values the redeem script needs, followed by the
+	// redeem script itself.
+	ScriptHash
+
+	// MultiSig scripts need m signatures from n public keys:
+	//	<m> <public key 1> ... <public key n> <n> OP_CHECKMULTISIG
+	// and are spent with the signatures in the order of their keys:
+	//	<signature 1> ... <signature m>
+	MultiSig
+
+	// NullData scripts carry up to MaxDataCarrierSize bytes of data and can
+	// never be spent:
+	//	OP_RETURN <data>
+	NullData
+)
+
+const (
+	// The length of a public key hash.
+	pubKeyHashLen = 20
+
+	// The most data a null data script may carry.
+	MaxDataCarrierSize = 80
+)
+
+// String returns the name of the class.
+func (c Class) String() string {
+	switch c {
+	case PubKeyHash:
+		return "pubkeyhash"
+	case ScriptHash:
+		return "scripthash"
+	case MultiSig:
+		return "multisig"
+	case NullData:
+		return "nulldata"
+	default:
+		return "nonstandard"
+	}
+}
+
+// Classify returns the standard template a public key script follows.
+func Classify(script []byte) Class {
+	if ExtractPubKeyHash(script) != nil {
+		return PubKeyHash
+	}
+
+	if ExtractScriptHash(script) != nil {
+		return ScriptHash
+	}
+
+	if _, _, ok := ExtractMultiSig(script); ok {
+		return MultiSig
+	}
+
+	if _, ok := ExtractNullData(script); ok {
+		return NullData
+	}
+
+	return NonStandard
+}
+
+// PayToPubKeyHash returns a script paying to the hash of a public key.
+func PayToPubKeyHash(pubKeyHash []byte) []byte {
+	script, err := NewBuilder().
+		AddOp(OpDup).
+		AddOp(OpHash160).
+		AddData(pubKeyHash).
+		AddOp(OpEqualVerify).
+		AddOp(OpCheckSig).
+		Script()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return script
+}
+
+// ExtractPubKeyHash returns the public key hash paid to by a pay to public key
+// hash script, or nil if the script is of another kind.
+func ExtractPubKeyHash(script []byte) []byte {
+	if len(script) != pubKeyHashLen+5 ||
+		script[0] != OpDup ||
+		script[1] != OpHash160 ||
+		script[2] != pubKeyHashLen ||
+		script[pubKeyHashLen+3] != OpEqualVerify ||
+		script[pubKeyHashLen+4] != OpCheckSig {
+		return nil
+	}
+
+	return script[3 : pubKeyHashLen+3]
+}
+
+// SignatureScript returns the script spending a pay to public key hash output
+// with the given signature and public key.
+func SignatureScript(sig, pubKey []byte) []byte {
+	script, err := NewBuilder().AddData(sig).AddData(pubKey).Script()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return script
+}
+
+// ExtractSigPubKey returns the public key of a signature script spending a pay
+// to public key hash output, or nil if the script is of another kind.
+func ExtractSigPubKey(sigScript []byte) []byte {
+	data, err := PushedData(sigScript)
+	if err != nil || len(data) != 2 || len(data[1]) == 0 {
+		return nil
+	}
+
+	return data[1]
+}
+
+// IsPayToPubKeyHash reports whether a script pays to the given public key
+// hash.
+func IsPayToPubKeyHash(script, pubKeyHash []byte) bool {
+	hash := ExtractPubKeyHash(script)
+
+	return hash != nil && bytes.Equal(hash, pubKeyHash)
+}
+
+// PayToScriptHash returns a script paying to the hash of a redeem script.
+func PayToScriptHash(scriptHash []byte) []byte {
+	script, err := NewBuilder().
+		AddOp(OpHash160).
+		AddData(scriptHash).
+		AddOp(OpEqual).
+		Script()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return script
+}
+
+// ExtractScriptHash returns the redeem script hash paid to by a pay to script
+// hash script, or nil if the script is of another kind.
+func ExtractScriptHash(script []byte) []byte {
+	if len(script) != pubKeyHashLen+3 ||
+		script[0] != OpHash160 ||
+		script[1] != pubKeyHashLen ||
+		script[pubKeyHashLen+2] != OpEqual {
+		return nil
+	}
+
+	return script[2 : pubKeyHashLen+2]
+}
+
+// MultiSigScript returns a script needing m signatures from the given public
+// keys. To be paid to by script hash, it must be no larger than MaxPushSize.
+func MultiSigScript(m int, pubKeys [][]byte) ([]byte, error) {
+	if len(pubKeys) == 0 || len(pubKeys) > MaxMultiSigKeys {
+		return nil, fmt.Errorf("multisig needs from 1 to %d keys, got %d", MaxMultiSigKeys, len(pubKeys))
+	}
+	if m < 1 || m > len(pubKeys) {
+		return nil, fmt.Errorf("multisig can't need %d signatures from %d keys", m, len(pubKeys))
+	}
+
+	b := NewBuilder().AddInt(m)
+	for _, pubKey := range pubKeys {
+		b.AddData(pubKey)
+	}
+
+	return b.AddInt(len(pubKeys)).AddOp(OpCheckMultiSig).Script()
+}
+
+// ExtractMultiSig returns the number of signatures needed and the public keys
+// of a multisig script. False is returned if the script is of another kind.
+func ExtractMultiSig(script []byte) (int, [][]byte, bool) {
+	instructions, err := parse(script)
+	if err != nil || len(instructions) < 4 {
+		return 0, nil, false
+	}
+
+	first, last := instructions[0], instructions[len(instructions)-1]
+	nIn := instructions[len(instructions)-2]
+	if !isSmallInt(first.op) || !isSmallInt(nIn.op) || last.op != OpCheckMultiSig {
+		return 0, nil, false
+	}
+
+	var pubKeys [][]byte
+	for _, in := range instructions[1 : len(instructions)-2] {
+		if in.op > OpPushData2 || len(in.data) == 0 {
+			return 0, nil, false
+		}
+		pubKeys = append(pubKeys, in.data)
+	}
+
+	m, n := smallInt(first.op), smallInt(nIn.op)
+	if m < 1 || m > n || n != len(pubKeys) {
+		return 0, nil, false
+	}
+
+	return m, pubKeys, true
+}
+
+// MultiSigSigScript returns the script spending a pay to script hash output
+// with a multisig redeem script, given signatures in the order of their keys.
+// With fewer signatures than needed, it is a partly signed script which more
+// signatures can be added to.
+func MultiSigSigScript(sigs [][]byte, redeemScript []byte) ([]byte, error) {
+	b := NewBuilder()
+	for _, sig := range sigs {
+		b.AddData(sig)
+	}
+
+	return b.AddData(redeemScript).Script()
+}
+
+// ExtractMultiSigSigs returns the signatures and redeem script of a script
+// made by MultiSigSigScript. False is returned if the script is of another
+// kind.
+func ExtractMultiSigSigs(sigScript []byte) ([][]byte, []byte, bool) {
+	data, err := PushedData(sigScript)
+	if err != nil || len(data) == 0 {
+		return nil, nil, false
+	}
+
+	redeemScript := data[len(data)-1]
+	if _, _, ok := ExtractMultiSig(redeemScript); !ok {
+		return nil, nil, false
+	}
+
+	return data[:len(data)-1], redeemScript, true
+}
+
+// NullDataScript returns a script carrying data, which can never be spent.
+func NullDataScript(data []byte) ([]byte, error) {
+	if len(data) > MaxDataCarrierSize {
+		return nil, fmt.Errorf("data of %d bytes is larger than %d", len(data), MaxDataCarrierSize)
+	}
+
+	return NewBuilder().AddOp(OpReturn).AddData(data).Script()
+}
+
+// ExtractNullData returns the data carried by a null data script. False is
+// returned if the script is of another kind.
+func ExtractNullData(script []byte) ([]byte, bool) {
+	instructions, err := parse(script)
+	if err != nil || len(instructions) != 2 || instructions[0].op != OpReturn {
+		return nil, false
+	}
+
+	data := instructions[1]
+	if data.op > OpPushData2 || len(data.data) > MaxDataCarrierSize {
+		return nil, false
+	}
+
+	return data.data, true
+}
+
+// IsUnspendable reports whether a script can never be spent, because it
+// starts with OP_RETURN. Outputs with such scripts needn't be kept as
+// unspent.
+func IsUnspendable(script []byte) bool {
+	return len(script) > 0 && script[0] == OpReturn
+}
+
+// isSmallInt reports whether an opcode pushes a number from 0 to 16.
+func isSmallInt(op byte) bool {
+	return op == Op0 || (op >= Op1 && op <= Op16)
+}
+
+// smallInt returns the number pushed by an opcode from Op0 to Op16.
+func smallInt(op byte) int {
+	if op == Op0 {
+		return 0
+	}
+
+	return int(op-Op1) + 1
+}
@@ -0,0 +1,440 @@
+package server
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/danmrichards/yagocoin/crypto"
+	"github.com/danmrichards/yagocoin/script"
+)
+
+const (
+	// The number of blocks listed by the explorer by default, and at most.
+	defaultExplorerBlocks = 10
+	maxExplorerBlocks     = 100
+)
+
+// explorerBlock is the JSON representation of a block.
+type explorerBlock struct {
+	Hash              string       `json:"hash"`
+	Height            int          `json:"height"`
+	Version           int          `json:"version"`
+	PreviousBlockHash string       `json:"previousBlockHash"`
+	MerkleRoot        string       `json:"merkleRoot"`
+	Time              int64        `json:"time"`
+	Bits              string       `json:"bits"`
+	Nonce             uint32       `json:"nonce"`
+	Transactions      []explorerTx `json:"transactions,omitempty"`
+}
+
+// explorerTx is the JSON representation of a transaction.
+type explorerTx struct {
+	TxID      string           `json:"txid"`
+	Coinbase  bool             `json:"coinbase"`
+	BlockHash string           `json:"blockHash,omitempty"`
+	Height    *int             `json:"height,omitempty"`
+	Inputs    []explorerInput  `json:"inputs"`
+	Outputs   []explorerOutput `json:"outputs"`
+	LockTime  uint32           `json:"locktime"`
+}
+
+// explorerInput is the JSON representation of a transaction input.
+type explorerInput struct {
+	TxID     string `json:"txid,omitempty"`
+	Vout     int    `json:"vout"`
+	Address  string `json:"address,omitempty"`
+	Sequence uint32 `json:"sequence"`
+}
+
+// explorerOutput is the JSON representation of a transaction output. Data
+// outputs have the hex encoded data they carry instead of an address.
+type explorerOutput struct {
+	Value   int     `json:"value"`
+	Address string  `json:"address,omitempty"`
+	Data    *string `json:"data,omitempty"`
+	Script  string  `json:"script"`
+}
+
+// explorerAddress is the JSON representation of an address.
+type explorerAddress struct {
+	Address  string                 `json:"address"`
+	Balance  int                    `json:"balance"`
+	Immature int                    `json:"immature"` // Coinbase outputs which can't be spent yet.
+	History  []explorerHistoryEntry `json:"history"`
+}
+
+// explorerHistoryEntry is a payment to or from an address.
+type explorerHistoryEntry struct {
+	TxID      string `json:"txid"`
+	BlockHash string `json:"blockHash"`
+	Height    int    `json:"height"`
+	Value     int    `json:"value"`
+	Direction string `json:"direction"` // Either "received" or "sent".
+}
+
+// explorerStatus is the JSON representation of the state of the node.
+type explorerStatus struct {
+	Height   int    `json:"height"`
+	BestHash string `json:"bestHash"`
+	Mempool  int    `json:"mempool"`
+}
+
+// explorerServer serves the block explorer API and web UI.
+type explorerServer struct {
+	bc   *crypto.Blockchain
+	http *http.Server
+}
+
+// startExplorer starts serving the block explorer in the background.
+func startExplorer(addr string, bc *crypto.Blockchain) *explorerServer {
+	s := &explorerServer{bc: bc}
+	s.http = &http.Server{Addr: addr, Handler: s.handler()}
+
+	go func() {
+		err := s.http.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			log.Panic(err)
+		}
+	}()
+
+	fmt.Printf("Block explorer listening on http://%s/\n", addr)
+
+	return s
+}
+
+// handler routes the requests of the explorer.
+func (s *explorerServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handleUI)
+	mux.HandleFunc("/api/status", s.handleStatus)
+	mux.HandleFunc("/api/blocks", s.handleBlocks)
+	mux.HandleFunc("/api/block/", s.handleBlock)
+	mux.HandleFunc("/api/height/", s.handleHeight)
+	mux.HandleFunc("/api/tx/", s.handleTx)
+	mux.HandleFunc("/api/address/", s.handleAddress)
+	mux.HandleFunc("/api/mempool", s.handleMempool)
+
+	return mux
+}
+
+// shutdown stops the explorer.
+func (s *explorerServer) shutdown() {
+	s.http.Close()
+}
+
+// handleUI serves the explorer web page.
+func (s *explorerServer) handleUI(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, explorerPage)
+}
+
+// handleStatus serves the height and tip of the chain.
+func (s *explorerServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, explorerStatus{
+		Height:   s.bc.GetBestHeight(),
+		BestHash: hex.EncodeToString(s.bc.GetBestHash()),
+		Mempool:  mempool.Count(),
+	})
+}
+
+// handleBlocks serves the most recent blocks, without their transactions.
+func (s *explorerServer) handleBlocks(w http.ResponseWriter, r *http.Request) {
+	limit := defaultExplorerBlocks
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	if limit > maxExplorerBlocks {
+		limit = maxExplorerBlocks
+	}
+
+	blocks := []explorerBlock{}
+	bci := s.bc.Iterator()
+
+	for len(blocks) < limit {
+		block := bci.Next()
+
+		summary := newExplorerBlock(block)
+		summary.Transactions = nil
+		blocks = append(blocks, summary)
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	writeJSON(w, blocks)
+}
+
+// handleBlock serves a block by its hash.
+func (s *explorerServer) handleBlock(w http.ResponseWriter, r *http.Request) {
+	hash, err := hex.DecodeString(strings.TrimPrefix(r.URL.Path, "/api/block/"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid block hash")
+		return
+	}
+
+	block, err := s.bc.GetBlock(hash)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "block not found")
+		return
+	}
+
+	writeJSON(w, newExplorerBlock(&block))
+}
+
+// handleHeight serves the block at a height of the main chain.
+func (s *explorerServer) handleHeight(w http.ResponseWriter, r *http.Request) {
+	height, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/height/"))
+	if err != nil || height < 0 {
+		writeError(w, http.StatusBadRequest, "invalid height")
+		return
+	}
+
+	block, err := s.bc.GetBlockByHeight(height)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "block not found")
+		return
+	}
+
+	writeJSON(w, newExplorerBlock(&block))
+}
+
+// handleTx serves a transaction from the mempool or the main chain.
+func (s *explorerServer) handleTx(w http.ResponseWriter, r *http.Request) {
+	txID, err := hex.DecodeString(strings.TrimPrefix(r.URL.Path, "/api/tx/"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid transaction ID")
+		return
+	}
+
+	if tx, ok := mempool.Get(txID); ok {
+		writeJSON(w, newExplorerTx(tx, nil))
+		return
+	}
+
+	tx, block, err := s.bc.FindTransactionBlock(txID)
+	if err == nil {
+		writeJSON(w, newExplorerTx(&tx, &block))
+		return
+	}
+
+	writeError(w, http.StatusNotFound, "transaction not found")
+}
+
+// handleAddress serves the balance and confirmed history of an address.
+func (s *explorerServer) handleAddress(w http.ResponseWriter, r *http.Request) {
+	address := strings.TrimPrefix(r.URL.Path, "/api/address/")
+	if !crypto.ValidateAddress(address) {
+		writeError(w, http.StatusBadRequest, "invalid address")
+		return
+	}
+
+	pubKeyHash := crypto.GetPublicKeyHash([]byte(address))
+	result := explorerAddress{Address: address, History: []explorerHistoryEntry{}}
+
+	uTxOSet := crypto.UTxOSet{Blockchain: s.bc}
+	result.Balance, result.Immature = uTxOSet.GetBalance(pubKeyHash)
+
+	// Use the address index if it's been built, otherwise walk the chain.
+	history, err := s.bc.AddressHistory(pubKeyHash)
+	if err == nil {
+		result.History = indexedAddressHistory(history)
+	} else {
+		result.History = scanAddressHistory(s.bc, pubKeyHash)
+	}
+
+	writeJSON(w, result)
+}
+
+// handleMempool serves the transactions in the mempool.
+func (s *explorerServer) handleMempool(w http.ResponseWriter, r *http.Request) {
+	txs := []explorerTx{}
+
+	for _, entry := range mempool.Entries() {
+		txs = append(txs, newExplorerTx(entry.Tx, nil))
+	}
+
+	writeJSON(w, txs)
+}
+
+// indexedAddressHistory converts the history from the address index, newest
+// first. Entries for the same transaction and direction are combined.
+func indexedAddressHistory(history []crypto.AddressHistoryEntry) []explorerHistoryEntry {
+	entries := []explorerHistoryEntry{}
+
+	for i := len(history) - 1; i >= 0; i-- {
+		h := history[i]
+		txID := hex.EncodeToString(h.TxID)
+		direction := h.Direction.String()
+
+		last := len(entries) - 1
+		if last >= 0 && entries[last].TxID == txID && entries[last].Direction == direction {
+			entries[last].Value += h.Value
+			continue
+		}
+
+		entries = append(entries, explorerHistoryEntry{
+			TxID:      txID,
+			BlockHash: hex.EncodeToString(h.BlockHash),
+			Height:    h.Height,
+			Value:     h.Value,
+			Direction: direction,
+		})
+	}
+
+	return entries
+}
+
+// scanAddressHistory walks the chain for every payment to or from an address,
+// newest first.
+func scanAddressHistory(bc *crypto.Blockchain, pubKeyHash []byte) []explorerHistoryEntry {
+	entries := []explorerHistoryEntry{}
+
+	bci := bc.Iterator()
+	for {
+		block := bci.Next()
+
+		for _, tx := range block.Transactions {
+			entry := explorerHistoryEntry{
+				TxID:      hex.EncodeToString(tx.ID),
+				BlockHash: hex.EncodeToString(block.Hash),
+				Height:    block.Height,
+			}
+
+			sent := 0
+			if !tx.IsCoinbase() {
+				for _, vin := range tx.Vin {
+					if !vin.UsesKey(pubKeyHash) {
+						continue
+					}
+
+					prevTx, err := bc.FindTransaction(vin.Txid)
+					if err == nil {
+						sent += prevTx.Vout[vin.Vout].Value
+					}
+				}
+			}
+
+			received := 0
+			for _, out := range tx.Vout {
+				if out.IsLockedWithHash(pubKeyHash) {
+					received += out.Value
+				}
+			}
+
+			if sent > 0 {
+				entry.Value = sent
+				entry.Direction = crypto.Sent.String()
+				entries = append(entries, entry)
+			}
+
+			if received > 0 {
+				entry.Value = received
+				entry.Direction = crypto.Received.String()
+				entries = append(entries, entry)
+			}
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	return entries
+}
+
+// newExplorerBlock converts a block to its JSON representation.
+func newExplorerBlock(block *crypto.Block) explorerBlock {
+	result := explorerBlock{
+		Hash:              hex.EncodeToString(block.Hash),
+		Height:            block.Height,
+		Version:           block.Version,
+		PreviousBlockHash: hex.EncodeToString(block.PrevBlockHash),
+		MerkleRoot:        hex.EncodeToString(block.MerkleRoot),
+		Time:              block.Timestamp.Unix(),
+		Bits:              fmt.Sprintf("%08x", block.Bits),
+		Nonce:             block.Nonce,
+	}
+
+	for _, tx := range block.Transactions {
+		result.Transactions = append(result.Transactions, newExplorerTx(tx, block))
+	}
+
+	return result
+}
+
+// newExplorerTx converts a transaction to its JSON representation. The block
+// is nil for unconfirmed transactions.
+func newExplorerTx(tx *crypto.Transaction, block *crypto.Block) explorerTx {
+	result := explorerTx{
+		TxID:     hex.EncodeToString(tx.ID),
+		Coinbase: tx.IsCoinbase(),
+		Inputs:   []explorerInput{},
+		Outputs:  []explorerOutput{},
+		LockTime: tx.LockTime,
+	}
+
+	if block != nil {
+		result.BlockHash = hex.EncodeToString(block.Hash)
+		result.Height = &block.Height
+	}
+
+	for _, vin := range tx.Vin {
+		if tx.IsCoinbase() {
+			result.Inputs = append(result.Inputs, explorerInput{Vout: vin.Vout, Sequence: vin.Sequence})
+			continue
+		}
+
+		input := explorerInput{TxID: hex.EncodeToString(vin.Txid), Vout: vin.Vout, Sequence: vin.Sequence}
+		if pubKey := vin.PubKey(); pubKey != nil {
+			input.Address = string(crypto.AddressFromPubKeyHash(crypto.HashPubKey(pubKey)))
+		}
+
+		result.Inputs = append(result.Inputs, input)
+	}
+
+	for _, out := range tx.Vout {
+		output := explorerOutput{Value: out.Value, Script: script.Disassemble(out.ScriptPubKey)}
+		if address := out.Address(); address != nil {
+			output.Address = string(address)
+		} else if data, ok := script.ExtractNullData(out.ScriptPubKey); ok {
+			dataHex := hex.EncodeToString(data)
+			output.Data = &dataHex
+		}
+
+		result.Outputs = append(result.Outputs, output)
+	}
+
+	return result
+}
+
+// writeJSON writes a value as a JSON response.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// writeError writes an error as a JSON response.
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(map[string]string{"error": message})
+	if err != nil {
+		log.Println(err)
+	}
+}
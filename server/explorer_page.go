@@ -0,0 +1,148 @@
+package server
+
+// explorerPage is the block explorer web UI. It is a single page which reads
+// the explorer API and links blocks, transactions and addresses together.
+const explorerPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>yagocoin explorer</title>
+<style>
+body { font-family: sans-serif; margin: 2em auto; max-width: 60em; color: #222; }
+h1 a { color: inherit; text-decoration: none; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 1em; }
+th, td { text-align: left; padding: 0.3em 0.6em; border-bottom: 1px solid #ddd; }
+td.hash, span.hash { font-family: monospace; word-break: break-all; }
+form input { width: 40em; }
+.error { color: #b00; }
+</style>
+</head>
+<body>
+<h1><a href="#/">yagocoin explorer</a></h1>
+<form id="search">
+<input id="query" placeholder="Block hash, height, transaction ID or address">
+<button>Search</button>
+</form>
+<div id="content"></div>
+<script>
+var content = document.getElementById("content");
+
+function esc(s) {
+  return String(s).replace(/[&<>"]/g, function (c) {
+    return {"&": "&amp;", "<": "&lt;", ">": "&gt;", '"': "&quot;"}[c];
+  });
+}
+
+function link(kind, id, text) {
+  return '<a class="hash" href="#/' + kind + '/' + esc(id) + '">' + esc(text || id) + '</a>';
+}
+
+function time(t) {
+  return new Date(t * 1000).toISOString();
+}
+
+function get(path) {
+  return fetch("/api/" + path).then(function (r) {
+    return r.json().then(function (body) {
+      if (!r.ok) throw new Error(body.error);
+      return body;
+    });
+  });
+}
+
+function txTable(txs) {
+  var html = "<table><tr><th>Transaction</th><th>Inputs</th><th>Outputs</th></tr>";
+  txs.forEach(function (tx) {
+    var ins = tx.coinbase ? "Coinbase" : tx.inputs.map(function (i) {
+      var from = i.address ? " from " + link("address", i.address) : "";
+      return link("tx", i.txid, i.txid.slice(0, 16) + "…:" + i.vout) + from;
+    }).join("<br>");
+    var outs = tx.outputs.map(function (o) {
+      if (o.data !== undefined) return "data: <span class=\"hash\">" + esc(o.data) + "</span>";
+      if (!o.address) return o.value + " to " + esc(o.script);
+      return o.value + " to " + link("address", o.address);
+    }).join("<br>");
+    html += "<tr><td>" + link("tx", tx.txid) + "</td><td>" + ins + "</td><td>" + outs + "</td></tr>";
+  });
+  return html + "</table>";
+}
+
+function showHome() {
+  return Promise.all([get("status"), get("blocks"), get("mempool")]).then(function (r) {
+    var status = r[0], blocks = r[1], pool = r[2];
+    var html = "<p>Height " + status.height + ", " + status.mempool + " transactions in the mempool.</p>";
+    html += "<h2>Latest blocks</h2><table><tr><th>Height</th><th>Hash</th><th>Time</th></tr>";
+    blocks.forEach(function (b) {
+      html += "<tr><td>" + b.height + "</td><td>" + link("block", b.hash) + "</td><td>" + time(b.time) + "</td></tr>";
+    });
+    html += "</table><h2>Mempool</h2>" + txTable(pool);
+    content.innerHTML = html;
+  });
+}
+
+function showBlock(block) {
+  var html = "<h2>Block " + block.height + "</h2><table>";
+  html += "<tr><th>Hash</th><td class=\"hash\">" + esc(block.hash) + "</td></tr>";
+  html += "<tr><th>Previous block</th><td>" + (block.previousBlockHash ? link("block", block.previousBlockHash) : "None") + "</td></tr>";
+  html += "<tr><th>Merkle root</th><td class=\"hash\">" + esc(block.merkleRoot) + "</td></tr>";
+  html += "<tr><th>Time</th><td>" + time(block.time) + "</td></tr>";
+  html += "<tr><th>Bits</th><td>" + esc(block.bits) + "</td></tr>";
+  html += "<tr><th>Nonce</th><td>" + block.nonce + "</td></tr>";
+  html += "</table><h2>Transactions</h2>" + txTable(block.transactions);
+  content.innerHTML = html;
+}
+
+function showTx(tx) {
+  var html = "<h2>Transaction</h2><p><span class=\"hash\">" + esc(tx.txid) + "</span></p>";
+  html += tx.blockHash ? "<p>Confirmed in block " + link("block", tx.blockHash) + " at height " + tx.height + "</p>" : "<p>Unconfirmed</p>";
+  content.innerHTML = html + txTable([tx]);
+}
+
+function showAddress(addr) {
+  var html = "<h2>Address</h2><p class=\"hash\">" + esc(addr.address) + "</p><p>Balance: " + addr.balance +
+    (addr.immature ? " (plus " + addr.immature + " immature)" : "") + "</p>";
+  html += "<table><tr><th>Height</th><th>Transaction</th><th>Amount</th></tr>";
+  addr.history.forEach(function (h) {
+    html += "<tr><td>" + link("block", h.blockHash, h.height) + "</td><td>" + link("tx", h.txid) + "</td><td>" +
+      (h.direction === "sent" ? "-" : "+") + h.value + "</td></tr>";
+  });
+  content.innerHTML = html + "</table>";
+}
+
+function route() {
+  var parts = location.hash.replace(/^#\/?/, "").split("/");
+  var page;
+  switch (parts[0]) {
+  case "block": page = get("block/" + parts[1]).then(showBlock); break;
+  case "height": page = get("height/" + parts[1]).then(showBlock); break;
+  case "tx": page = get("tx/" + parts[1]).then(showTx); break;
+  case "address": page = get("address/" + parts[1]).then(showAddress); break;
+  default: page = showHome();
+  }
+  page.catch(function (err) {
+    content.innerHTML = '<p class="error">' + esc(err.message) + "</p>";
+  });
+}
+
+document.getElementById("search").onsubmit = function (e) {
+  e.preventDefault();
+  var q = document.getElementById("query").value.trim();
+  if (/^\d+$/.test(q)) {
+    location.hash = "#/height/" + q;
+  } else if (/^[0-9a-f]{64}$/i.test(q)) {
+    get("block/" + q).then(function () {
+      location.hash = "#/block/" + q;
+    }, function () {
+      location.hash = "#/tx/" + q;
+    });
+  } else {
+    location.hash = "#/address/" + q;
+  }
+};
+
+window.onhashchange = route;
+route();
+</script>
+</body>
+</html>
+`
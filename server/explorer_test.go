@@ -0,0 +1,116 @@
+package server
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/danmrichards/yagocoin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+// explorerGet requests a path from the explorer, decoding the JSON response
+// into v.
+func explorerGet(t *testing.T, s *explorerServer, path string, v interface{}) int {
+	w := httptest.NewRecorder()
+	s.handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+	err := json.Unmarshal(w.Body.Bytes(), v)
+	assert.NoError(t, err, "Response to %s is JSON", path)
+
+	return w.Code
+}
+
+func TestExplorerBlock(t *testing.T) {
+	bc, _ := newTestChain(t)
+	s := &explorerServer{bc: bc}
+	genesis := hex.EncodeToString(bc.GetBestHash())
+
+	var block explorerBlock
+	assert.Equal(t, http.StatusOK, explorerGet(t, s, "/api/block/"+genesis, &block), "Block is found")
+	assert.Equal(t, genesis, block.Hash, "Block is returned")
+	assert.Len(t, block.Transactions, 1, "Transactions of the block are returned")
+
+	block = explorerBlock{}
+	assert.Equal(t, http.StatusOK, explorerGet(t, s, "/api/height/0", &block), "Block is found by height")
+	assert.Equal(t, genesis, block.Hash, "Block at the height is returned")
+
+	var blocks []explorerBlock
+	assert.Equal(t, http.StatusOK, explorerGet(t, s, "/api/blocks?limit=5", &blocks), "Blocks are listed")
+	assert.Len(t, blocks, 1, "Listing stops at the genesis block")
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/api/block/xyz", http.StatusBadRequest},
+		{"/api/block/" + hex.EncodeToString(make([]byte, 32)), http.StatusNotFound},
+		{"/api/height/abc", http.StatusBadRequest},
+		{"/api/height/-1", http.StatusBadRequest},
+		{"/api/height/1", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		var result map[string]string
+		assert.Equal(t, test.status, explorerGet(t, s, test.path, &result), test.path)
+		assert.NotEmpty(t, result["error"], "%s has an error message", test.path)
+	}
+}
+
+func TestExplorerTx(t *testing.T) {
+	bc, _ := newTestChain(t)
+	s := &explorerServer{bc: bc}
+
+	genesis, err := bc.GetBlockByHeight(0)
+	assert.NoError(t, err, "Genesis block is found")
+	coinbase := genesis.Transactions[0]
+
+	var tx explorerTx
+	assert.Equal(t, http.StatusOK, explorerGet(t, s, "/api/tx/"+hex.EncodeToString(coinbase.ID), &tx), "Transaction is found")
+	assert.True(t, tx.Coinbase, "Coinbase is flagged")
+	assert.Equal(t, hex.EncodeToString(genesis.Hash), tx.BlockHash, "Block of the transaction is returned")
+	assert.Equal(t, string(coinbase.Vout[0].Address()), tx.Outputs[0].Address, "Address of the output is returned")
+
+	var result map[string]string
+	assert.Equal(t, http.StatusBadRequest, explorerGet(t, s, "/api/tx/xyz", &result), "Invalid ID is rejected")
+	assert.Equal(t, http.StatusNotFound, explorerGet(t, s, "/api/tx/"+hex.EncodeToString(make([]byte, 32)), &result), "Unknown transaction is not found")
+}
+
+func TestExplorerAddress(t *testing.T) {
+	bc, w := newTestChain(t)
+	s := &explorerServer{bc: bc}
+	address := string(w.GetAddress())
+
+	var result explorerAddress
+	assert.Equal(t, http.StatusOK, explorerGet(t, s, "/api/address/"+address, &result), "Address is found")
+	assert.Equal(t, crypto.Emission.Subsidy(0), result.Balance+result.Immature, "Genesis coinbase is counted")
+	if assert.Len(t, result.History, 1, "Genesis coinbase is in the history") {
+		assert.Equal(t, crypto.Received.String(), result.History[0].Direction, "Coinbase is received")
+	}
+
+	result = explorerAddress{}
+	other := string(crypto.NewWallet().GetAddress())
+	assert.Equal(t, http.StatusOK, explorerGet(t, s, "/api/address/"+other, &result), "Unused address is found")
+	assert.Empty(t, result.History, "Unused address has no history")
+
+	var rerr map[string]string
+	assert.Equal(t, http.StatusBadRequest, explorerGet(t, s, "/api/address/nope", &rerr), "Invalid address is rejected")
+}
+
+func TestExplorerDataOutput(t *testing.T) {
+	w := crypto.NewWallet()
+	data, err := crypto.NewDataOutput([]byte("document hash"))
+	assert.NoError(t, err, "Data output is created")
+
+	tx := &crypto.Transaction{Vout: []crypto.TxOutput{*data, *crypto.NewTxOutput(1, string(w.GetAddress()))}}
+	result := newExplorerTx(tx, nil)
+
+	if assert.NotNil(t, result.Outputs[0].Data, "Data output has its data") {
+		assert.Equal(t, hex.EncodeToString([]byte("document hash")), *result.Outputs[0].Data, "Data is hex encoded")
+	}
+	assert.Empty(t, result.Outputs[0].Address, "Data output has no address")
+	assert.Nil(t, result.Outputs[1].Data, "Payment has no data")
+	assert.Equal(t, string(w.GetAddress()), result.Outputs[1].Address, "Payment has an address")
+}
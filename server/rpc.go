@@ -0,0 +1,551 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/danmrichards/yagocoin/crypto"
+)
+
+// JSON-RPC error codes. The first four are defined by the JSON-RPC 2.0
+// specification, the rest are specific to yagocoin.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+
+	rpcWalletError               = -4
+	rpcNotFound                  = -5
+	rpcWalletUnlockNeeded        = -13
+	rpcWalletPassphraseIncorrect = -14
+	rpcWalletWrongEncState       = -15
+	rpcRejected                  = -26
+
+	// The largest request body the RPC server will read.
+	maxRPCRequestSize = 1 << 20
+)
+
+// RPCConfig holds the settings of the JSON-RPC server.
+type RPCConfig struct {
+	Addr     string // Address to listen on. The server is disabled if empty.
+	User     string
+	Password string
+}
+
+// RPCError is an error returned by a JSON-RPC method.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error returns the message of the RPC error.
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// rpcRequest represents a JSON-RPC 2.0 request.
+type rpcRequest struct {
+	JSONRPC string            `json:"jsonrpc"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params"`
+	ID      json.RawMessage   `json:"id"`
+}
+
+// rpcResponse represents a JSON-RPC 2.0 response.
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result"`
+	Error   *RPCError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// MarshalJSON encodes the response with exactly one of a result, which may be
+// null, or an error, as JSON-RPC 2.0 requires.
+func (r rpcResponse) MarshalJSON() ([]byte, error) {
+	if r.Error == nil {
+		type response rpcResponse
+
+		return json.Marshal(response(r))
+	}
+
+	return json.Marshal(struct {
+		JSONRPC string          `json:"jsonrpc"`
+		Error   *RPCError       `json:"error"`
+		ID      json.RawMessage `json:"id"`
+	}{r.JSONRPC, r.Error, r.ID})
+}
+
+// BlockResult is the result of the getblock method.
+type BlockResult struct {
+	Hash              string   `json:"hash"`
+	Height            int      `json:"height"`
+	Version           int      `json:"version"`
+	PreviousBlockHash string   `json:"previousblockhash"`
+	MerkleRoot        string   `json:"merkleroot"`
+	Time              int64    `json:"time"`
+	Bits              string   `json:"bits"`
+	Nonce             uint32   `json:"nonce"`
+	Tx                []string `json:"tx"`
+	Hex               string   `json:"hex"`
+}
+
+// TransactionResult is the result of the gettransaction method.
+type TransactionResult struct {
+	TxID      string `json:"txid"`
+	Confirmed bool   `json:"confirmed"`
+	Hex       string `json:"hex"`
+}
+
+// BalanceResult is the result of the getbalance method. Immature coinbase
+// outputs are not part of the balance.
+type BalanceResult struct {
+	Address  string `json:"address"`
+	Balance  int    `json:"balance"`
+	Immature int    `json:"immature"`
+}
+
+// MempoolInfoResult is the result of the getmempoolinfo method.
+type MempoolInfoResult struct {
+	Size  int `json:"size"`
+	Bytes int `json:"bytes"`
+}
+
+// rpcHandler handles a JSON-RPC method call.
+type rpcHandler func(bc *crypto.Blockchain, params []json.RawMessage) (interface{}, *RPCError)
+
+// rpcHandlers maps the name of each JSON-RPC method to its handler.
+var rpcHandlers map[string]rpcHandler
+
+func init() {
+	rpcHandlers = map[string]rpcHandler{
+		"getblock":           rpcGetBlock,
+		"getblockcount":      rpcGetBlockCount,
+		"getblockhash":       rpcGetBlockHash,
+		"getbestblockhash":   rpcGetBestBlockHash,
+		"gettransaction":     rpcGetTransaction,
+		"getbalance":         rpcGetBalance,
+		"sendrawtransaction": rpcSendRawTransaction,
+		"getmempoolinfo":     rpcGetMempoolInfo,
+		"sendtoaddress":      rpcSendToAddress,
+		"walletpassphrase":   rpcWalletPassphrase,
+		"walletlock":         rpcWalletLock,
+		"stop":               rpcStop,
+	}
+}
+
+// rpcServer serves the JSON-RPC methods over HTTP.
+type rpcServer struct {
+	config RPCConfig
+	bc     *crypto.Blockchain
+	http   *http.Server
+}
+
+// startRPCServer starts serving JSON-RPC requests in the background.
+func startRPCServer(config RPCConfig, bc *crypto.Blockchain) *rpcServer {
+	if config.User == "" || config.Password == "" {
+		log.Panic("ERROR: The RPC server needs a user and password")
+	}
+
+	s := &rpcServer{config: config, bc: bc}
+	s.http = &http.Server{Addr: config.Addr, Handler: s}
+
+	go func() {
+		err := s.http.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			log.Panic(err)
+		}
+	}()
+
+	fmt.Printf("RPC server listening on %s\n", config.Addr)
+
+	return s
+}
+
+// shutdown stops the RPC server, letting requests in progress finish.
+func (s *rpcServer) shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	s.http.Shutdown(ctx)
+}
+
+// ServeHTTP handles a JSON-RPC request, or a batch of them sent as an array.
+// Notifications, valid requests without an id, are run but get no response.
+func (s *rpcServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "JSON-RPC requests must be POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="yagocoin"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var body json.RawMessage
+
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRPCRequestSize)).Decode(&body)
+	if err != nil {
+		writeRPC(w, rpcResponse{JSONRPC: "2.0", Error: &RPCError{rpcParseError, "Parse error"}})
+		return
+	}
+
+	if body[0] != '[' {
+		resp, due := s.call(body)
+		if !due {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		writeRPC(w, resp)
+		return
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(body, &batch); err != nil || len(batch) == 0 {
+		writeRPC(w, rpcResponse{JSONRPC: "2.0", Error: &RPCError{rpcInvalidRequest, "Invalid request"}})
+		return
+	}
+
+	var resps []rpcResponse
+	for _, data := range batch {
+		if resp, due := s.call(data); due {
+			resps = append(resps, resp)
+		}
+	}
+
+	// A batch of notifications gets no response at all.
+	if len(resps) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeRPC(w, resps)
+}
+
+// call runs a single JSON-RPC request. The response is only due if the
+// request is not a notification.
+func (s *rpcServer) call(data json.RawMessage) (rpcResponse, bool) {
+	var req rpcRequest
+	resp := rpcResponse{JSONRPC: "2.0"}
+
+	err := json.Unmarshal(data, &req)
+	if err != nil {
+		resp.Error = &RPCError{rpcInvalidRequest, "Invalid request"}
+		return resp, true
+	}
+
+	resp.ID = req.ID
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		resp.Error = &RPCError{rpcInvalidRequest, "Invalid request"}
+		return resp, true
+	}
+
+	if handler, ok := rpcHandlers[req.Method]; !ok {
+		resp.Error = &RPCError{rpcMethodNotFound, "Method not found"}
+	} else {
+		resp.Result, resp.Error = handler(s.bc, req.Params)
+	}
+
+	return resp, req.ID != nil
+}
+
+// writeRPC writes a JSON-RPC response, or an array of them for a batch.
+func writeRPC(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// authorized checks the basic auth credentials of a request.
+func (s *rpcServer) authorized(r *http.Request) bool {
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+
+	userOK := subtle.ConstantTimeCompare([]byte(user), []byte(s.config.User)) == 1
+	passwordOK := subtle.ConstantTimeCompare([]byte(password), []byte(s.config.Password)) == 1
+
+	return userOK && passwordOK
+}
+
+// parseParams decodes the positional parameters of a method call into the
+// given values. Exactly len(values) parameters must be given.
+func parseParams(params []json.RawMessage, values ...interface{}) *RPCError {
+	if len(params) != len(values) {
+		return &RPCError{rpcInvalidParams, fmt.Sprintf("Expected %d parameters", len(values))}
+	}
+
+	for i, v := range values {
+		if err := json.Unmarshal(params[i], v); err != nil {
+			return &RPCError{rpcInvalidParams, fmt.Sprintf("Invalid parameter %d: %s", i+1, err)}
+		}
+	}
+
+	return nil
+}
+
+// parseHash decodes a hex encoded hash parameter.
+func parseHash(params []json.RawMessage) ([]byte, *RPCError) {
+	var hash string
+
+	if err := parseParams(params, &hash); err != nil {
+		return nil, err
+	}
+
+	decoded, err := hex.DecodeString(hash)
+	if err != nil {
+		return nil, &RPCError{rpcInvalidParams, "Invalid hash"}
+	}
+
+	return decoded, nil
+}
+
+// rpcGetBlock returns the block with the given hash.
+func rpcGetBlock(bc *crypto.Blockchain, params []json.RawMessage) (interface{}, *RPCError) {
+	hash, rerr := parseHash(params)
+	if rerr != nil {
+		return nil, rerr
+	}
+
+	block, err := bc.GetBlock(hash)
+	if err != nil {
+		return nil, &RPCError{rpcNotFound, "Block not found"}
+	}
+
+	result := BlockResult{
+		Hash:              hex.EncodeToString(block.Hash),
+		Height:            block.Height,
+		Version:           block.Version,
+		PreviousBlockHash: hex.EncodeToString(block.PrevBlockHash),
+		MerkleRoot:        hex.EncodeToString(block.MerkleRoot),
+		Time:              block.Timestamp.Unix(),
+		Bits:              fmt.Sprintf("%08x", block.Bits),
+		Nonce:             block.Nonce,
+		Hex:               hex.EncodeToString(block.Serialize()),
+	}
+	for _, tx := range block.Transactions {
+		result.Tx = append(result.Tx, hex.EncodeToString(tx.ID))
+	}
+
+	return result, nil
+}
+
+// rpcGetBlockCount returns the height of the tip of the main chain.
+func rpcGetBlockCount(bc *crypto.Blockchain, params []json.RawMessage) (interface{}, *RPCError) {
+	if err := parseParams(params); err != nil {
+		return nil, err
+	}
+
+	return bc.GetBestHeight(), nil
+}
+
+// rpcGetBlockHash returns the hash of the block at the given height of the
+// main chain.
+func rpcGetBlockHash(bc *crypto.Blockchain, params []json.RawMessage) (interface{}, *RPCError) {
+	var height int
+
+	if err := parseParams(params, &height); err != nil {
+		return nil, err
+	}
+
+	hash, err := bc.GetBlockHashByHeight(height)
+	if err != nil {
+		return nil, &RPCError{rpcNotFound, "Block height out of range"}
+	}
+
+	return hex.EncodeToString(hash), nil
+}
+
+// rpcGetBestBlockHash returns the hash of the tip of the main chain.
+func rpcGetBestBlockHash(bc *crypto.Blockchain, params []json.RawMessage) (interface{}, *RPCError) {
+	if err := parseParams(params); err != nil {
+		return nil, err
+	}
+
+	return hex.EncodeToString(bc.GetBestHash()), nil
+}
+
+// rpcGetTransaction returns the transaction with the given ID from the
+// mempool or the main chain.
+func rpcGetTransaction(bc *crypto.Blockchain, params []json.RawMessage) (interface{}, *RPCError) {
+	txID, rerr := parseHash(params)
+	if rerr != nil {
+		return nil, rerr
+	}
+
+	if tx, ok := mempool.Get(txID); ok {
+		return TransactionResult{hex.EncodeToString(tx.ID), false, hex.EncodeToString(tx.Serialize())}, nil
+	}
+
+	tx, err := bc.FindTransaction(txID)
+	if err != nil {
+		return nil, &RPCError{rpcNotFound, "Transaction not found"}
+	}
+
+	return TransactionResult{hex.EncodeToString(tx.ID), true, hex.EncodeToString(tx.Serialize())}, nil
+}
+
+// rpcGetBalance returns the confirmed balance of an address.
+func rpcGetBalance(bc *crypto.Blockchain, params []json.RawMessage) (interface{}, *RPCError) {
+	var address string
+
+	if err := parseParams(params, &address); err != nil {
+		return nil, err
+	}
+
+	if !crypto.ValidateAddress(address) {
+		return nil, &RPCError{rpcInvalidParams, "Invalid address"}
+	}
+
+	uTxOSet := crypto.UTxOSet{Blockchain: bc}
+	balance, immature := uTxOSet.GetBalance(crypto.GetPublicKeyHash([]byte(address)))
+
+	return BalanceResult{address, balance, immature}, nil
+}
+
+// rpcSendRawTransaction adds a hex encoded transaction to the mempool and
+// announces it to the other nodes.
+func rpcSendRawTransaction(bc *crypto.Blockchain, params []json.RawMessage) (interface{}, *RPCError) {
+	var txHex string
+
+	if err := parseParams(params, &txHex); err != nil {
+		return nil, err
+	}
+
+	data, err := hex.DecodeString(txHex)
+	if err != nil {
+		return nil, &RPCError{rpcInvalidParams, "Invalid transaction hex"}
+	}
+
+	tx, err := crypto.DeserializeTransaction(data)
+	if err != nil {
+		return nil, &RPCError{rpcInvalidParams, err.Error()}
+	}
+
+	err = mempool.Add(&tx)
+	if err != nil {
+		return nil, &RPCError{rpcRejected, err.Error()}
+	}
+
+	// Relaying or mining can take a while, so don't hold up the response.
+	go announceTx(&tx, "", bc)
+
+	return hex.EncodeToString(tx.ID), nil
+}
+
+// rpcGetMempoolInfo returns the state of the mempool.
+func rpcGetMempoolInfo(bc *crypto.Blockchain, params []json.RawMessage) (interface{}, *RPCError) {
+	if err := parseParams(params); err != nil {
+		return nil, err
+	}
+
+	return MempoolInfoResult{mempool.Count(), mempool.Size()}, nil
+}
+
+// rpcSendToAddress sends coins from an address in the wallet file of the node,
+// which must be unlocked with walletpassphrase if it is encrypted. The ID of
+// the transaction is returned.
+func rpcSendToAddress(bc *crypto.Blockchain, params []json.RawMessage) (interface{}, *RPCError) {
+	var from, to string
+	var amount, fee int
+
+	if err := parseParams(params, &from, &to, &amount, &fee); err != nil {
+		return nil, err
+	}
+
+	if !crypto.ValidateAddress(from) || !crypto.ValidateAddress(to) {
+		return nil, &RPCError{rpcInvalidParams, "Invalid address"}
+	}
+
+	if amount <= 0 || fee < 0 {
+		return nil, &RPCError{rpcInvalidParams, "Invalid amount or fee"}
+	}
+
+	var tx *crypto.Transaction
+	uTxOSet := crypto.UTxOSet{Blockchain: bc}
+
+	err := signWith(from, func(wallet *crypto.Wallet) error {
+		var err error
+
+		tx, err = crypto.NewLockedUTxOTransaction(wallet, to, "", amount, fee, 0, crypto.SequenceFinal, &uTxOSet)
+
+		return err
+	})
+	if err == crypto.ErrWalletLocked {
+		return nil, &RPCError{rpcWalletUnlockNeeded, "Wallet is locked, unlock it with walletpassphrase first"}
+	} else if err != nil {
+		return nil, &RPCError{rpcWalletError, err.Error()}
+	}
+
+	err = mempool.Add(tx)
+	if err != nil {
+		return nil, &RPCError{rpcRejected, err.Error()}
+	}
+
+	go announceTx(tx, "", bc)
+
+	return hex.EncodeToString(tx.ID), nil
+}
+
+// rpcWalletPassphrase unlocks the encrypted wallet file of the node for the
+// given number of seconds.
+func rpcWalletPassphrase(bc *crypto.Blockchain, params []json.RawMessage) (interface{}, *RPCError) {
+	var passphrase string
+	var timeout int
+
+	if err := parseParams(params, &passphrase, &timeout); err != nil {
+		return nil, err
+	}
+
+	if timeout <= 0 {
+		return nil, &RPCError{rpcInvalidParams, "Timeout must be positive"}
+	}
+
+	err := unlockWallet(passphrase, time.Duration(timeout)*time.Second)
+	switch err {
+	case nil:
+		return nil, nil
+	case crypto.ErrWrongPassphrase:
+		return nil, &RPCError{rpcWalletPassphraseIncorrect, "The wallet passphrase is incorrect"}
+	case crypto.ErrWalletNotEncrypted:
+		return nil, &RPCError{rpcWalletWrongEncState, "The wallet file is not encrypted"}
+	default:
+		return nil, &RPCError{rpcWalletError, err.Error()}
+	}
+}
+
+// rpcWalletLock locks the wallet file of the node again.
+func rpcWalletLock(bc *crypto.Blockchain, params []json.RawMessage) (interface{}, *RPCError) {
+	if err := parseParams(params); err != nil {
+		return nil, err
+	}
+
+	lockWallet()
+
+	return nil, nil
+}
+
+// rpcStop shuts the node down.
+func rpcStop(bc *crypto.Blockchain, params []json.RawMessage) (interface{}, *RPCError) {
+	if err := parseParams(params); err != nil {
+		return nil, err
+	}
+
+	// Stop once the response has been sent.
+	go stopServer()
+
+	return "yagocoin server stopping", nil
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// RPCClient calls the JSON-RPC methods of a running node.
+type RPCClient struct {
+	url      string
+	user     string
+	password string
+	nextID   int
+}
+
+// NewRPCClient creates a client for the JSON-RPC server at the given address.
+func NewRPCClient(addr, user, password string) *RPCClient {
+	return &RPCClient{
+		url:      fmt.Sprintf("http://%s/", addr),
+		user:     user,
+		password: password,
+	}
+}
+
+// Call calls a JSON-RPC method with the given parameters and decodes the
+// result into result. Errors returned by the method are of type *RPCError.
+func (c *RPCClient) Call(method string, result interface{}, params ...interface{}) error {
+	c.nextID++
+
+	if params == nil {
+		params = []interface{}{}
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"params":  params,
+		"id":      c.nextID,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(c.user, c.password)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("RPC server returned %s", resp.Status)
+	}
+
+	var rpcResp struct {
+		Result json.RawMessage `json:"result"`
+		Error  *RPCError       `json:"error"`
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&rpcResp)
+	if err != nil {
+		return err
+	}
+
+	if rpcResp.Error != nil {
+		return rpcResp.Error
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(rpcResp.Result, result)
+}
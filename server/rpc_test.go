@@ -0,0 +1,186 @@
+package server
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/danmrichards/yagocoin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestChain creates a blockchain in a temporary directory, with an empty
+// mempool, and returns it along with the wallet paid by the genesis block.
+func newTestChain(t *testing.T) (*crypto.Blockchain, *crypto.Wallet) {
+	dir, err := ioutil.TempDir("", "yagocoin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Chdir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := crypto.NewWallet()
+	bc := crypto.CreateBlockchain(string(w.GetAddress()), "test")
+	crypto.UTxOSet{Blockchain: bc}.Reindex()
+
+	pool := mempool
+	mempool = crypto.NewMempool(bc, crypto.DefaultMempoolSize, crypto.DefaultMempoolExpiry)
+
+	t.Cleanup(func() {
+		mempool = pool
+		bc.Close()
+		os.Chdir(wd)
+		os.RemoveAll(dir)
+	})
+
+	return bc, w
+}
+
+// rpcPost posts a JSON-RPC request body to the server.
+func rpcPost(s *rpcServer, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.SetBasicAuth("user", "password")
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+
+	return w
+}
+
+// rpcFields posts a JSON-RPC request body to the server and returns the
+// members of the response.
+func rpcFields(t *testing.T, s *rpcServer, body string) map[string]json.RawMessage {
+	w := rpcPost(s, body)
+	assert.Equal(t, http.StatusOK, w.Code, "Request is answered")
+
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(w.Body.Bytes(), &fields)
+	assert.NoError(t, err, "Response is JSON")
+
+	return fields
+}
+
+// rpcErrorCode returns the code of the error in a response.
+func rpcErrorCode(t *testing.T, fields map[string]json.RawMessage) int {
+	var rerr RPCError
+	err := json.Unmarshal(fields["error"], &rerr)
+	assert.NoError(t, err, "Response has an error")
+
+	return rerr.Code
+}
+
+func TestRPCResponses(t *testing.T) {
+	bc, w := newTestChain(t)
+	s := &rpcServer{config: RPCConfig{User: "user", Password: "password"}, bc: bc}
+
+	fields := rpcFields(t, s, `{"jsonrpc":"2.0","method":"getbestblockhash","params":[],"id":1}`)
+	assert.Equal(t, `"`+hex.EncodeToString(bc.GetBestHash())+`"`, string(fields["result"]), "Result is returned")
+	assert.Equal(t, "1", string(fields["id"]), "ID is echoed")
+	assert.NotContains(t, fields, "error", "Success has no error")
+
+	fields = rpcFields(t, s, `{"jsonrpc":"2.0","method":"getbalance","params":["`+string(w.GetAddress())+`"],"id":"a"}`)
+	var balance BalanceResult
+	json.Unmarshal(fields["result"], &balance)
+	assert.Equal(t, crypto.Emission.Subsidy(0), balance.Immature+balance.Balance, "Balance is returned")
+
+	fields = rpcFields(t, s, `{"jsonrpc":"2.0","method":"walletlock","params":[],"id":2}`)
+	assert.Equal(t, "null", string(fields["result"]), "Success without a result has a null result")
+	assert.NotContains(t, fields, "error", "Success has no error")
+
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"parse error", `{"jsonrpc":`, rpcParseError},
+		{"invalid request", `{"jsonrpc":"1.0","method":"getblockcount","id":3}`, rpcInvalidRequest},
+		{"unknown method", `{"jsonrpc":"2.0","method":"nope","params":[],"id":3}`, rpcMethodNotFound},
+		{"wrong number of params", `{"jsonrpc":"2.0","method":"getblockcount","params":[1],"id":3}`, rpcInvalidParams},
+		{"bad hash", `{"jsonrpc":"2.0","method":"getblock","params":["xyz"],"id":3}`, rpcInvalidParams},
+		{"unknown block", `{"jsonrpc":"2.0","method":"getblock","params":["00"],"id":3}`, rpcNotFound},
+		{"bad address", `{"jsonrpc":"2.0","method":"getbalance","params":["nope"],"id":3}`, rpcInvalidParams},
+	}
+
+	for _, test := range tests {
+		fields := rpcFields(t, s, test.body)
+		assert.Equal(t, test.code, rpcErrorCode(t, fields), test.name)
+		assert.NotContains(t, fields, "result", "Error has no result, %s", test.name)
+		assert.Contains(t, fields, "id", "Error has an id, %s", test.name)
+	}
+}
+
+func TestRPCNotification(t *testing.T) {
+	bc, _ := newTestChain(t)
+	s := &rpcServer{config: RPCConfig{User: "user", Password: "password"}, bc: bc}
+
+	for _, body := range []string{
+		`{"jsonrpc":"2.0","method":"getblockcount","params":[]}`,
+		`{"jsonrpc":"2.0","method":"nope","params":[]}`,
+	} {
+		w := rpcPost(s, body)
+		assert.Equal(t, http.StatusNoContent, w.Code, "Notification gets no response")
+		assert.Empty(t, w.Body.String(), "Notification gets no response")
+	}
+
+	fields := rpcFields(t, s, `{"jsonrpc":"2.0","method":"getblockcount","params":[],"id":null}`)
+	assert.Equal(t, "0", string(fields["result"]), "Request with a null id is answered")
+}
+
+func TestRPCBatch(t *testing.T) {
+	bc, _ := newTestChain(t)
+	s := &rpcServer{config: RPCConfig{User: "user", Password: "password"}, bc: bc}
+
+	w := rpcPost(s, `[
+		{"jsonrpc":"2.0","method":"getblockcount","params":[],"id":1},
+		{"jsonrpc":"2.0","method":"getblockcount","params":[]},
+		{"jsonrpc":"2.0","method":"nope","params":[],"id":2},
+		1
+	]`)
+	assert.Equal(t, http.StatusOK, w.Code, "Batch is answered")
+
+	var resps []map[string]json.RawMessage
+	err := json.Unmarshal(w.Body.Bytes(), &resps)
+	assert.NoError(t, err, "Response is a JSON array")
+	if assert.Len(t, resps, 3, "Every request but the notification is answered") {
+		assert.Equal(t, "0", string(resps[0]["result"]), "Result is returned")
+		assert.Equal(t, rpcMethodNotFound, rpcErrorCode(t, resps[1]), "Unknown method is an error")
+		assert.Equal(t, rpcInvalidRequest, rpcErrorCode(t, resps[2]), "Invalid member is an error")
+	}
+
+	fields := rpcFields(t, s, `[]`)
+	assert.Equal(t, rpcInvalidRequest, rpcErrorCode(t, fields), "Empty batch is invalid")
+
+	w = rpcPost(s, `[{"jsonrpc":"2.0","method":"getblockcount","params":[]}]`)
+	assert.Equal(t, http.StatusNoContent, w.Code, "Batch of notifications gets no response")
+	assert.Empty(t, w.Body.String(), "Batch of notifications gets no response")
+}
+
+func TestRPCAuthorization(t *testing.T) {
+	s := &rpcServer{config: RPCConfig{User: "user", Password: "password"}}
+	body := `{"jsonrpc":"2.0","method":"getblockcount","params":[],"id":1}`
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.SetBasicAuth("user", "wrong")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "Wrong password is refused")
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.SetBasicAuth("user", "password")
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code, "Requests must be POSTed")
+}
@@ -2,13 +2,14 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"encoding/gob"
-	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"net"
+	"sync"
 
 	"github.com/danmrichards/yagocoin/crypto"
 )
@@ -22,9 +23,17 @@ const (
 var (
 	nodeAddress     string
 	miningAddress   string
-	KnownNodes      = []string{"localhost:3000"}
+	KnownNodes      = append([]string{}, crypto.Net.SeedNodes...)
 	blocksInTransit = [][]byte{}
-	mempool         = make(map[string]crypto.Transaction)
+	mempool         *crypto.Mempool
+
+	// Cancels the block being mined, if any.
+	cancelMining = func() {}
+	miningMu     sync.Mutex
+
+	// Closed to shut the node down.
+	shutdown     = make(chan struct{})
+	shutdownOnce sync.Once
 )
 
 type addr struct {
@@ -49,6 +58,19 @@ type getData struct {
 	ID       []byte
 }
 
+// getHeaders represents a request for the headers of the main chain which
+// follow a block.
+type getHeaders struct {
+	AddrFrom string
+	After    []byte
+}
+
+// headers represents a message to transfer block headers, oldest first.
+type headers struct {
+	AddrFrom string
+	Headers  [][]byte
+}
+
 // inv represents an inventory of block hashes.
 type inv struct {
 	AddrFrom string
@@ -70,8 +92,9 @@ type version struct {
 }
 
 // commandToBytes returns a byte array representing a command.
-// Our server messages are byte arrays, the first 12 of which specify the name
-// of the command the message represents.
+// Our server messages are byte arrays which start with the magic of the
+// network, followed by 12 bytes which specify the name of the command the
+// message represents.
 func commandToBytes(command string) []byte {
 	var outBytes [commandLength]byte
 
@@ -111,8 +134,11 @@ func sendBlock(addr string, b *crypto.Block) {
 	sendData(addr, request)
 }
 
-// sendData sends a set of data to the specified address.
+// sendData sends a message to the specified address, prefixed with the magic
+// of the network.
 func sendData(addr string, data []byte) {
+	data = append(crypto.Net.Magic[:], data...)
+
 	conn, err := net.Dial(protocol, addr)
 	if err != nil {
 		fmt.Printf("%s is not available\n", addr)
@@ -153,6 +179,29 @@ func sendGetBlocks(address string) {
 	sendData(address, request)
 }
 
+// sendGetHeaders sends a 'get headers' message to an address, asking for the
+// headers that follow the given block.
+func sendGetHeaders(address string, after []byte) {
+	payload := gobEncode(getHeaders{nodeAddress, after})
+	request := append(commandToBytes("getHeaders"), payload...)
+
+	sendData(address, request)
+}
+
+// sendHeaders sends a message representing a list of block headers.
+func sendHeaders(address string, hdrs []*crypto.BlockHeader) {
+	var items [][]byte
+
+	for _, h := range hdrs {
+		items = append(items, h.Serialize())
+	}
+
+	payload := gobEncode(headers{nodeAddress, items})
+	request := append(commandToBytes("headers"), payload...)
+
+	sendData(address, request)
+}
+
 // sendGetData sends a 'get data' request to an address.
 func sendGetData(address, kind string, id []byte) {
 	payload := gobEncode(getData{nodeAddress, kind, id})
@@ -210,21 +259,55 @@ func handleBlock(request []byte, bc *crypto.Blockchain) {
 	}
 
 	blockData := payload.Block
-	block := crypto.DeserializeBlock(blockData)
+	block, err := crypto.DeserializeBlock(blockData)
+	if err != nil {
+		fmt.Printf("Received an invalid block: %s\n", err)
+		return
+	}
 
 	fmt.Println("Recevied a new block!")
-	bc.AddBlock(block)
+
+	// Never store a block until we know it follows the consensus rules.
+	err = bc.ValidateBlock(block)
+	if rerr, ok := err.(crypto.RuleError); ok && rerr.Code == crypto.ErrDuplicateBlock {
+		requestNextBlock(payload.AddrFrom)
+		return
+	}
+
+	var change *crypto.ChainChange
+	if err == nil {
+		change, err = bc.AddBlock(block)
+	}
+	if err != nil {
+		fmt.Printf("Rejected block %x: %s\n", block.Hash, err)
+		blocksInTransit = [][]byte{}
+		return
+	}
 
 	fmt.Printf("Added block %x\n", block.Hash)
 
+	// A block we are mining would no longer build on the tip.
+	if len(change.Connected) > 0 {
+		stopMining()
+	}
+
+	// Transactions from blocks that are no longer on the main chain need to
+	// be mined again.
+	if len(change.Disconnected) > 0 {
+		fmt.Printf("Reorganised chain, %d blocks disconnected\n", len(change.Disconnected))
+	}
+	mempool.ApplyChange(change)
+
+	requestNextBlock(payload.AddrFrom)
+}
+
+// requestNextBlock requests the next block in transit from the given address.
+func requestNextBlock(addr string) {
 	if len(blocksInTransit) > 0 {
 		blockHash := blocksInTransit[0]
-		sendGetData(payload.AddrFrom, "block", blockHash)
+		sendGetData(addr, "block", blockHash)
 
 		blocksInTransit = blocksInTransit[1:]
-	} else {
-		UTXOSet := crypto.UTxOSet{bc}
-		UTXOSet.Reindex()
 	}
 }
 
@@ -243,6 +326,11 @@ func handleInv(request []byte) {
 	fmt.Printf("Recevied inventory with %d %s\n", len(payload.Items), payload.Type)
 
 	if payload.Type == "block" {
+		// Inventories list the newest block first. Blocks can only be added
+		// once their parent is known, so request them oldest first.
+		for i, j := 0, len(payload.Items)-1; i < j; i, j = i+1, j-1 {
+			payload.Items[i], payload.Items[j] = payload.Items[j], payload.Items[i]
+		}
 		blocksInTransit = payload.Items
 
 		blockHash := payload.Items[0]
@@ -260,7 +348,7 @@ func handleInv(request []byte) {
 	if payload.Type == "tx" {
 		txID := payload.Items[0]
 
-		if mempool[hex.EncodeToString(txID)].ID == nil {
+		if !mempool.Has(txID) {
 			sendGetData(payload.AddrFrom, "tx", txID)
 		}
 	}
@@ -282,6 +370,72 @@ func handleGetBlocks(request []byte, bc *crypto.Blockchain) {
 	sendInv(payload.AddrFrom, "block", blocks)
 }
 
+// handleGetHeaders handles a request for the headers which follow a block.
+func handleGetHeaders(request []byte, bc *crypto.Blockchain) {
+	var buff bytes.Buffer
+	var payload getHeaders
+
+	buff.Write(request[commandLength:])
+	dec := gob.NewDecoder(&buff)
+	err := dec.Decode(&payload)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	sendHeaders(payload.AddrFrom, bc.GetHeaders(payload.After, crypto.MaxHeaders))
+}
+
+// handleHeaders handles a list of block headers. The headers are checked for
+// proof-of-work and linkage before the blocks they describe are requested.
+func handleHeaders(request []byte, bc *crypto.Blockchain) {
+	var buff bytes.Buffer
+	var payload headers
+
+	buff.Write(request[commandLength:])
+	dec := gob.NewDecoder(&buff)
+	err := dec.Decode(&payload)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("Recevied %d headers\n", len(payload.Headers))
+
+	var prevHash []byte
+	var wanted [][]byte
+
+	for _, data := range payload.Headers {
+		header, err := crypto.DeserializeBlockHeader(data)
+		if err != nil {
+			fmt.Printf("Received an invalid header: %s\n", err)
+			return
+		}
+
+		// Each header must build on the one before it, or on a block we
+		// already have.
+		linked := bytes.Equal(header.PrevBlockHash, prevHash) ||
+			bc.HasBlock(header.PrevBlockHash)
+		if !linked || !header.CheckProof() {
+			fmt.Printf("Rejected header %x\n", header.Hash())
+			return
+		}
+
+		prevHash = header.Hash()
+		if !bc.HasBlock(prevHash) {
+			wanted = append(wanted, prevHash)
+		}
+	}
+
+	if len(wanted) > 0 {
+		blocksInTransit = wanted[1:]
+		sendGetData(payload.AddrFrom, "block", wanted[0])
+	}
+
+	// A full list means the peer may have more headers to send.
+	if len(payload.Headers) == crypto.MaxHeaders {
+		sendGetHeaders(payload.AddrFrom, prevHash)
+	}
+}
+
 // handleGetData handles a request to get a specific block or transaction.
 func handleGetData(request []byte, bc *crypto.Blockchain) {
 	var buff bytes.Buffer
@@ -304,10 +458,12 @@ func handleGetData(request []byte, bc *crypto.Blockchain) {
 	}
 
 	if payload.Type == "tx" {
-		txID := hex.EncodeToString(payload.ID)
-		tx := mempool[txID]
+		tx, ok := mempool.Get(payload.ID)
+		if !ok {
+			return
+		}
 
-		SendTx(payload.AddrFrom, &tx)
+		SendTx(payload.AddrFrom, tx)
 	}
 }
 
@@ -324,56 +480,73 @@ func handleTx(request []byte, bc *crypto.Blockchain) {
 	}
 
 	txData := payload.Transaction
-	tx := crypto.DeserializeTransaction(txData)
-	mempool[hex.EncodeToString(tx.ID)] = tx
+	tx, err := crypto.DeserializeTransaction(txData)
+	if err != nil {
+		fmt.Printf("Received an invalid transaction: %s\n", err)
+		return
+	}
+	err = mempool.Add(&tx)
+	if err != nil {
+		fmt.Printf("Rejected transaction %x: %s\n", tx.ID, err)
+		return
+	}
+
+	announceTx(&tx, payload.AddFrom, bc)
+}
 
-	if nodeAddress == KnownNodes[0] {
+// announceTx passes on a transaction just added to the mempool. The central
+// node relays it to the other nodes, except the one it came from. Other nodes
+// only relay transactions submitted to them, for which from is empty, and mine
+// the mempool once it holds enough transactions.
+func announceTx(tx *crypto.Transaction, from string, bc *crypto.Blockchain) {
+	central := len(KnownNodes) > 0 && nodeAddress == KnownNodes[0]
+
+	if central || from == "" {
 		for _, node := range KnownNodes {
-			if node != nodeAddress && node != payload.AddFrom {
+			if node != nodeAddress && node != from {
 				sendInv(node, "tx", [][]byte{tx.ID})
 			}
 		}
-	} else {
-		if len(mempool) >= 2 && len(miningAddress) > 0 {
-		MineTransactions:
-			var txs []*crypto.Transaction
-
-			for id := range mempool {
-				tx := mempool[id]
-				if bc.VerifyTransaction(&tx) {
-					txs = append(txs, &tx)
-				}
-			}
+	}
 
-			if len(txs) == 0 {
-				fmt.Println("All transactions are invalid! Waiting for new ones...")
-				return
-			}
+	if !central && len(miningAddress) > 0 && mempool.Count() >= 2 {
+	MineTransactions:
+		var txs []*crypto.Transaction
+		fees := 0
 
-			cbTx := crypto.NewCoinbaseTx(miningAddress, "")
-			txs = append(txs, cbTx)
+		// Everything in the pool has been checked already.
+		for _, entry := range mempool.Entries() {
+			txs = append(txs, entry.Tx)
+			fees += entry.Fee
+		}
 
-			newBlock := bc.MineBlock(txs)
-			UTXOSet := crypto.UTxOSet{bc}
-			UTXOSet.Reindex()
+		if len(txs) == 0 {
+			fmt.Println("No transactions to mine! Waiting for new ones...")
+			return
+		}
 
-			fmt.Println("New block is mined!")
+		cbTx := crypto.NewCoinbaseTx(miningAddress, "", bc.GetBestHeight()+1, fees)
+		txs = append([]*crypto.Transaction{cbTx}, txs...)
 
-			for _, tx := range txs {
-				txID := hex.EncodeToString(tx.ID)
-				delete(mempool, txID)
-			}
+		newBlock, err := bc.MineBlock(startMining(), txs)
+		if err != nil {
+			fmt.Printf("Could not mine block: %s\n", err)
+			return
+		}
 
-			for _, node := range KnownNodes {
-				if node != nodeAddress {
-					sendInv(node, "block", [][]byte{newBlock.Hash})
-				}
-			}
+		fmt.Println("New block is mined!")
 
-			if len(mempool) > 0 {
-				goto MineTransactions
+		mempool.ApplyChange(&crypto.ChainChange{Connected: []*crypto.Block{newBlock}})
+
+		for _, node := range KnownNodes {
+			if node != nodeAddress {
+				sendInv(node, "block", [][]byte{newBlock.Hash})
 			}
 		}
+
+		if mempool.Count() > 0 {
+			goto MineTransactions
+		}
 	}
 }
 
@@ -393,7 +566,7 @@ func handleVersion(request []byte, bc *crypto.Blockchain) {
 	foreignerBestHeight := payload.BestHeight
 
 	if myBestHeight < foreignerBestHeight {
-		sendGetBlocks(payload.AddrFrom)
+		sendGetHeaders(payload.AddrFrom, bc.GetBestHash())
 	} else if myBestHeight > foreignerBestHeight {
 		sendVersion(payload.AddrFrom, bc)
 	}
@@ -410,6 +583,16 @@ func handleConnection(conn net.Conn, bc *crypto.Blockchain) {
 	if err != nil {
 		log.Panic(err)
 	}
+
+	// Ignore messages from nodes on other networks.
+	magic := crypto.Net.Magic[:]
+	if len(request) < len(magic)+commandLength || !bytes.Equal(request[:len(magic)], magic) {
+		fmt.Println("Ignoring message from another network")
+		conn.Close()
+		return
+	}
+	request = request[len(magic):]
+
 	command := bytesToCommand(request[:commandLength])
 	fmt.Printf("Received %s command\n", command)
 
@@ -424,6 +607,10 @@ func handleConnection(conn net.Conn, bc *crypto.Blockchain) {
 		handleGetBlocks(request, bc)
 	case "getData":
 		handleGetData(request, bc)
+	case "getHeaders":
+		handleGetHeaders(request, bc)
+	case "headers":
+		handleHeaders(request, bc)
 	case "tx":
 		handleTx(request, bc)
 	case "version":
@@ -435,10 +622,14 @@ func handleConnection(conn net.Conn, bc *crypto.Blockchain) {
 	conn.Close()
 }
 
-// StartServer starts a node server.
-func StartServer(nodeID, minerAddress string) {
+// StartServer starts a node server. The JSON-RPC server is started too if
+// rpcConfig has an address, and the block explorer if explorerAddr is set.
+// StartServer returns once the node is stopped.
+func StartServer(nodeID, minerAddress string, rpcConfig RPCConfig, explorerAddr string) {
 	nodeAddress = fmt.Sprintf("localhost:%s", nodeID)
 	miningAddress = minerAddress
+	walletNodeID = nodeID
+	defer lockWallet()
 
 	ln, err := net.Listen(protocol, nodeAddress)
 	if err != nil {
@@ -447,6 +638,18 @@ func StartServer(nodeID, minerAddress string) {
 	defer ln.Close()
 
 	bc := crypto.NewBlockchain(nodeID)
+	defer bc.Close()
+	mempool = crypto.NewMempool(bc, crypto.DefaultMempoolSize, crypto.DefaultMempoolExpiry)
+
+	if rpcConfig.Addr != "" {
+		rpc := startRPCServer(rpcConfig, bc)
+		defer rpc.shutdown()
+	}
+
+	if explorerAddr != "" {
+		explorer := startExplorer(explorerAddr, bc)
+		defer explorer.shutdown()
+	}
 
 	// If this is not the central node, send a request to it to check if the
 	// blockchain is up to date.
@@ -454,16 +657,55 @@ func StartServer(nodeID, minerAddress string) {
 		sendVersion(KnownNodes[0], bc)
 	}
 
+	// Stop accepting connections when the node is shut down.
+	go func() {
+		<-shutdown
+		stopMining()
+		ln.Close()
+	}()
+
 	// Handle commands as they come in.
 	for {
 		conn, err := ln.Accept()
 		if err != nil {
-			log.Panic(err)
+			select {
+			case <-shutdown:
+				fmt.Println("Node stopped")
+				return
+			default:
+				log.Panic(err)
+			}
 		}
 		go handleConnection(conn, bc)
 	}
 }
 
+// stopServer shuts the node down.
+func stopServer() {
+	shutdownOnce.Do(func() { close(shutdown) })
+}
+
+// startMining returns a context for mining a new block. Any block that was
+// already being mined is abandoned.
+func startMining() context.Context {
+	miningMu.Lock()
+	defer miningMu.Unlock()
+
+	cancelMining()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelMining = cancel
+
+	return ctx
+}
+
+// stopMining abandons the block being mined, if any.
+func stopMining() {
+	miningMu.Lock()
+	defer miningMu.Unlock()
+
+	cancelMining()
+}
+
 // goEncode encodes data as gob.
 func gobEncode(data interface{}) []byte {
 	var buff bytes.Buffer
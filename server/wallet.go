@@ -0,0 +1,95 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/danmrichards/yagocoin/crypto"
+)
+
+// The wallet file of the node, kept unlocked by walletpassphrase so that
+// sendtoaddress can sign with it until the timeout passes or walletlock is
+// called.
+var (
+	walletMu        sync.Mutex
+	walletNodeID    string
+	unlockedWallets *crypto.Wallets
+	walletLockTimer *time.Timer
+)
+
+// unlockWallet unlocks the wallet file of the node for the given time,
+// replacing any earlier unlock.
+func unlockWallet(passphrase string, timeout time.Duration) error {
+	wallets, err := crypto.NewWallets(walletNodeID)
+	if err != nil {
+		return err
+	}
+
+	err = wallets.Unlock([]byte(passphrase))
+	if err != nil {
+		return err
+	}
+
+	walletMu.Lock()
+	defer walletMu.Unlock()
+
+	lockWalletLocked()
+	unlockedWallets = wallets
+	walletLockTimer = time.AfterFunc(timeout, func() {
+		walletMu.Lock()
+		defer walletMu.Unlock()
+
+		// A later unlock has its own timer.
+		if unlockedWallets == wallets {
+			lockWalletLocked()
+		}
+	})
+
+	return nil
+}
+
+// lockWallet clears the unlocked wallet file of the node from memory.
+func lockWallet() {
+	walletMu.Lock()
+	defer walletMu.Unlock()
+
+	lockWalletLocked()
+}
+
+// lockWalletLocked is lockWallet for callers holding walletMu.
+func lockWalletLocked() {
+	if walletLockTimer != nil {
+		walletLockTimer.Stop()
+		walletLockTimer = nil
+	}
+
+	if unlockedWallets != nil {
+		unlockedWallets.Lock()
+		unlockedWallets = nil
+	}
+}
+
+// signWith calls sign with the wallet of an address, from the unlocked wallet
+// file of the node or else an unencrypted one. The wallet can't be locked
+// while sign runs.
+func signWith(address string, sign func(wallet *crypto.Wallet) error) error {
+	walletMu.Lock()
+	defer walletMu.Unlock()
+
+	wallets := unlockedWallets
+	if wallets == nil {
+		var err error
+
+		wallets, err = crypto.NewWallets(walletNodeID)
+		if err != nil {
+			return err
+		}
+	}
+
+	wallet, err := wallets.GetWallet(address)
+	if err != nil {
+		return err
+	}
+
+	return sign(&wallet)
+}